@@ -1,15 +1,42 @@
 package grimoire
 
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Fs02/grimoire/errors"
+)
+
 // Adapter interface
 type Adapter interface {
-	Count(Query, ...Logger) (int, error)
-	All(Query, interface{}, ...Logger) (int, error)
-	Delete(Query, ...Logger) error
-	Insert(Query, map[string]interface{}, ...Logger) (interface{}, error)
-	InsertAll(Query, []string, []map[string]interface{}, ...Logger) ([]interface{}, error)
-	Update(Query, map[string]interface{}, ...Logger) error
-
-	Begin() (Adapter, error)
+	Count(Query, ...Instrumenter) (int, error)
+	All(Query, interface{}, ...Instrumenter) (int, error)
+	Iterate(Query, ...Instrumenter) (Iterator, error)
+	Delete(Query, ...Instrumenter) (int64, error)
+	Insert(Query, map[string]interface{}, ...Instrumenter) (interface{}, error)
+	InsertAll(Query, []string, []map[string]interface{}, ...Instrumenter) ([]interface{}, error)
+	Update(Query, map[string]interface{}, ...Instrumenter) (int64, error)
+
+	RawQuery(context.Context, interface{}, string, []interface{}, ...Instrumenter) (int64, error)
+	RawExec(context.Context, string, []interface{}, ...Instrumenter) (int64, int64, error)
+
+	Begin(context.Context, *sql.TxOptions) (Adapter, error)
 	Commit() error
 	Rollback() error
 }
+
+// Renderer is implemented by adapters that can render statement of an operation without performing it.
+type Renderer interface {
+	Render(Operation) (string, []interface{}, error)
+}
+
+// Render renders statement and arguments of operation using adapter.
+// It returns an error if adapter doesn't implement Renderer.
+func Render(adapter Adapter, op Operation) (string, []interface{}, error) {
+	renderer, ok := adapter.(Renderer)
+	if !ok {
+		return "", nil, errors.UnexpectedError("adapter doesn't support rendering statement")
+	}
+
+	return renderer.Render(op)
+}
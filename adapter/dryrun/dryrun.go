@@ -0,0 +1,267 @@
+// Package dryrun provides an adapter wrapper that records statements instead of executing them.
+// It's intended to preview migrations and batch jobs, statements are rendered by the wrapped adapter.
+package dryrun
+
+import (
+	"context"
+	db "database/sql"
+	"io"
+	"sync"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/memory"
+	"github.com/Fs02/grimoire/errors"
+)
+
+// Statement records a rendered statement.
+// Statement and Args are empty for begin, commit and rollback.
+type Statement struct {
+	Op            string
+	Statement     string
+	Args          []interface{}
+	InTransaction bool
+}
+
+// Adapter records statement of every call instead of performing it using the wrapped adapter.
+// Writes are applied to an in-memory adapter, so inserts return incrementing ids, writes return affected rows,
+// and records written during dry run can be read back, which allows inserting to a record and save to work.
+// Errors of the in-memory adapter such as duplicate id or canceled context are returned,
+// except for query that can't be evaluated in memory, which is treated as matching no written record.
+// Recorded statements and written records are shared with adapter returned by Begin.
+type Adapter struct {
+	// Reads performs count, all, iterate and raw query using the wrapped adapter after recording them,
+	// so jobs that write based on existing records can be previewed.
+	// All falls back to records written during dry run when the wrapped adapter returns no result,
+	// their ids start from one, so they may collide with ids of existing records.
+	Reads bool
+
+	adapter grimoire.Adapter
+	written grimoire.Adapter
+	state   *state
+	tx      bool
+}
+
+var (
+	_ grimoire.Adapter  = (*Adapter)(nil)
+	_ grimoire.Renderer = (*Adapter)(nil)
+)
+
+type state struct {
+	mutex      sync.Mutex
+	statements []Statement
+}
+
+// Wrap adapter with dry run.
+func Wrap(adapter grimoire.Adapter) *Adapter {
+	return &Adapter{
+		adapter: adapter,
+		written: memory.New(),
+		state:   &state{},
+	}
+}
+
+// Statements returns every recorded statement.
+func (adapter *Adapter) Statements() []Statement {
+	adapter.state.mutex.Lock()
+	defer adapter.state.mutex.Unlock()
+
+	return append([]Statement(nil), adapter.state.statements...)
+}
+
+// Reset removes every recorded statement.
+func (adapter *Adapter) Reset() {
+	adapter.state.mutex.Lock()
+	adapter.state.statements = nil
+	adapter.state.mutex.Unlock()
+}
+
+// Count records count, it's performed using wrapped adapter if Reads is enabled.
+// Otherwise records written during dry run are counted.
+func (adapter *Adapter) Count(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int, error) {
+	if err := adapter.record(grimoire.Operation{Op: "count", Query: query}); err != nil {
+		return 0, err
+	}
+
+	if adapter.Reads {
+		return adapter.adapter.Count(query, instrumenters...)
+	}
+
+	count, err := adapter.written.Count(query)
+	return count, supported(err)
+}
+
+// All records all, it's performed using wrapped adapter if Reads is enabled.
+// Records written during dry run are retrieved when Reads is disabled or the wrapped adapter returns no result.
+func (adapter *Adapter) All(query grimoire.Query, doc interface{}, instrumenters ...grimoire.Instrumenter) (int, error) {
+	if err := adapter.record(grimoire.Operation{Op: "all", Query: query}); err != nil {
+		return 0, err
+	}
+
+	if adapter.Reads {
+		if count, err := adapter.adapter.All(query, doc, instrumenters...); err != nil || count != 0 {
+			return count, err
+		}
+	}
+
+	count, err := adapter.written.All(query, doc)
+	return count, supported(err)
+}
+
+// Iterate records iterate, it's performed using wrapped adapter if Reads is enabled.
+// Otherwise records written during dry run are iterated.
+func (adapter *Adapter) Iterate(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (grimoire.Iterator, error) {
+	if err := adapter.record(grimoire.Operation{Op: "iterate", Query: query}); err != nil {
+		return nil, err
+	}
+
+	if adapter.Reads {
+		return adapter.adapter.Iterate(query, instrumenters...)
+	}
+
+	it, err := adapter.written.Iterate(query)
+	if memory.IsUnsupported(err) {
+		return iterator{}, nil
+	}
+
+	return it, err
+}
+
+// Insert records insert and returns id of the record written during dry run.
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	if err := adapter.record(grimoire.Operation{Op: "insert", Query: query, Changes: changes}); err != nil {
+		return nil, err
+	}
+
+	return adapter.written.Insert(query, changes)
+}
+
+// InsertAll records insert all and returns ids of the records written during dry run.
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	if err := adapter.record(grimoire.Operation{Op: "insert_all", Query: query, Fields: fields, AllChanges: allchanges}); err != nil {
+		return nil, err
+	}
+
+	return adapter.written.InsertAll(query, fields, allchanges)
+}
+
+// Update records update and returns the number of records updated during dry run.
+func (adapter *Adapter) Update(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := adapter.record(grimoire.Operation{Op: "update", Query: query, Changes: changes}); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.written.Update(query, changes)
+	return count, supported(err)
+}
+
+// Delete records delete and returns the number of records deleted during dry run.
+func (adapter *Adapter) Delete(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := adapter.record(grimoire.Operation{Op: "delete", Query: query}); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.written.Delete(query)
+	return count, supported(err)
+}
+
+// RawQuery records raw query, it's performed using wrapped adapter if Reads is enabled.
+func (adapter *Adapter) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := adapter.record(grimoire.Operation{Op: "raw_query", Statement: statement, Args: args}); err != nil || !adapter.Reads {
+		return 0, err
+	}
+
+	return adapter.adapter.RawQuery(ctx, out, statement, args, instrumenters...)
+}
+
+// RawExec records raw exec.
+func (adapter *Adapter) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	return 0, 0, adapter.record(grimoire.Operation{Op: "raw_exec", Statement: statement, Args: args})
+}
+
+// Begin records begin without beginning transaction using wrapped adapter.
+// The returned adapter shares recorded statements and written records with this adapter,
+// records written inside the transaction are discarded when it's rolled back.
+func (adapter *Adapter) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	written, err := adapter.written.Begin(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter.append(Statement{Op: "begin", InTransaction: adapter.tx})
+
+	return &Adapter{
+		Reads:   adapter.Reads,
+		adapter: adapter.adapter,
+		written: written,
+		state:   adapter.state,
+		tx:      true,
+	}, nil
+}
+
+// Commit records commit.
+func (adapter *Adapter) Commit() error {
+	if !adapter.tx {
+		return errors.UnexpectedError("not in transaction")
+	}
+
+	adapter.append(Statement{Op: "commit", InTransaction: true})
+	return adapter.written.Commit()
+}
+
+// Rollback records rollback.
+func (adapter *Adapter) Rollback() error {
+	if !adapter.tx {
+		return errors.UnexpectedError("not in transaction")
+	}
+
+	adapter.append(Statement{Op: "rollback", InTransaction: true})
+	return adapter.written.Rollback()
+}
+
+// Render renders operation using wrapped adapter.
+func (adapter *Adapter) Render(op grimoire.Operation) (string, []interface{}, error) {
+	return grimoire.Render(adapter.adapter, op)
+}
+
+// record renders operation using wrapped adapter and records the statement.
+func (adapter *Adapter) record(op grimoire.Operation) error {
+	statement, args, err := adapter.Render(op)
+	if err != nil {
+		return err
+	}
+
+	adapter.append(Statement{
+		Op:            op.Op,
+		Statement:     statement,
+		Args:          args,
+		InTransaction: adapter.tx,
+	})
+
+	return nil
+}
+
+// supported ignores error of query that can't be evaluated in memory, so it's treated as matching no written record.
+func supported(err error) error {
+	if memory.IsUnsupported(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (adapter *Adapter) append(statement Statement) {
+	adapter.state.mutex.Lock()
+	adapter.state.statements = append(adapter.state.statements, statement)
+	adapter.state.mutex.Unlock()
+}
+
+// iterator is returned by Iterate when the query can't be evaluated in memory.
+type iterator struct{}
+
+func (iterator) Next(record interface{}) error {
+	return io.EOF
+}
+
+func (iterator) Close() error {
+	return nil
+}
@@ -0,0 +1,254 @@
+package dryrun
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/Fs02/go-paranoid"
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/memory"
+	"github.com/Fs02/grimoire/adapter/sqlite3"
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+func open() *sqlite3.Adapter {
+	adapter, err := sqlite3.Open("file::memory:?mode=memory&cache=shared")
+	paranoid.Panic(err)
+
+	_, err = grimoire.New(adapter).Raw(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY,
+		name VARCHAR(30) NOT NULL DEFAULT ''
+	);`).Exec()
+	paranoid.Panic(err)
+
+	return adapter
+}
+
+func TestAdapterWrite(t *testing.T) {
+	database := open()
+	defer database.Close()
+
+	adapter := Wrap(database)
+	repo := grimoire.New(adapter)
+
+	assert.Nil(t, repo.From("users").Set("name", "name").Insert(nil))
+
+	ch := changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"})
+	assert.Nil(t, repo.From("users").Insert(nil, ch, ch))
+	assert.Equal(t, int64(3), repo.From("users").Where(c.Eq(c.I("name"), "name")).Set("name", "new name").MustUpdateAll())
+	assert.Equal(t, int64(1), repo.From("users").Where(c.Eq(c.I("id"), 1)).MustDeleteAll())
+
+	_, err := repo.Raw("DELETE FROM users WHERE id=?;", 1).Exec()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []Statement{
+		{Op: "insert", Statement: `INSERT INTO "users" ("name") VALUES (?);`, Args: []interface{}{"name"}},
+		{Op: "insert_all", Statement: `INSERT INTO "users" ("name") VALUES (?),(?);`, Args: []interface{}{"name", "name"}},
+		{Op: "update", Statement: `UPDATE "users" SET "name"=? WHERE "name"=?;`, Args: []interface{}{"new name", "name"}},
+		{Op: "delete", Statement: `DELETE FROM "users" WHERE "id"=?;`, Args: []interface{}{1}},
+		{Op: "raw_exec", Statement: "DELETE FROM users WHERE id=?;", Args: []interface{}{1}},
+	}, adapter.Statements())
+
+	// nothing is written.
+	assert.Equal(t, 0, grimoire.New(database).From("users").MustCount())
+
+	adapter.Reset()
+	assert.Nil(t, adapter.Statements())
+}
+
+func TestAdapterRead(t *testing.T) {
+	database := open()
+	defer database.Close()
+
+	grimoire.New(database).From("users").Set("name", "name").MustInsert(nil)
+
+	var (
+		adapter = Wrap(database)
+		repo    = grimoire.New(adapter)
+		users   []User
+	)
+
+	assert.Nil(t, repo.From("users").All(&users))
+	assert.Equal(t, 0, len(users))
+	assert.Equal(t, 0, repo.From("users").MustCount())
+
+	it, err := repo.From("users").Iterate()
+	assert.Nil(t, err)
+	assert.Equal(t, io.EOF, it.Next(&User{}))
+	assert.Nil(t, it.Close())
+
+	assert.Nil(t, repo.Raw("SELECT * FROM users;").All(&users))
+	assert.Equal(t, 0, len(users))
+
+	assert.Equal(t, []Statement{
+		{Op: "all", Statement: `SELECT * FROM "users";`},
+		{Op: "count", Statement: `SELECT COUNT(*) AS count FROM "users";`},
+		{Op: "iterate", Statement: `SELECT * FROM "users";`},
+		{Op: "raw_query", Statement: "SELECT * FROM users;"},
+	}, adapter.Statements())
+}
+
+func TestAdapterReads(t *testing.T) {
+	database := open()
+	defer database.Close()
+
+	grimoire.New(database).From("users").Set("name", "name").MustInsert(nil)
+
+	var (
+		adapter = Wrap(database)
+		repo    = grimoire.New(adapter)
+		users   []User
+	)
+
+	adapter.Reads = true
+
+	assert.Nil(t, repo.From("users").All(&users))
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, 1, repo.From("users").MustCount())
+
+	it, err := repo.From("users").Iterate()
+	assert.Nil(t, err)
+	assert.Nil(t, it.Next(&User{}))
+	assert.Nil(t, it.Close())
+
+	assert.Nil(t, repo.Raw("SELECT * FROM users;").All(&users))
+	assert.Equal(t, 1, len(users))
+
+	for _, user := range users {
+		repo.From("users").Find(user.ID).Set("name", "new name").MustUpdateAll()
+	}
+
+	assert.Equal(t, 5, len(adapter.Statements()))
+	assert.Equal(t, Statement{
+		Op:        "update",
+		Statement: `UPDATE "users" SET "name"=? WHERE "users"."id"=?;`,
+		Args:      []interface{}{"new name", users[0].ID},
+	}, adapter.Statements()[4])
+	assert.Equal(t, 1, grimoire.New(database).From("users").Where(c.Eq(c.I("name"), "name")).MustCount())
+}
+
+func TestAdapterInsertRecord(t *testing.T) {
+	database := open()
+	defer database.Close()
+
+	var (
+		adapter = Wrap(database)
+		repo    = grimoire.New(adapter)
+		user    User
+		saved   = User{Name: "saved"}
+		users   []User
+	)
+
+	ch := changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"})
+	assert.Nil(t, repo.From("users").Insert(&user, ch))
+	assert.Equal(t, User{ID: 1, Name: "name"}, user)
+
+	assert.Nil(t, repo.From("users").Save(&saved))
+	assert.Equal(t, User{ID: 2, Name: "saved"}, saved)
+
+	assert.Nil(t, repo.From("users").Insert(&users, ch, ch))
+	assert.Equal(t, []User{{ID: 3, Name: "name"}, {ID: 4, Name: "name"}}, users)
+
+	saved.Name = "updated"
+	assert.Nil(t, repo.From("users").Find(saved.ID).Save(&saved))
+	assert.Nil(t, repo.From("users").Find(saved.ID).One(&user))
+	assert.Equal(t, User{ID: 2, Name: "updated"}, user)
+
+	assert.Equal(t, Statement{
+		Op:        "insert",
+		Statement: `INSERT INTO "users" ("name") VALUES (?);`,
+		Args:      []interface{}{"name"},
+	}, adapter.Statements()[0])
+
+	// nothing is written.
+	assert.Equal(t, 0, grimoire.New(database).From("users").MustCount())
+}
+
+func TestAdapterWriteError(t *testing.T) {
+	database := open()
+	defer database.Close()
+
+	var (
+		adapter     = Wrap(database)
+		repo        = grimoire.New(adapter)
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	cancel()
+
+	assert.Nil(t, repo.From("users").Set("id", 1).Insert(nil))
+	assert.Equal(t, errors.DuplicateError("memory: duplicate id", "id"), repo.From("users").Set("id", 1).Insert(nil))
+
+	_, err := repo.WithContext(ctx).From("users").UpdateAll(changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"}))
+	assert.True(t, err.(errors.Error).CanceledError())
+
+	// query that can't be evaluated in memory matches no written record.
+	count, err := repo.From("users").Where(c.Fragment("id IN (1, 2)")).Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+
+	affected, err := repo.From("users").Where(c.Fragment("id IN (1, 2)")).DeleteAll()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), affected)
+}
+
+func TestAdapterTransaction(t *testing.T) {
+	database := open()
+	defer database.Close()
+
+	adapter := Wrap(database)
+	repo := grimoire.New(adapter)
+
+	err := repo.Transaction(func(repo grimoire.Repo) error {
+		repo.From("users").Set("name", "name").MustInsert(nil)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.From("users").Set("name", "rollback").MustInsert(nil)
+		return errors.UnexpectedError("error")
+	})
+	assert.Equal(t, errors.UnexpectedError("error"), err)
+
+	assert.Equal(t, []Statement{
+		{Op: "begin"},
+		{Op: "insert", Statement: `INSERT INTO "users" ("name") VALUES (?);`, Args: []interface{}{"name"}, InTransaction: true},
+		{Op: "commit", InTransaction: true},
+		{Op: "begin"},
+		{Op: "insert", Statement: `INSERT INTO "users" ("name") VALUES (?);`, Args: []interface{}{"rollback"}, InTransaction: true},
+		{Op: "rollback", InTransaction: true},
+	}, adapter.Statements())
+
+	// record written inside rolled back transaction is discarded.
+	assert.Equal(t, 1, repo.From("users").MustCount())
+
+	assert.Equal(t, errors.UnexpectedError("not in transaction"), adapter.Commit())
+	assert.Equal(t, errors.UnexpectedError("not in transaction"), adapter.Rollback())
+}
+
+func TestAdapterRenderError(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	err := errors.UnexpectedError("adapter doesn't support rendering statement")
+
+	assert.Equal(t, err, repo.From("users").Set("name", "name").Insert(nil))
+	assert.Equal(t, err, repo.From("users").All(&[]User{}))
+
+	_, iterErr := repo.From("users").Iterate()
+	assert.Equal(t, err, iterErr)
+
+	_, _, rawErr := adapter.RawExec(context.Background(), "DELETE FROM users;", nil)
+	assert.Equal(t, err, rawErr)
+
+	assert.Nil(t, adapter.Statements())
+}
@@ -0,0 +1,306 @@
+// Package fault provides an adapter wrapper that injects failure and latency to the wrapped adapter.
+// It's intended to be used in tests to cover error paths that are hard to reproduce using a real database.
+package fault
+
+import (
+	"context"
+	db "database/sql"
+	"sync"
+	"time"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/errors"
+)
+
+// Operations that can be matched by rule.
+const (
+	OpCount     = "count"
+	OpAll       = "all"
+	OpIterate   = "iterate"
+	OpInsert    = "insert"
+	OpInsertAll = "insert_all"
+	OpUpdate    = "update"
+	OpDelete    = "delete"
+	OpRawQuery  = "raw_query"
+	OpRawExec   = "raw_exec"
+	OpBegin     = "begin"
+	OpCommit    = "commit"
+	OpRollback  = "rollback"
+)
+
+// Call records an adapter call.
+type Call struct {
+	Op            string
+	Collection    string
+	InTransaction bool
+	Injected      bool
+	Error         error
+}
+
+// Adapter delegates every call to the wrapped adapter unless it matches a rule.
+// Rules and recorded calls are shared with adapter returned by Begin.
+type Adapter struct {
+	adapter grimoire.Adapter
+	state   *state
+	ctx     context.Context
+	tx      bool
+}
+
+var (
+	_ grimoire.Adapter  = (*Adapter)(nil)
+	_ grimoire.Renderer = (*Adapter)(nil)
+)
+
+type state struct {
+	mutex sync.Mutex
+	rules []*Rule
+	calls []Call
+}
+
+// Wrap adapter with fault injection.
+func Wrap(adapter grimoire.Adapter) *Adapter {
+	return &Adapter{
+		adapter: adapter,
+		state:   &state{},
+		ctx:     context.Background(),
+	}
+}
+
+// On adds a rule that matches the operation, empty operation matches every operation.
+func (adapter *Adapter) On(op string) *Rule {
+	rule := &Rule{mutex: &adapter.state.mutex, op: op, nth: 1}
+
+	adapter.state.mutex.Lock()
+	adapter.state.rules = append(adapter.state.rules, rule)
+	adapter.state.mutex.Unlock()
+
+	return rule
+}
+
+// Calls returns every recorded call.
+func (adapter *Adapter) Calls() []Call {
+	adapter.state.mutex.Lock()
+	defer adapter.state.mutex.Unlock()
+
+	return append([]Call(nil), adapter.state.calls...)
+}
+
+// Reset removes every rule and recorded call.
+func (adapter *Adapter) Reset() {
+	adapter.state.mutex.Lock()
+	adapter.state.rules = nil
+	adapter.state.calls = nil
+	adapter.state.mutex.Unlock()
+}
+
+// Count delegates count to wrapped adapter.
+func (adapter *Adapter) Count(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int, error) {
+	if err := adapter.inject(query.Context(), OpCount, query.Collection); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.adapter.Count(query, instrumenters...)
+	adapter.record(OpCount, query.Collection, err)
+
+	return count, err
+}
+
+// All delegates all to wrapped adapter.
+func (adapter *Adapter) All(query grimoire.Query, doc interface{}, instrumenters ...grimoire.Instrumenter) (int, error) {
+	if err := adapter.inject(query.Context(), OpAll, query.Collection); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.adapter.All(query, doc, instrumenters...)
+	adapter.record(OpAll, query.Collection, err)
+
+	return count, err
+}
+
+// Iterate delegates iterate to wrapped adapter.
+func (adapter *Adapter) Iterate(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (grimoire.Iterator, error) {
+	if err := adapter.inject(query.Context(), OpIterate, query.Collection); err != nil {
+		return nil, err
+	}
+
+	iter, err := adapter.adapter.Iterate(query, instrumenters...)
+	adapter.record(OpIterate, query.Collection, err)
+
+	return iter, err
+}
+
+// Insert delegates insert to wrapped adapter.
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	if err := adapter.inject(query.Context(), OpInsert, query.Collection); err != nil {
+		return nil, err
+	}
+
+	id, err := adapter.adapter.Insert(query, changes, instrumenters...)
+	adapter.record(OpInsert, query.Collection, err)
+
+	return id, err
+}
+
+// InsertAll delegates insert all to wrapped adapter.
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	if err := adapter.inject(query.Context(), OpInsertAll, query.Collection); err != nil {
+		return nil, err
+	}
+
+	ids, err := adapter.adapter.InsertAll(query, fields, allchanges, instrumenters...)
+	adapter.record(OpInsertAll, query.Collection, err)
+
+	return ids, err
+}
+
+// Update delegates update to wrapped adapter.
+func (adapter *Adapter) Update(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := adapter.inject(query.Context(), OpUpdate, query.Collection); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.adapter.Update(query, changes, instrumenters...)
+	adapter.record(OpUpdate, query.Collection, err)
+
+	return count, err
+}
+
+// Delete delegates delete to wrapped adapter.
+func (adapter *Adapter) Delete(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := adapter.inject(query.Context(), OpDelete, query.Collection); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.adapter.Delete(query, instrumenters...)
+	adapter.record(OpDelete, query.Collection, err)
+
+	return count, err
+}
+
+// RawQuery delegates raw query to wrapped adapter.
+func (adapter *Adapter) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := adapter.inject(ctx, OpRawQuery, ""); err != nil {
+		return 0, err
+	}
+
+	count, err := adapter.adapter.RawQuery(ctx, out, statement, args, instrumenters...)
+	adapter.record(OpRawQuery, "", err)
+
+	return count, err
+}
+
+// RawExec delegates raw exec to wrapped adapter.
+func (adapter *Adapter) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	if err := adapter.inject(ctx, OpRawExec, ""); err != nil {
+		return 0, 0, err
+	}
+
+	lastInsertedID, rowAffected, err := adapter.adapter.RawExec(ctx, statement, args, instrumenters...)
+	adapter.record(OpRawExec, "", err)
+
+	return lastInsertedID, rowAffected, err
+}
+
+// Begin delegates begin to wrapped adapter, the returned adapter shares rules and recorded calls with this adapter.
+func (adapter *Adapter) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	if err := adapter.inject(ctx, OpBegin, ""); err != nil {
+		return nil, err
+	}
+
+	tx, err := adapter.adapter.Begin(ctx, opts)
+	adapter.record(OpBegin, "", err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Adapter{
+		adapter: tx,
+		state:   adapter.state,
+		ctx:     ctx,
+		tx:      true,
+	}, nil
+}
+
+// Commit delegates commit to wrapped adapter.
+// If the commit is failed by a rule, the wrapped transaction is rolled back so it won't be left open.
+func (adapter *Adapter) Commit() error {
+	if err := adapter.inject(adapter.ctx, OpCommit, ""); err != nil {
+		adapter.adapter.Rollback()
+		return err
+	}
+
+	err := adapter.adapter.Commit()
+	adapter.record(OpCommit, "", err)
+
+	return err
+}
+
+// Rollback delegates rollback to wrapped adapter.
+func (adapter *Adapter) Rollback() error {
+	if err := adapter.inject(adapter.ctx, OpRollback, ""); err != nil {
+		adapter.adapter.Rollback()
+		return err
+	}
+
+	err := adapter.adapter.Rollback()
+	adapter.record(OpRollback, "", err)
+
+	return err
+}
+
+// Render renders operation using wrapped adapter, rules are not applied since nothing is performed.
+func (adapter *Adapter) Render(op grimoire.Operation) (string, []interface{}, error) {
+	return grimoire.Render(adapter.adapter, op)
+}
+
+// inject applies every rule that matches the call, and returns the error of the first failing rule.
+// The call is recorded if it's failed by a rule.
+func (adapter *Adapter) inject(ctx context.Context, op string, collection string) error {
+	var (
+		delay time.Duration
+		err   error
+	)
+
+	adapter.state.mutex.Lock()
+	for _, rule := range adapter.state.rules {
+		d, e := rule.apply(op, collection)
+		delay += d
+		if err == nil {
+			err = e
+		}
+	}
+	adapter.state.mutex.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			err = errors.CanceledError(ctx.Err().Error())
+		}
+	}
+
+	if err != nil {
+		adapter.state.mutex.Lock()
+		adapter.state.calls = append(adapter.state.calls, Call{
+			Op:            op,
+			Collection:    collection,
+			InTransaction: adapter.tx,
+			Injected:      true,
+			Error:         err,
+		})
+		adapter.state.mutex.Unlock()
+	}
+
+	return err
+}
+
+func (adapter *Adapter) record(op string, collection string, err error) {
+	adapter.state.mutex.Lock()
+	adapter.state.calls = append(adapter.state.calls, Call{
+		Op:            op,
+		Collection:    collection,
+		InTransaction: adapter.tx,
+		Error:         err,
+	})
+	adapter.state.mutex.Unlock()
+}
@@ -0,0 +1,218 @@
+package fault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/memory"
+	"github.com/Fs02/grimoire/adapter/specs"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+func change(name string) *changeset.Changeset {
+	return changeset.Cast(User{}, map[string]interface{}{"name": name}, []string{"name"})
+}
+
+func TestSpecs(t *testing.T) {
+	specs.Suite{
+		Adapter: Wrap(memory.New()),
+		Skip:    []specs.Capability{specs.CapabilityRaw, specs.CapabilityRender, specs.CapabilitySerialization},
+	}.Run(t)
+}
+
+func TestAdapterReturn(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	duperr := errors.DuplicateError("duplicate name", "name")
+
+	adapter.On(OpInsert).Collection("users").Return(duperr)
+
+	assert.Equal(t, duperr, repo.From("users").Insert(nil, change("user")))
+	assert.Nil(t, repo.From("addresses").Insert(nil, change("address")))
+	assert.Equal(t, 0, repo.From("users").MustCount())
+
+	assert.Equal(t, []Call{
+		{Op: OpInsert, Collection: "users", Injected: true, Error: duperr},
+		{Op: OpInsert, Collection: "addresses"},
+		{Op: OpCount, Collection: "users"},
+	}, adapter.Calls())
+}
+
+func TestAdapterDefaultError(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+
+	adapter.On(OpInsert).Once()
+
+	err := repo.From("users").Insert(nil, change("user"))
+	assert.Equal(t, errors.UnexpectedError("fault: injected error"), err)
+	assert.True(t, err.(errors.Error).UnexpectedError())
+	assert.Nil(t, repo.From("users").Insert(nil, change("user")))
+}
+
+func TestAdapterRuleConcurrent(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			repo.From("users").Count()
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		adapter.On(OpCount).Collection("users").Nth(2).Times(1).Delay(0).Return(errors.UnexpectedError("error"))
+	}
+
+	<-done
+}
+
+func TestAdapterNth(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	err := errors.UnexpectedError("connection lost")
+
+	adapter.On(OpInsert).Nth(2).Once().Return(err)
+
+	assert.Nil(t, repo.From("users").Insert(nil, change("first")))
+	assert.Equal(t, err, repo.From("users").Insert(nil, change("second")))
+	assert.Nil(t, repo.From("users").Insert(nil, change("third")))
+	assert.Equal(t, 2, repo.From("users").MustCount())
+}
+
+func TestAdapterTimes(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	err := errors.UnexpectedError("connection lost")
+
+	adapter.On(OpCount).Times(2).Return(err)
+
+	_, err1 := repo.From("users").Count()
+	_, err2 := repo.From("users").Count()
+	_, err3 := repo.From("users").Count()
+
+	assert.Equal(t, err, err1)
+	assert.Equal(t, err, err2)
+	assert.Nil(t, err3)
+}
+
+func TestAdapterCommitError(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	err := errors.UnexpectedError("commit failed")
+
+	adapter.On(OpCommit).Return(err)
+
+	assert.Equal(t, err, repo.Transaction(func(repo grimoire.Repo) error {
+		return repo.From("users").Insert(nil, change("user"))
+	}))
+
+	assert.Equal(t, 0, repo.From("users").MustCount())
+
+	calls := adapter.Calls()
+	assert.Equal(t, Call{Op: OpInsert, Collection: "users", InTransaction: true}, calls[1])
+	assert.Equal(t, Call{Op: OpCommit, InTransaction: true, Injected: true, Error: err}, calls[2])
+}
+
+func TestAdapterDisconnectInTransaction(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	err := errors.UnexpectedError("connection lost")
+
+	// begin and first insert succeed, every call after that fails.
+	adapter.On("").Nth(3).Return(err)
+
+	assert.Equal(t, err, repo.Transaction(func(repo grimoire.Repo) error {
+		if err := repo.From("users").Insert(nil, change("first")); err != nil {
+			return err
+		}
+
+		return repo.From("users").Insert(nil, change("second"))
+	}))
+
+	adapter.Reset()
+	assert.Equal(t, 0, repo.From("users").MustCount())
+}
+
+func TestAdapterDelay(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+
+	adapter.On(OpAll).Delay(10 * time.Millisecond)
+
+	start := time.Now()
+	assert.Nil(t, repo.From("users").All(&[]User{}))
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+}
+
+func TestAdapterDelayCanceled(t *testing.T) {
+	adapter := Wrap(memory.New())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	adapter.On(OpAll).Delay(time.Second)
+
+	err := grimoire.New(adapter).WithContext(ctx).From("users").All(&[]User{})
+	assert.NotNil(t, err)
+	assert.True(t, err.(errors.Error).CanceledError())
+}
+
+func TestAdapterReset(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+
+	adapter.On("").Return(errors.UnexpectedError("error"))
+	assert.NotNil(t, repo.From("users").Insert(nil, change("user")))
+
+	adapter.Reset()
+	assert.Nil(t, adapter.Calls())
+	assert.Nil(t, repo.From("users").Insert(nil, change("user")))
+}
+
+func TestAdapterRaw(t *testing.T) {
+	adapter := Wrap(memory.New())
+	repo := grimoire.New(adapter)
+	err := errors.UnexpectedError("raw failed")
+
+	adapter.On(OpRawQuery).Return(err)
+	adapter.On(OpRawExec).Return(err)
+
+	assert.Equal(t, err, repo.Raw("SELECT 1;").All(&[]User{}))
+
+	_, rawErr := repo.Raw("DELETE FROM users;").Exec()
+	assert.Equal(t, err, rawErr)
+}
+
+type renderer struct {
+	grimoire.Adapter
+}
+
+func (renderer) Render(op grimoire.Operation) (string, []interface{}, error) {
+	return "SELECT * FROM " + op.Query.Collection + ";", nil, nil
+}
+
+func TestAdapterRender(t *testing.T) {
+	adapter := Wrap(renderer{memory.New()})
+	repo := grimoire.New(adapter)
+
+	adapter.On(OpAll).Return(errors.UnexpectedError("all failed"))
+
+	statement, _, err := repo.From("users").ToSQL(OpAll)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM users;", statement)
+	assert.Nil(t, adapter.Calls())
+
+	_, _, err = grimoire.New(Wrap(memory.New())).From("users").ToSQL(OpAll)
+	assert.Equal(t, errors.UnexpectedError("adapter doesn't support rendering statement"), err)
+}
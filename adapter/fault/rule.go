@@ -0,0 +1,96 @@
+package fault
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Fs02/grimoire/errors"
+)
+
+// errInjected is returned by rule without Return and Delay.
+var errInjected = errors.UnexpectedError("fault: injected error")
+
+// Rule defines a fault that is injected to calls that match its operation and collection.
+// By default rule fails every matching call with an unexpected error, use Nth and Times to only fail some of them.
+// Rule with Delay but without Return only delays the call.
+// Rule is safe to be modified while it's applied to calls of other goroutines.
+type Rule struct {
+	mutex      *sync.Mutex
+	op         string
+	collection string
+	nth        int
+	times      int
+	delay      time.Duration
+	err        error
+	matched    int
+	applied    int
+}
+
+// Collection limits the rule to calls of the collection.
+func (rule *Rule) Collection(collection string) *Rule {
+	rule.mutex.Lock()
+	rule.collection = collection
+	rule.mutex.Unlock()
+
+	return rule
+}
+
+// Nth starts applying the rule from the nth matching call, n starts from 1.
+func (rule *Rule) Nth(n int) *Rule {
+	rule.mutex.Lock()
+	rule.nth = n
+	rule.mutex.Unlock()
+
+	return rule
+}
+
+// Times limits the number of calls the rule is applied to, zero means unlimited.
+func (rule *Rule) Times(n int) *Rule {
+	rule.mutex.Lock()
+	rule.times = n
+	rule.mutex.Unlock()
+
+	return rule
+}
+
+// Once applies the rule only to a single call.
+func (rule *Rule) Once() *Rule {
+	return rule.Times(1)
+}
+
+// Delay the call before it's delegated or failed, the call fails with canceled error if its context is done while waiting.
+func (rule *Rule) Delay(delay time.Duration) *Rule {
+	rule.mutex.Lock()
+	rule.delay = delay
+	rule.mutex.Unlock()
+
+	return rule
+}
+
+// Return fails the call with the error, the error should be an errors.Error so it's classified the same way as adapter's error.
+func (rule *Rule) Return(err error) *Rule {
+	rule.mutex.Lock()
+	rule.err = err
+	rule.mutex.Unlock()
+
+	return rule
+}
+
+// apply returns delay and error of the rule if the call matches, caller must hold state's mutex.
+func (rule *Rule) apply(op string, collection string) (time.Duration, error) {
+	if (rule.op != "" && rule.op != op) || (rule.collection != "" && rule.collection != collection) {
+		return 0, nil
+	}
+
+	rule.matched++
+	if rule.matched < rule.nth || (rule.times > 0 && rule.applied >= rule.times) {
+		return 0, nil
+	}
+
+	rule.applied++
+	if rule.err == nil && rule.delay == 0 {
+		return 0, errInjected
+	}
+
+	return rule.delay, rule.err
+}
@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Fs02/grimoire/c"
+)
+
+// matches evaluates condition against the row, comparison with nil value is always false like in sql.
+func matches(r row, cond c.Condition) (bool, error) {
+	switch cond.Type {
+	case c.ConditionAnd:
+		for _, inner := range cond.Inner {
+			if match, err := matches(r, inner); err != nil || !match {
+				return false, err
+			}
+		}
+
+		return true, nil
+	case c.ConditionOr:
+		if len(cond.Inner) == 0 {
+			return true, nil
+		}
+
+		for _, inner := range cond.Inner {
+			if match, err := matches(r, inner); err != nil || match {
+				return match, err
+			}
+		}
+
+		return false, nil
+	case c.ConditionNot:
+		if len(cond.Inner) == 0 {
+			return true, nil
+		}
+
+		match, err := matches(r, c.And(cond.Inner...))
+		return !match && err == nil, err
+	case c.ConditionEq, c.ConditionNe, c.ConditionLt, c.ConditionLte, c.ConditionGt, c.ConditionGte:
+		return comparison(cond.Type, operand(r, cond.Left), operand(r, cond.Right)), nil
+	case c.ConditionNil:
+		return operand(r, cond.Left) == nil, nil
+	case c.ConditionNotNil:
+		return operand(r, cond.Left) != nil, nil
+	case c.ConditionIn, c.ConditionNin:
+		value := operand(r, cond.Left)
+		if value == nil {
+			return false, nil
+		}
+
+		for _, v := range cond.Right.Values {
+			if equal(value, normalize(v)) {
+				return cond.Type == c.ConditionIn, nil
+			}
+		}
+
+		return cond.Type == c.ConditionNin, nil
+	case c.ConditionLike, c.ConditionNotLike:
+		value := operand(r, cond.Left)
+		pattern, ok := operand(r, cond.Right).(string)
+		if value == nil || !ok {
+			return false, nil
+		}
+
+		return like(fmt.Sprint(value), pattern) == (cond.Type == c.ConditionLike), nil
+	case c.ConditionFragment:
+		return fragment(r, string(cond.Left.Column), cond.Right.Values)
+	}
+
+	return false, unsupportedError("condition")
+}
+
+func operand(r row, op c.Operand) interface{} {
+	if op.Column != "" {
+		return r[string(op.Column)]
+	}
+
+	if len(op.Values) == 0 {
+		return nil
+	}
+
+	return normalize(op.Values[0])
+}
+
+func comparison(typ c.ConditionType, left, right interface{}) bool {
+	result, ok := compare(left, right)
+	if !ok {
+		return typ == c.ConditionEq && equal(left, right)
+	}
+
+	switch typ {
+	case c.ConditionEq:
+		return result == 0
+	case c.ConditionNe:
+		return result != 0
+	case c.ConditionLt:
+		return result < 0
+	case c.ConditionLte:
+		return result <= 0
+	case c.ConditionGt:
+		return result > 0
+	default:
+		return result >= 0
+	}
+}
+
+// like matches value using sql pattern, % matches any characters and _ matches a single character.
+func like(value, pattern string) bool {
+	var expr strings.Builder
+	expr.WriteString("(?s)^")
+
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			expr.WriteString(".*")
+		case '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String()).MatchString(value)
+}
+
+var fragmentOperators = map[string]c.ConditionType{
+	"=":  c.ConditionEq,
+	"!=": c.ConditionNe,
+	"<>": c.ConditionNe,
+	"<":  c.ConditionLt,
+	"<=": c.ConditionLte,
+	">":  c.ConditionGt,
+	">=": c.ConditionGte,
+}
+
+// fragment evaluates simple fragment in the form of "field operator value", for example: "age > ?", "age > :min_age" or "id > 0".
+// Value can be a placeholder, named placeholder bound from a map, number, quoted string or another field.
+func fragment(r row, expr string, values []interface{}) (bool, error) {
+	tokens := strings.Fields(expr)
+	if len(tokens) != 3 {
+		return false, unsupportedError("fragment " + expr)
+	}
+
+	typ, ok := fragmentOperators[tokens[1]]
+	if !ok {
+		return false, unsupportedError("fragment " + expr)
+	}
+
+	var right interface{}
+	switch token := tokens[2]; {
+	case token == "?":
+		if len(values) != 1 {
+			return false, unsupportedError("fragment " + expr)
+		}
+
+		right = normalize(values[0])
+	case len(token) >= 2 && token[0] == ':' && token[1] != ':':
+		named, ok := namedValue(values, token[1:])
+		if !ok {
+			return false, unsupportedError("fragment " + expr)
+		}
+
+		right = normalize(named)
+	case len(token) >= 2 && token[0] == '\'' && token[len(token)-1] == '\'':
+		right = token[1 : len(token)-1]
+	default:
+		if i, err := strconv.ParseInt(token, 10, 64); err == nil {
+			right = i
+		} else if f, err := strconv.ParseFloat(token, 64); err == nil {
+			right = f
+		} else {
+			right = r[token]
+		}
+	}
+
+	return comparison(typ, r[tokens[0]], right), nil
+}
+
+// namedValue returns value of named placeholder, values must only contain a map.
+func namedValue(values []interface{}, name string) (interface{}, bool) {
+	if len(values) != 1 {
+		return nil, false
+	}
+
+	named, ok := values[0].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	value, ok := named[name]
+	return value, ok
+}
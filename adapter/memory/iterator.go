@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"io"
+	"reflect"
+)
+
+// Iterator iterates rows retrieved by Adapter.Iterate.
+type Iterator struct {
+	rows  []row
+	index int
+	all   bool
+}
+
+// Next scans the next row into record, it'll return io.EOF if there's no more row.
+func (iterator *Iterator) Next(record interface{}) error {
+	if iterator.index >= len(iterator.rows) {
+		return io.EOF
+	}
+
+	rv := reflect.ValueOf(record)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic("record must be pointer")
+	}
+
+	rv = rv.Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+
+	r := iterator.rows[iterator.index]
+	iterator.index++
+
+	return scan(rv, r, fieldIndex(rv.Type()))
+}
+
+// Close the iterator.
+func (iterator *Iterator) Close() error {
+	iterator.rows = nil
+	return nil
+}
@@ -0,0 +1,430 @@
+// Package memory is an in-memory adapter for grimoire, it's intended to be used in tests that don't need a real database.
+package memory
+
+import (
+	"context"
+	db "database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/sql"
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/errors"
+)
+
+// Adapter stores collections in memory as rows of maps.
+// Transaction is supported by taking snapshot of every collection when it begins, the snapshot is restored on rollback.
+// Transactions are not isolated from each other, changes made inside a transaction are visible to any other query.
+type Adapter struct {
+	store    *store
+	snapshot map[string]*collection
+	tx       bool
+}
+
+var _ grimoire.Adapter = (*Adapter)(nil)
+
+type store struct {
+	mutex       sync.Mutex
+	collections map[string]*collection
+}
+
+type collection struct {
+	rows   []row
+	lastID int64
+}
+
+// New creates an empty in-memory adapter.
+func New() *Adapter {
+	return &Adapter{
+		store: &store{
+			collections: make(map[string]*collection),
+		},
+	}
+}
+
+// Count retrieves count of record that match the query.
+func (adapter *Adapter) Count(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int, error) {
+	if err := query.Context().Err(); err != nil {
+		return 0, errors.CanceledError(err.Error())
+	}
+
+	start := time.Now()
+
+	adapter.store.mutex.Lock()
+	rows, err := adapter.filter(query)
+	adapter.store.mutex.Unlock()
+
+	if len(instrumenters) > 0 {
+		query.Fields = []string{"COUNT(*) AS count"}
+		statement, args := sql.NewBuilder("?", false).Find(query)
+		adapter.instrument(query.Context(), instrumenters, statement, args, start, int64(len(rows)), err)
+	}
+
+	return len(rows), err
+}
+
+// All retrieves all record that match the query.
+func (adapter *Adapter) All(query grimoire.Query, doc interface{}, instrumenters ...grimoire.Instrumenter) (int, error) {
+	rows, err := adapter.find(query, instrumenters)
+	if err != nil {
+		return 0, err
+	}
+
+	return scanAll(doc, rows, selectAll(query.Fields))
+}
+
+// Iterate returns iterator of records that match the query.
+// Results are retrieved when iterate is called, so changes made during iteration won't be visible to the iterator.
+func (adapter *Adapter) Iterate(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (grimoire.Iterator, error) {
+	rows, err := adapter.find(query, instrumenters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Iterator{
+		rows: rows,
+		all:  selectAll(query.Fields),
+	}, nil
+}
+
+// Insert inserts a record to memory and returns its id.
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	if err := query.Context().Err(); err != nil {
+		return nil, errors.CanceledError(err.Error())
+	}
+
+	start := time.Now()
+
+	adapter.store.mutex.Lock()
+	id, err := adapter.insert(query, changes)
+	adapter.store.mutex.Unlock()
+
+	if len(instrumenters) > 0 {
+		statement, args := sql.NewBuilder("?", false).Insert(query.Collection, changes)
+		adapter.instrument(query.Context(), instrumenters, statement, args, start, affected(err), err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+// InsertAll inserts all record to memory and returns its ids.
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	if err := query.Context().Err(); err != nil {
+		return nil, errors.CanceledError(err.Error())
+	}
+
+	var err error
+	start := time.Now()
+	ids := make([]interface{}, 0, len(allchanges))
+
+	adapter.store.mutex.Lock()
+	for _, changes := range allchanges {
+		var id int64
+		if id, err = adapter.insert(query, pick(changes, fields)); err != nil {
+			break
+		}
+
+		ids = append(ids, id)
+	}
+	adapter.store.mutex.Unlock()
+
+	if len(instrumenters) > 0 {
+		statement, args := sql.NewBuilder("?", false).InsertAll(query.Collection, fields, allchanges)
+		adapter.instrument(query.Context(), instrumenters, statement, args, start, int64(len(ids)), err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Update updates records in memory, and returns the number of updated records.
+func (adapter *Adapter) Update(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := query.Context().Err(); err != nil {
+		return 0, errors.CanceledError(err.Error())
+	}
+
+	start := time.Now()
+	values := normalizeRow(changes)
+
+	adapter.store.mutex.Lock()
+	count, err := adapter.each(query, func(coll *collection, i int) {
+		for field, value := range values {
+			coll.rows[i][field] = value
+		}
+
+		if id, ok := values["id"].(int64); ok && id > coll.lastID {
+			coll.lastID = id
+		}
+	})
+	adapter.store.mutex.Unlock()
+
+	if len(instrumenters) > 0 {
+		statement, args := sql.NewBuilder("?", false).Update(query.Collection, changes, query.Condition)
+		adapter.instrument(query.Context(), instrumenters, statement, args, start, count, err)
+	}
+
+	return count, err
+}
+
+// Delete deletes all results that match the query, and returns the number of deleted records.
+func (adapter *Adapter) Delete(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	if err := query.Context().Err(); err != nil {
+		return 0, errors.CanceledError(err.Error())
+	}
+
+	start := time.Now()
+	deleted := make(map[int]bool)
+
+	adapter.store.mutex.Lock()
+	count, err := adapter.each(query, func(coll *collection, i int) {
+		deleted[i] = true
+	})
+
+	if coll := adapter.store.collections[query.Collection]; err == nil && count > 0 {
+		rows := make([]row, 0, len(coll.rows)-len(deleted))
+		for i, r := range coll.rows {
+			if !deleted[i] {
+				rows = append(rows, r)
+			}
+		}
+
+		coll.rows = rows
+	}
+	adapter.store.mutex.Unlock()
+
+	if len(instrumenters) > 0 {
+		statement, args := sql.NewBuilder("?", false).Delete(query.Collection, query.Condition)
+		adapter.instrument(query.Context(), instrumenters, statement, args, start, count, err)
+	}
+
+	return count, err
+}
+
+// RawQuery is not supported by memory adapter.
+func (adapter *Adapter) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	return 0, errors.UnexpectedError("memory: raw query is not supported")
+}
+
+// RawExec is not supported by memory adapter.
+func (adapter *Adapter) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	return 0, 0, errors.UnexpectedError("memory: raw query is not supported")
+}
+
+// Begin begins a new transaction by taking snapshot of every collection.
+// Calling begin inside transaction will take another snapshot, so it behaves like a savepoint.
+// Transaction options are ignored.
+func (adapter *Adapter) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.CanceledError(err.Error())
+	}
+
+	adapter.store.mutex.Lock()
+	defer adapter.store.mutex.Unlock()
+
+	return &Adapter{
+		store:    adapter.store,
+		snapshot: adapter.store.clone(),
+		tx:       true,
+	}, nil
+}
+
+// Commit commits current transaction by discarding its snapshot.
+func (adapter *Adapter) Commit() error {
+	if !adapter.tx {
+		return errors.UnexpectedError("not in transaction")
+	}
+
+	return nil
+}
+
+// Rollback restores snapshot taken when the transaction begins.
+// Last id of each collection is kept, so ids won't be reused after rollback.
+func (adapter *Adapter) Rollback() error {
+	if !adapter.tx {
+		return errors.UnexpectedError("not in transaction")
+	}
+
+	adapter.store.mutex.Lock()
+	defer adapter.store.mutex.Unlock()
+
+	for name, coll := range adapter.store.collections {
+		if snapshot, exist := adapter.snapshot[name]; exist {
+			snapshot.lastID = coll.lastID
+		} else {
+			adapter.snapshot[name] = &collection{lastID: coll.lastID}
+		}
+	}
+
+	adapter.store.collections = adapter.snapshot
+	adapter.snapshot = adapter.store.clone()
+
+	return nil
+}
+
+// find retrieves rows that match the query.
+func (adapter *Adapter) find(query grimoire.Query, instrumenters []grimoire.Instrumenter) ([]row, error) {
+	if err := query.Context().Err(); err != nil {
+		return nil, errors.CanceledError(err.Error())
+	}
+
+	start := time.Now()
+
+	adapter.store.mutex.Lock()
+	rows, err := adapter.query(query)
+	adapter.store.mutex.Unlock()
+
+	if len(instrumenters) > 0 {
+		statement, args := sql.NewBuilder("?", false).Find(query)
+		adapter.instrument(query.Context(), instrumenters, statement, args, start, int64(len(rows)), err)
+	}
+
+	return rows, err
+}
+
+// insert inserts a row, store's mutex must be locked by the caller.
+func (adapter *Adapter) insert(query grimoire.Query, changes map[string]interface{}) (int64, error) {
+	coll := adapter.store.collection(query.Collection)
+	values := normalizeRow(changes)
+
+	if id, ok := values["id"].(int64); ok {
+		if id > coll.lastID {
+			coll.lastID = id
+		}
+	} else {
+		coll.lastID++
+		values["id"] = coll.lastID
+	}
+
+	fields := query.OnConflictClause.Fields
+	if len(fields) == 0 {
+		fields = []string{"id"}
+	}
+
+	for _, r := range coll.rows {
+		if !r.conflict(values, fields) {
+			continue
+		}
+
+		switch query.OnConflictClause.Action {
+		case c.ConflictIgnore:
+			return 0, nil
+		case c.ConflictUpdate:
+			for _, field := range query.OnConflictClause.UpdateFields {
+				r[field] = values[field]
+			}
+
+			id, _ := r["id"].(int64)
+			return id, nil
+		default:
+			return 0, errors.DuplicateError("memory: duplicate "+fields[0], fields[0])
+		}
+	}
+
+	coll.rows = append(coll.rows, values)
+
+	id, _ := values["id"].(int64)
+	return id, nil
+}
+
+// each calls fn with index of every row that match the query's condition, store's mutex must be locked by the caller.
+func (adapter *Adapter) each(query grimoire.Query, fn func(*collection, int)) (int64, error) {
+	coll, exist := adapter.store.collections[query.Collection]
+	if !exist {
+		return 0, nil
+	}
+
+	var count int64
+	for i, r := range coll.rows {
+		match, err := matches(r.view(query.Collection), query.Condition)
+		if err != nil {
+			return 0, err
+		}
+
+		if match {
+			fn(coll, i)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (adapter *Adapter) instrument(ctx context.Context, instrumenters []grimoire.Instrumenter, statement string, args []interface{}, start time.Time, rows int64, err error) {
+	grimoire.Instrument(instrumenters, grimoire.QueryEvent{
+		Context:       ctx,
+		Statement:     statement,
+		Args:          args,
+		Duration:      time.Since(start),
+		Rows:          rows,
+		Error:         err,
+		InTransaction: adapter.tx,
+	})
+}
+
+func (store *store) collection(name string) *collection {
+	coll, exist := store.collections[name]
+	if !exist {
+		coll = &collection{}
+		store.collections[name] = coll
+	}
+
+	return coll
+}
+
+func (store *store) clone() map[string]*collection {
+	collections := make(map[string]*collection, len(store.collections))
+	for name, coll := range store.collections {
+		rows := make([]row, len(coll.rows))
+		for i, r := range coll.rows {
+			rows[i] = r.clone()
+		}
+
+		collections[name] = &collection{
+			rows:   rows,
+			lastID: coll.lastID,
+		}
+	}
+
+	return collections
+}
+
+func pick(changes map[string]interface{}, fields []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, exist := changes[field]; exist {
+			result[field] = value
+		}
+	}
+
+	return result
+}
+
+func affected(err error) int64 {
+	if err != nil {
+		return 0
+	}
+
+	return 1
+}
+
+// IsUnsupported returns true if err is returned because the query can't be evaluated in memory,
+// such as unsupported condition, fragment or join mode.
+func IsUnsupported(err error) bool {
+	e, ok := err.(errors.Error)
+	return ok && e.UnexpectedError() && strings.HasPrefix(e.Message, unsupportedPrefix)
+}
+
+const unsupportedPrefix = "memory: unsupported "
+
+func unsupportedError(message string) error {
+	return errors.UnexpectedError(unsupportedPrefix + message)
+}
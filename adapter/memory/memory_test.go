@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/specs"
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecs(t *testing.T) {
+	specs.Suite{
+		Adapter: New(),
+		Skip:    []specs.Capability{specs.CapabilityRaw, specs.CapabilityRender, specs.CapabilitySerialization},
+	}.Run(t)
+}
+
+type user struct {
+	ID   int64
+	Name string
+	Age  int
+}
+
+func TestAdapterGroup(t *testing.T) {
+	adapter := New()
+	repo := grimoire.New(adapter)
+
+	repo.From("users").MustInsert(&user{}, changeset.Cast(user{}, map[string]interface{}{"name": "a", "age": 10}, []string{"name", "age"}))
+	repo.From("users").MustInsert(&user{}, changeset.Cast(user{}, map[string]interface{}{"name": "a", "age": 20}, []string{"name", "age"}))
+	repo.From("users").MustInsert(&user{}, changeset.Cast(user{}, map[string]interface{}{"name": "b", "age": 30}, []string{"name", "age"}))
+
+	var result []struct {
+		Name  string
+		Total int
+	}
+
+	repo.From("users").Select("name", "SUM(age) AS total").Group("name").Having(c.Gt(c.I("total"), 25)).Order(c.Asc("name")).MustAll(&result)
+
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "a", result[0].Name)
+	assert.Equal(t, 30, result[0].Total)
+	assert.Equal(t, "b", result[1].Name)
+}
+
+func TestAdapterTransactionRollback(t *testing.T) {
+	adapter := New()
+	repo := grimoire.New(adapter)
+
+	repo.From("users").MustInsert(&user{}, changeset.Cast(user{}, map[string]interface{}{"name": "a"}, []string{"name"}))
+
+	err := repo.Transaction(func(repo grimoire.Repo) error {
+		repo.From("users").MustInsert(&user{}, changeset.Cast(user{}, map[string]interface{}{"name": "b"}, []string{"name"}))
+		return errors.UnexpectedError("rollback")
+	})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, repo.From("users").MustCount())
+
+	var u user
+	repo.From("users").MustInsert(&u, changeset.Cast(user{}, map[string]interface{}{"name": "c"}, []string{"name"}))
+	assert.Equal(t, int64(3), u.ID)
+}
+
+func TestAdapterTransactionCommitError(t *testing.T) {
+	assert.NotNil(t, New().Commit())
+}
+
+func TestAdapterTransactionRollbackError(t *testing.T) {
+	assert.NotNil(t, New().Rollback())
+}
+
+func TestAdapterRawError(t *testing.T) {
+	adapter := New()
+
+	_, err := adapter.RawQuery(context.Background(), &[]user{}, "SELECT 1;", nil)
+	assert.NotNil(t, err)
+
+	_, _, err = adapter.RawExec(context.Background(), "DELETE FROM users;", nil)
+	assert.NotNil(t, err)
+}
+
+func TestAdapterCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	repo := grimoire.New(New())
+	_, err := repo.WithContext(ctx).From("users").Count()
+
+	assert.Equal(t, true, err.(errors.Error).CanceledError())
+}
+
+func TestIsUnsupported(t *testing.T) {
+	repo := grimoire.New(New())
+	repo.From("users").Set("name", "name").MustInsert(nil)
+
+	_, err := repo.From("users").Where(c.Fragment("id IN (1, 2)")).Count()
+	assert.True(t, IsUnsupported(err))
+
+	_, err = repo.From("users").Join("addresses", c.Eq(c.I("users.id"), c.I("addresses.user_id"))).Count()
+	assert.False(t, IsUnsupported(err))
+
+	assert.False(t, IsUnsupported(errors.UnexpectedError("error")))
+	assert.False(t, IsUnsupported(errors.DuplicateError("memory: unsupported duplicate", "id")))
+}
@@ -0,0 +1,358 @@
+package memory
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
+)
+
+var aggregateExpr = regexp.MustCompile(`(?i)^(COUNT|SUM|MIN|MAX|AVG)\((.+)\)$`)
+
+// query retrieves rows that match the query, store's mutex must be locked by the caller.
+// The returned rows only contain selected fields.
+func (adapter *Adapter) query(query grimoire.Query) ([]row, error) {
+	rows, err := adapter.filter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(query.GroupFields) > 0 || aggregated(query.Fields) {
+		if rows, err = group(rows, query); err != nil {
+			return nil, err
+		}
+	}
+
+	sortRows(rows, query)
+
+	result := make([]row, 0, len(rows))
+	for _, r := range rows {
+		result = append(result, project(r, query.Fields))
+	}
+
+	if query.AsDistinct {
+		result = distinct(result)
+	}
+
+	return paginate(result, query.OffsetResult, query.LimitResult), nil
+}
+
+// filter retrieves rows of query's collection alongside its joined collection that match the condition.
+func (adapter *Adapter) filter(query grimoire.Query) ([]row, error) {
+	var rows []row
+	if coll, exist := adapter.store.collections[query.Collection]; exist {
+		rows = make([]row, 0, len(coll.rows))
+		for _, r := range coll.rows {
+			rows = append(rows, r.view(query.Collection))
+		}
+	}
+
+	for _, join := range query.JoinClause {
+		var err error
+		if rows, err = adapter.join(rows, join.Mode, join.Collection, join.Condition); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]row, 0, len(rows))
+	for _, r := range rows {
+		match, err := matches(r, query.Condition)
+		if err != nil {
+			return nil, err
+		}
+
+		if match {
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+func (adapter *Adapter) join(rows []row, mode string, collection string, cond c.Condition) ([]row, error) {
+	mode = strings.ToUpper(mode)
+	left := strings.HasPrefix(mode, "LEFT")
+	if !left && mode != "JOIN" && mode != "INNER JOIN" {
+		return nil, unsupportedError("join mode " + mode)
+	}
+
+	var joined []row
+	if coll, exist := adapter.store.collections[collection]; exist {
+		joined = make([]row, 0, len(coll.rows))
+		for _, r := range coll.rows {
+			joined = append(joined, r.view(collection))
+		}
+	}
+
+	result := make([]row, 0, len(rows))
+	for _, r := range rows {
+		found := false
+		for _, j := range joined {
+			merged := r.merge(j)
+			match, err := matches(merged, cond)
+			if err != nil {
+				return nil, err
+			}
+
+			if match {
+				result = append(result, merged)
+				found = true
+			}
+		}
+
+		if !found && left {
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+// group rows by group fields, aggregate fields are computed for each group and the group is filtered using having condition.
+// Rows are considered as a single group if query contains aggregate fields without group fields.
+func group(rows []row, query grimoire.Query) ([]row, error) {
+	var keys []string
+	groups := make(map[string][]row)
+
+	if len(query.GroupFields) == 0 {
+		keys = append(keys, "")
+		groups[""] = rows
+	} else {
+		for _, r := range rows {
+			values := make([]interface{}, len(query.GroupFields))
+			for i, field := range query.GroupFields {
+				values[i] = r[field]
+			}
+
+			key := fmt.Sprintf("%#v", values)
+			if _, exist := groups[key]; !exist {
+				keys = append(keys, key)
+			}
+
+			groups[key] = append(groups[key], r)
+		}
+	}
+
+	result := make([]row, 0, len(keys))
+	for _, key := range keys {
+		members := groups[key]
+
+		r := row{}
+		if len(members) > 0 {
+			r = members[0].clone()
+		}
+
+		for _, field := range query.Fields {
+			expr, alias := parseField(field)
+			if m := aggregateExpr.FindStringSubmatch(expr); m != nil {
+				r[alias] = aggregate(strings.ToUpper(m[1]), m[2], members)
+			}
+		}
+
+		match, err := matches(r, query.HavingCondition)
+		if err != nil {
+			return nil, err
+		}
+
+		if match {
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+func aggregate(fn string, field string, rows []row) interface{} {
+	if fn == "COUNT" {
+		count := int64(0)
+		for _, r := range rows {
+			if field == "*" || r[field] != nil {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	var result interface{}
+	var sum float64
+	var count int
+	for _, r := range rows {
+		value := r[field]
+		if value == nil {
+			continue
+		}
+
+		switch fn {
+		case "MIN", "MAX":
+			cmp, ok := compare(value, result)
+			if result == nil || (ok && ((fn == "MIN" && cmp < 0) || (fn == "MAX" && cmp > 0))) {
+				result = value
+			}
+		default:
+			switch v := value.(type) {
+			case int64:
+				sum += float64(v)
+			case float64:
+				sum += v
+			}
+
+			count++
+		}
+	}
+
+	switch {
+	case fn == "SUM" && count > 0:
+		return sum
+	case fn == "AVG" && count > 0:
+		return sum / float64(count)
+	}
+
+	return result
+}
+
+func sortRows(rows []row, query grimoire.Query) {
+	if len(query.OrderClause) == 0 {
+		return
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, order := range query.OrderClause {
+			a, b := rows[i][string(order.Field)], rows[j][string(order.Field)]
+
+			// nil is considered as the smallest value.
+			var result int
+			switch {
+			case a == nil && b == nil:
+				continue
+			case a == nil:
+				result = -1
+			case b == nil:
+				result = 1
+			default:
+				result, _ = compare(a, b)
+			}
+
+			if result != 0 {
+				return (result < 0) == order.Asc()
+			}
+		}
+
+		return false
+	})
+}
+
+// project returns selected fields of the row using unqualified name.
+func project(r row, fields []string) row {
+	result := row{}
+	for _, field := range fields {
+		expr, alias := parseField(field)
+
+		switch {
+		case expr == "*":
+			for f, value := range r {
+				if !strings.Contains(f, ".") {
+					result[f] = value
+				}
+			}
+		case strings.HasSuffix(expr, ".*"):
+			prefix := strings.TrimSuffix(expr, "*")
+			for f, value := range r {
+				if strings.HasPrefix(f, prefix) {
+					result[strings.TrimPrefix(f, prefix)] = value
+				}
+			}
+		case aggregateExpr.MatchString(expr):
+			// aggregate is computed when the rows are grouped.
+			result[alias] = r[alias]
+		default:
+			if i := strings.LastIndex(alias, "."); i >= 0 && alias == expr {
+				alias = alias[i+1:]
+			}
+
+			result[alias] = r[expr]
+		}
+	}
+
+	return result
+}
+
+func distinct(rows []row) []row {
+	result := make([]row, 0, len(rows))
+	for _, r := range rows {
+		duplicate := false
+		for _, existing := range result {
+			if sameRow(existing, r) {
+				duplicate = true
+				break
+			}
+		}
+
+		if !duplicate {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+func sameRow(a, b row) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for field, value := range a {
+		other, exist := b[field]
+		if !exist || (value == nil) != (other == nil) || (value != nil && !equal(value, other)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func paginate(rows []row, offset int, limit int) []row {
+	if offset >= len(rows) {
+		return nil
+	}
+
+	rows = rows[offset:]
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+
+	return rows
+}
+
+// parseField returns expression and alias of selected field, for example: "COUNT(id) AS count".
+func parseField(field string) (string, string) {
+	if i := strings.LastIndex(strings.ToUpper(field), " AS "); i >= 0 {
+		return strings.TrimSpace(field[:i]), strings.TrimSpace(field[i+4:])
+	}
+
+	field = strings.TrimSpace(field)
+	return field, field
+}
+
+func aggregated(fields []string) bool {
+	for _, field := range fields {
+		if expr, _ := parseField(field); aggregateExpr.MatchString(expr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func selectAll(fields []string) bool {
+	for _, field := range fields {
+		if field == "*" {
+			return true
+		}
+	}
+
+	return false
+}
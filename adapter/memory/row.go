@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// row defines a record stored in collection, values are normalized so they can be compared regardless of its original type.
+type row map[string]interface{}
+
+func (r row) clone() row {
+	result := make(row, len(r))
+	for field, value := range r {
+		result[field] = value
+	}
+
+	return result
+}
+
+// view returns row that can be accessed using both qualified and unqualified field name.
+func (r row) view(collection string) row {
+	result := make(row, len(r)*2)
+	for field, value := range r {
+		result[field] = value
+		result[collection+"."+field] = value
+	}
+
+	return result
+}
+
+// merge adds qualified fields of joined row, unqualified field is only added if it's not ambiguous with existing field.
+func (r row) merge(joined row) row {
+	result := r.clone()
+	for field, value := range joined {
+		if _, exist := result[field]; !exist || strings.Contains(field, ".") {
+			result[field] = value
+		}
+	}
+
+	return result
+}
+
+func (r row) conflict(values row, fields []string) bool {
+	for _, field := range fields {
+		value, exist := values[field]
+		if !exist || value == nil || !equal(r[field], value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func normalizeRow(changes map[string]interface{}) row {
+	result := make(row, len(changes))
+	for field, value := range changes {
+		result[field] = normalize(value)
+	}
+
+	return result
+}
+
+// normalize converts value to one of nil, int64, float64, bool, string, []byte and time.Time if possible.
+func normalize(value interface{}) interface{} {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return value
+		}
+
+		value = v
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+
+		return normalize(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte(nil), rv.Bytes()...)
+		}
+	}
+
+	return value
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal or greater than b.
+// It returns false if the values can't be compared, for example when one of them is nil.
+func compare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return compareFloat(float64(x), float64(y)), true
+		case float64:
+			return compareFloat(float64(x), y), true
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return compareFloat(x, float64(y)), true
+		case float64:
+			return compareFloat(x, y), true
+		}
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), true
+		case []byte:
+			return strings.Compare(x, string(y)), true
+		}
+	case []byte:
+		switch y := b.(type) {
+		case string:
+			return bytes.Compare(x, []byte(y)), true
+		case []byte:
+			return bytes.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case !x:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Equal(y):
+				return 0, true
+			case x.Before(y):
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func equal(a, b interface{}) bool {
+	if result, ok := compare(a, b); ok {
+		return result == 0
+	}
+
+	return a != nil && b != nil && reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"database/sql"
+	"reflect"
+
+	"github.com/Fs02/grimoire/errors"
+	"github.com/Fs02/grimoire/internal"
+	"github.com/azer/snakecase"
+)
+
+// scanAll scans rows into record, which can be a pointer to struct or a pointer to slice of struct.
+// If all fields are selected, struct will be reset before scanned so fields that don't exist in the row will be zero.
+func scanAll(record interface{}, rows []row, all bool) (int, error) {
+	rv := reflect.ValueOf(record)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic("value must be pointer")
+	}
+
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Slice {
+		if len(rows) == 0 {
+			return 0, nil
+		}
+
+		if all {
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+
+		return 1, scan(rv, rows[0], fieldIndex(rv.Type()))
+	}
+
+	rv.Set(reflect.Zero(rv.Type()))
+	index := fieldIndex(rv.Type().Elem())
+
+	for _, r := range rows {
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		if err := scan(elem, r, index); err != nil {
+			return 0, err
+		}
+
+		rv.Set(reflect.Append(rv, elem))
+	}
+
+	return len(rows), nil
+}
+
+func scan(rv reflect.Value, r row, index map[string]int) error {
+	for field, value := range r {
+		if i, exist := index[field]; exist {
+			if err := assign(rv.Field(i), value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// assign value to field, value is converted to field's type if it's compatible.
+func assign(fv reflect.Value, value interface{}) error {
+	if value == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	if scanner, ok := fv.Addr().Interface().(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+
+	if fv.Kind() == reflect.Ptr {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := assign(ptr.Elem(), value); err != nil {
+			return err
+		}
+
+		fv.Set(ptr)
+		return nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(fv.Type()) {
+		fv.Set(rv)
+		return nil
+	}
+
+	if convertible(rv.Type(), fv.Type()) {
+		fv.Set(rv.Convert(fv.Type()))
+		return nil
+	}
+
+	return errors.UnexpectedError("memory: can't assign " + rv.Type().String() + " to " + fv.Type().String())
+}
+
+// convertible checks whether value can be converted without changing its meaning, for example int64 can't be converted to string.
+func convertible(from reflect.Type, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+
+	return numeric(from.Kind()) == numeric(to.Kind())
+}
+
+func numeric(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+func fieldIndex(rt reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	if rt.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+
+		// skip if not scannable
+		if !internal.Scannable(f.Type) {
+			continue
+		}
+
+		if tag := f.Tag.Get("db"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+
+			fields[tag] = i
+		} else {
+			fields[snakecase.SnakeCase(f.Name)] = i
+		}
+	}
+
+	return fields
+}
@@ -2,10 +2,13 @@ package mysql
 
 import (
 	db "database/sql"
+	"strconv"
+	"strings"
 
 	"github.com/Fs02/go-paranoid"
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/adapter/sql"
+	"github.com/Fs02/grimoire/c"
 	"github.com/Fs02/grimoire/errors"
 	"github.com/go-sql-driver/mysql"
 )
@@ -21,12 +24,54 @@ var _ grimoire.Adapter = (*Adapter)(nil)
 func Open(dsn string) (*Adapter, error) {
 	var err error
 
-	adapter := &Adapter{sql.New("?", false, errorFunc, incrementFunc)}
+	adapter := &Adapter{sql.NewWithDialect(Dialect{}, errorFunc, incrementFunc)}
+	adapter.DuplicateKey = true
+	adapter.LockFunc = lockFunc
 	adapter.DB, err = db.Open("mysql", dsn)
 
 	return adapter, err
 }
 
+// Dialect of mysql, identifiers are quoted using backtick.
+type Dialect struct{}
+
+// Quote identifier using backtick.
+func (Dialect) Quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// Placeholder returns ? placeholder.
+func (Dialect) Placeholder(n int) string {
+	return "?"
+}
+
+// Limit returns mysql's limit clause, offset is written before the row count.
+func (Dialect) Limit(limit int, offset int) string {
+	if limit <= 0 {
+		return ""
+	}
+
+	if offset > 0 {
+		return "LIMIT " + strconv.Itoa(offset) + ", " + strconv.Itoa(limit)
+	}
+
+	return "LIMIT " + strconv.Itoa(limit)
+}
+
+// Returning returns false, mysql doesn't support returning clause.
+func (Dialect) Returning() bool {
+	return false
+}
+
+// Bool returns TRUE or FALSE.
+func (Dialect) Bool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
 func incrementFunc(adapter sql.Adapter) int {
 	var variable string
 	var increment int
@@ -41,11 +86,22 @@ func incrementFunc(adapter sql.Adapter) int {
 	return increment
 }
 
+// lockFunc uses LOCK IN SHARE MODE for shared lock without wait option, so it's supported by older mysql.
+func lockFunc(lock c.Lock) string {
+	if lock.Mode == c.LockForShare && lock.Wait == c.LockWaitDefault {
+		return "LOCK IN SHARE MODE"
+	}
+
+	return sql.StandardLock(lock)
+}
+
 func errorFunc(err error) error {
 	if err == nil {
 		return nil
 	} else if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1062 {
 		return errors.DuplicateError(e.Message, "")
+	} else if e, ok := err.(*mysql.MySQLError); ok && e.Number == 1213 {
+		return errors.SerializationError(e.Message)
 	}
 
 	return err
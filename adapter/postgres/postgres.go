@@ -1,7 +1,10 @@
 package postgres
 
 import (
+	"context"
 	db "database/sql"
+	"strconv"
+	"strings"
 
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/adapter/sql"
@@ -20,35 +23,65 @@ var _ grimoire.Adapter = (*Adapter)(nil)
 func Open(dsn string) (*Adapter, error) {
 	var err error
 
-	adapter := &Adapter{sql.New("$", true, errorFunc, nil)}
+	adapter := &Adapter{sql.NewWithDialect(Dialect{}, errorFunc, nil)}
 	adapter.DB, err = db.Open("postgres", dsn)
 
 	return adapter, err
 }
 
+// Dialect of postgres, identifiers are quoted using double quote and placeholders are ordinal.
+type Dialect struct{}
+
+// Quote identifier using double quote.
+func (Dialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Placeholder returns $n placeholder.
+func (Dialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Limit returns standard limit and offset clause.
+func (Dialect) Limit(limit int, offset int) string {
+	return sql.StandardLimit(limit, offset)
+}
+
+// Returning returns true.
+func (Dialect) Returning() bool {
+	return true
+}
+
+// Bool returns TRUE or FALSE.
+func (Dialect) Bool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
 // Insert inserts a record to database and returns its id.
-func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, loggers ...grimoire.Logger) (interface{}, error) {
-	statement, args := sql.NewBuilder(adapter.Placeholder, adapter.Ordinal).
-		Returning("id").
-		Insert(query.Collection, changes)
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	statement, args := adapter.insertBuilder(query).Insert(query.Collection, changes)
 
 	var result struct {
 		ID int64
 	}
 
-	_, err := adapter.Query(&result, statement, args, loggers...)
+	_, err := adapter.Query(query.Context(), &result, statement, args, instrumenters...)
 	return result.ID, err
 }
 
 // InsertAll inserts all record to database and returns its ids.
-func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, loggers ...grimoire.Logger) ([]interface{}, error) {
-	statement, args := sql.NewBuilder(adapter.Placeholder, adapter.Ordinal).Returning("id").InsertAll(query.Collection, fields, allchanges)
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	statement, args := adapter.insertBuilder(query).InsertAll(query.Collection, fields, allchanges)
 
 	var result []struct {
 		ID int64
 	}
 
-	_, err := adapter.Query(&result, statement, args, loggers...)
+	_, err := adapter.Query(query.Context(), &result, statement, args, instrumenters...)
 
 	ids := make([]interface{}, 0, len(result))
 	for _, r := range result {
@@ -58,19 +91,30 @@ func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchan
 	return ids, err
 }
 
+// Render returns statement and arguments of operation without executing it.
+// Insert is rendered with returning clause, the same way it's executed.
+func (adapter *Adapter) Render(op grimoire.Operation) (string, []interface{}, error) {
+	switch op.Op {
+	case "insert":
+		statement, args := adapter.insertBuilder(op.Query).Insert(op.Query.Collection, op.Changes)
+		return statement, args, nil
+	case "insert_all":
+		statement, args := adapter.insertBuilder(op.Query).InsertAll(op.Query.Collection, op.Fields, op.AllChanges)
+		return statement, args, nil
+	}
+
+	return adapter.Adapter.Render(op)
+}
+
+func (adapter *Adapter) insertBuilder(query grimoire.Query) *sql.Builder {
+	return adapter.Builder().Returning("id").OnConflict(query.OnConflictClause)
+}
+
 // Begin begins a new transaction.
-func (adapter *Adapter) Begin() (grimoire.Adapter, error) {
-	Tx, err := adapter.DB.Begin()
-
-	return &Adapter{
-		&sql.Adapter{
-			Placeholder:   adapter.Placeholder,
-			Ordinal:       adapter.Ordinal,
-			IncrementFunc: adapter.IncrementFunc,
-			ErrorFunc:     adapter.ErrorFunc,
-			Tx:            Tx,
-		},
-	}, err
+func (adapter *Adapter) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	txAdapter, err := adapter.Adapter.Begin(ctx, opts)
+
+	return &Adapter{txAdapter.(*sql.Adapter)}, err
 }
 
 func errorFunc(err error) error {
@@ -78,6 +122,8 @@ func errorFunc(err error) error {
 		return nil
 	} else if e, ok := err.(*pq.Error); ok && e.Code == "23505" {
 		return errors.DuplicateError(e.Message, e.Column)
+	} else if e, ok := err.(*pq.Error); ok && (e.Code == "40001" || e.Code == "40P01") {
+		return errors.SerializationError(e.Message)
 	}
 
 	return err
@@ -1,10 +1,10 @@
 package postgres
 
 import (
+	"context"
 	"os"
 	"testing"
 
-	"github.com/Fs02/go-paranoid"
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/adapter/specs"
 	"github.com/Fs02/grimoire/errors"
@@ -12,19 +12,16 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func init() {
-	adapter, err := Open(dsn())
-	if err != nil {
-		panic(err)
+func setup(repo grimoire.Repo) error {
+	if _, err := repo.Raw(`DROP TABLE IF EXISTS addresses;`).Exec(); err != nil {
+		return err
 	}
-	defer adapter.Close()
 
-	_, _, err = adapter.Exec(`DROP TABLE IF EXISTS addresses;`, nil)
-	paranoid.Panic(err)
-	_, _, err = adapter.Exec(`DROP TABLE IF EXISTS users;`, nil)
-	paranoid.Panic(err)
+	if _, err := repo.Raw(`DROP TABLE IF EXISTS users;`).Exec(); err != nil {
+		return err
+	}
 
-	_, _, err = adapter.Exec(`CREATE TABLE users (
+	if _, err := repo.Raw(`CREATE TABLE users (
 		id SERIAL NOT NULL PRIMARY KEY,
 		name VARCHAR(30) NOT NULL DEFAULT '',
 		gender VARCHAR(10) NOT NULL DEFAULT 'male',
@@ -32,17 +29,21 @@ func init() {
 		note varchar(50),
 		created_at TIMESTAMP,
 		updated_at TIMESTAMP
-	);`, nil)
-	paranoid.Panic(err)
+	);`).Exec(); err != nil {
+		return err
+	}
 
-	_, _, err = adapter.Exec(`CREATE TABLE addresses (
+	if _, err := repo.Raw(`CREATE TABLE addresses (
 		id SERIAL NOT NULL PRIMARY KEY,
 		user_id INTEGER REFERENCES users(id),
 		address VARCHAR(60) NOT NULL DEFAULT '',
 		created_at TIMESTAMP,
 		updated_at TIMESTAMP
-	);`, nil)
-	paranoid.Panic(err)
+	);`).Exec(); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func dsn() string {
@@ -59,36 +60,11 @@ func TestSpecs(t *testing.T) {
 		panic(err)
 	}
 	defer adapter.Close()
-	repo := grimoire.New(adapter)
-
-	// Query Specs
-	specs.Query(t, repo)
-	specs.QueryJoin(t, repo)
-	specs.QueryNotFound(t, repo)
-
-	// Count Specs
-	specs.Count(t, repo)
-
-	// Insert Specs
-	specs.Insert(t, repo)
-	specs.InsertAll(t, repo)
-	specs.InsertSet(t, repo)
-
-	// Update Specs
-	specs.Update(t, repo)
-	specs.UpdateWhere(t, repo)
-	specs.UpdateSet(t, repo)
-
-	// Put Specs
-	specs.SaveInsert(t, repo)
-	specs.SaveInsertAll(t, repo)
-	specs.SaveUpdate(t, repo)
-
-	// Delete specs
-	specs.Delete(t, repo)
 
-	// Transaction specs
-	specs.Transaction(t, repo)
+	specs.Suite{
+		Adapter: adapter,
+		Setup:   setup,
+	}.Run(t)
 }
 
 func TestAdapterInsertAllError(t *testing.T) {
@@ -138,7 +114,7 @@ func TestAdapterQueryError(t *testing.T) {
 
 	out := struct{}{}
 
-	_, err = adapter.Query(&out, "error", nil)
+	_, err = adapter.Query(context.Background(), &out, "error", nil)
 	assert.NotNil(t, err)
 }
 
@@ -149,7 +125,7 @@ func TestAdapterExecError(t *testing.T) {
 	}
 	defer adapter.Close()
 
-	_, _, err = adapter.Exec("error", nil)
+	_, _, err = adapter.Exec(context.Background(), "error", nil)
 	assert.NotNil(t, err)
 }
 
@@ -162,7 +138,53 @@ func TestErrorFunc(t *testing.T) {
 	duperr := errors.DuplicateError(rawerr.Message, "")
 	assert.Equal(t, duperr, errorFunc(rawerr))
 
+	// Serialization error
+	rawerr = &pq.Error{Message: "serialization_failure", Code: "40001"}
+	assert.Equal(t, errors.SerializationError(rawerr.Message), errorFunc(rawerr))
+
+	// Deadlock error
+	rawerr = &pq.Error{Message: "deadlock_detected", Code: "40P01"}
+	assert.Equal(t, errors.SerializationError(rawerr.Message), errorFunc(rawerr))
+
 	// other errors
 	err := errors.UnexpectedError("error")
 	assert.Equal(t, err, errorFunc(err))
 }
+
+func TestDialect(t *testing.T) {
+	dialect := Dialect{}
+
+	assert.Equal(t, `"order"`, dialect.Quote("order"))
+	assert.Equal(t, `"na""me"`, dialect.Quote(`na"me`))
+	assert.Equal(t, "$2", dialect.Placeholder(2))
+	assert.Equal(t, "LIMIT 10 OFFSET 20", dialect.Limit(10, 20))
+	assert.True(t, dialect.Returning())
+	assert.Equal(t, "TRUE", dialect.Bool(true))
+	assert.Equal(t, "FALSE", dialect.Bool(false))
+}
+
+func TestAdapterRender(t *testing.T) {
+	adapter, err := Open(dsn())
+	assert.Nil(t, err)
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+
+	tests := []struct {
+		op        string
+		query     grimoire.Query
+		statement string
+	}{
+		{"all", repo.From("users").Find(1), `SELECT * FROM "users" WHERE "users"."id"=$1;`},
+		{"insert", repo.From("users").Set("name", "name"), `INSERT INTO "users" ("name") VALUES ($1) RETURNING "id";`},
+		{"insert", repo.From("users").Set("id", 1).Set("name", "name").OnConflict("id").DoUpdate(), `INSERT INTO "users" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name" RETURNING "id";`},
+		{"update", repo.From("users").Find(1).Set("name", "name"), `UPDATE "users" SET "name"=$1 WHERE "users"."id"=$2;`},
+		{"delete", repo.From("users").Find(1), `DELETE FROM "users" WHERE "users"."id"=$1;`},
+	}
+
+	for _, test := range tests {
+		statement, _, err := test.query.ToSQL(test.op)
+		assert.Nil(t, err)
+		assert.Equal(t, test.statement, statement)
+	}
+}
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancer picks replica to be used for read query.
+type Balancer interface {
+	// Pick returns index of the replica to be used, n is the number of replicas.
+	Pick(n int) int
+
+	// Done is called when a read using the picked replica is done.
+	Done(index int, duration time.Duration, err error)
+}
+
+type roundRobin struct {
+	next uint32
+}
+
+// RoundRobin picks each replica in turn.
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+func (balancer *roundRobin) Pick(n int) int {
+	return int((atomic.AddUint32(&balancer.next, 1) - 1) % uint32(n))
+}
+
+func (balancer *roundRobin) Done(index int, duration time.Duration, err error) {}
+
+// latencyWeight is the weight of the latest latency in the moving average.
+const latencyWeight = 0.3
+
+type leastLatency struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+}
+
+// LeastLatency picks replica with the lowest moving average of latency, replicas that haven't been used are picked first.
+// Failed read doubles the average latency of the replica, so it'll be picked less often.
+func LeastLatency() Balancer {
+	return &leastLatency{}
+}
+
+func (balancer *leastLatency) Pick(n int) int {
+	balancer.mutex.Lock()
+	defer balancer.mutex.Unlock()
+
+	for len(balancer.latencies) < n {
+		balancer.latencies = append(balancer.latencies, 0)
+	}
+
+	index := 0
+	for i := 0; i < n; i++ {
+		if balancer.latencies[i] == 0 {
+			return i
+		}
+
+		if balancer.latencies[i] < balancer.latencies[index] {
+			index = i
+		}
+	}
+
+	return index
+}
+
+func (balancer *leastLatency) Done(index int, duration time.Duration, err error) {
+	balancer.mutex.Lock()
+	defer balancer.mutex.Unlock()
+
+	for len(balancer.latencies) <= index {
+		balancer.latencies = append(balancer.latencies, 0)
+	}
+
+	latency := balancer.latencies[index]
+	switch {
+	case err != nil && latency > 0:
+		latency *= 2
+	case latency == 0:
+		latency = duration
+	default:
+		latency = time.Duration(latencyWeight*float64(duration) + (1-latencyWeight)*float64(latency))
+	}
+
+	// zero is reserved for replica that hasn't been used.
+	if latency <= 0 {
+		latency = 1
+	}
+
+	balancer.latencies[index] = latency
+}
@@ -0,0 +1,35 @@
+package router
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type pinKey struct{}
+
+type pin struct {
+	lastWrite int64
+}
+
+// Pin returns a copy of ctx that reads from primary for the adapter's window after it's used to write.
+// It's useful to pin each request's context, so a request can read its own writes despite replication lag.
+func Pin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinKey{}, &pin{})
+}
+
+func (adapter *Adapter) pinned(ctx context.Context) bool {
+	p, ok := ctx.Value(pinKey{}).(*pin)
+	if !ok || adapter.Window <= 0 {
+		return false
+	}
+
+	lastWrite := atomic.LoadInt64(&p.lastWrite)
+	return lastWrite != 0 && time.Since(time.Unix(0, lastWrite)) < adapter.Window
+}
+
+func (adapter *Adapter) wrote(ctx context.Context) {
+	if p, ok := ctx.Value(pinKey{}).(*pin); ok {
+		atomic.StoreInt64(&p.lastWrite, time.Now().UnixNano())
+	}
+}
@@ -0,0 +1,231 @@
+// Package router provides an adapter that routes read queries to replicas and everything else to primary.
+package router
+
+import (
+	"context"
+	db "database/sql"
+	"io"
+	"time"
+
+	"github.com/Fs02/grimoire"
+)
+
+// Adapter routes Count, All and Iterate to one of the replicas picked by Balancer.
+// Writes, raw queries and transactions are always performed using primary,
+// so are queries marked using Query.Primary and queries using context that is pinned to primary.
+type Adapter struct {
+	Primary  grimoire.Adapter
+	Replicas []grimoire.Adapter
+	Balancer Balancer
+
+	// Window defines how long a pinned context keeps reading from primary after it writes, zero disables pinning.
+	Window time.Duration
+}
+
+var (
+	_ grimoire.Adapter  = (*Adapter)(nil)
+	_ grimoire.Renderer = (*Adapter)(nil)
+)
+
+// New creates a router that balances read queries between replicas using round-robin.
+func New(primary grimoire.Adapter, replicas ...grimoire.Adapter) *Adapter {
+	return &Adapter{
+		Primary:  primary,
+		Replicas: replicas,
+		Balancer: RoundRobin(),
+	}
+}
+
+// Count retrieves count of records using replica.
+func (adapter *Adapter) Count(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int, error) {
+	reader, done := adapter.reader(query)
+	count, err := reader.Count(query, instrumenters...)
+	done(err)
+
+	return count, err
+}
+
+// All retrieves all records using replica.
+func (adapter *Adapter) All(query grimoire.Query, doc interface{}, instrumenters ...grimoire.Instrumenter) (int, error) {
+	reader, done := adapter.reader(query)
+	count, err := reader.All(query, doc, instrumenters...)
+	done(err)
+
+	return count, err
+}
+
+// Iterate retrieves records using replica, the read is reported to balancer when the iterator is closed.
+func (adapter *Adapter) Iterate(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (grimoire.Iterator, error) {
+	reader, done := adapter.reader(query)
+	iter, err := reader.Iterate(query, instrumenters...)
+	if err != nil {
+		done(err)
+		return iter, err
+	}
+
+	return &iterator{Iterator: iter, done: done}, nil
+}
+
+// Insert inserts a record using primary.
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	adapter.wrote(query.Context())
+	return adapter.Primary.Insert(query, changes, instrumenters...)
+}
+
+// InsertAll inserts all records using primary.
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	adapter.wrote(query.Context())
+	return adapter.Primary.InsertAll(query, fields, allchanges, instrumenters...)
+}
+
+// Update updates records using primary.
+func (adapter *Adapter) Update(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	adapter.wrote(query.Context())
+	return adapter.Primary.Update(query, changes, instrumenters...)
+}
+
+// Delete deletes records using primary.
+func (adapter *Adapter) Delete(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	adapter.wrote(query.Context())
+	return adapter.Primary.Delete(query, instrumenters...)
+}
+
+// RawQuery performs raw query using primary, since the statement may modify records.
+func (adapter *Adapter) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	return adapter.Primary.RawQuery(ctx, out, statement, args, instrumenters...)
+}
+
+// RawExec performs raw exec using primary.
+func (adapter *Adapter) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	adapter.wrote(ctx)
+	return adapter.Primary.RawExec(ctx, statement, args, instrumenters...)
+}
+
+// Begin begins transaction using primary, every query inside the transaction is performed by primary.
+// Beginning a transaction, writes inside it and its commit count as a write for pinned context,
+// so a transaction that lasts longer than the window can still be read right after it's committed.
+func (adapter *Adapter) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	adapter.wrote(ctx)
+
+	txAdapter, err := adapter.Primary.Begin(ctx, opts)
+	if err != nil {
+		return txAdapter, err
+	}
+
+	return &transaction{Adapter: txAdapter, router: adapter, ctx: ctx}, nil
+}
+
+// Commit commits transaction of primary.
+func (adapter *Adapter) Commit() error {
+	return adapter.Primary.Commit()
+}
+
+// Rollback rollbacks transaction of primary.
+func (adapter *Adapter) Rollback() error {
+	return adapter.Primary.Rollback()
+}
+
+// Render renders operation using primary.
+func (adapter *Adapter) Render(op grimoire.Operation) (string, []interface{}, error) {
+	return grimoire.Render(adapter.Primary, op)
+}
+
+// reader returns adapter to be used for read query and a function that should be called when the read is done.
+func (adapter *Adapter) reader(query grimoire.Query) (grimoire.Adapter, func(error)) {
+	if len(adapter.Replicas) == 0 || query.UsePrimary || adapter.pinned(query.Context()) {
+		return adapter.Primary, func(error) {}
+	}
+
+	var (
+		index = adapter.Balancer.Pick(len(adapter.Replicas))
+		start = time.Now()
+	)
+
+	return adapter.Replicas[index], func(err error) {
+		adapter.Balancer.Done(index, time.Since(start), err)
+	}
+}
+
+// iterator calls done once it's closed, so duration of the read covers the whole iteration.
+type iterator struct {
+	grimoire.Iterator
+	done func(error)
+	err  error
+}
+
+func (it *iterator) Next(record interface{}) error {
+	err := it.Iterator.Next(record)
+	if err != nil && err != io.EOF {
+		it.err = err
+	}
+
+	return err
+}
+
+func (it *iterator) Close() error {
+	err := it.Iterator.Close()
+	if it.done != nil {
+		if it.err == nil {
+			it.err = err
+		}
+
+		it.done(it.err)
+		it.done = nil
+	}
+
+	return err
+}
+
+// transaction wraps transaction of primary, so writes inside it refresh pinned context.
+type transaction struct {
+	grimoire.Adapter
+	router *Adapter
+	ctx    context.Context
+}
+
+func (tx *transaction) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	tx.router.wrote(query.Context())
+	return tx.Adapter.Insert(query, changes, instrumenters...)
+}
+
+func (tx *transaction) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	tx.router.wrote(query.Context())
+	return tx.Adapter.InsertAll(query, fields, allchanges, instrumenters...)
+}
+
+func (tx *transaction) Update(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	tx.router.wrote(query.Context())
+	return tx.Adapter.Update(query, changes, instrumenters...)
+}
+
+func (tx *transaction) Delete(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	tx.router.wrote(query.Context())
+	return tx.Adapter.Delete(query, instrumenters...)
+}
+
+func (tx *transaction) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	tx.router.wrote(ctx)
+	return tx.Adapter.RawExec(ctx, statement, args, instrumenters...)
+}
+
+func (tx *transaction) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	tx.router.wrote(ctx)
+
+	txAdapter, err := tx.Adapter.Begin(ctx, opts)
+	if err != nil {
+		return txAdapter, err
+	}
+
+	return &transaction{Adapter: txAdapter, router: tx.router, ctx: ctx}, nil
+}
+
+func (tx *transaction) Commit() error {
+	err := tx.Adapter.Commit()
+	tx.router.wrote(tx.ctx)
+
+	return err
+}
+
+func (tx *transaction) Render(op grimoire.Operation) (string, []interface{}, error) {
+	return grimoire.Render(tx.Adapter, op)
+}
@@ -0,0 +1,275 @@
+package router
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/fault"
+	"github.com/Fs02/grimoire/adapter/memory"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID   int64
+	Name string
+}
+
+func ops(adapter *fault.Adapter) []string {
+	var result []string
+	for _, call := range adapter.Calls() {
+		result = append(result, call.Op)
+	}
+
+	return result
+}
+
+func setup() (*Adapter, *fault.Adapter, *fault.Adapter, *fault.Adapter) {
+	var (
+		primary  = fault.Wrap(memory.New())
+		replica1 = fault.Wrap(memory.New())
+		replica2 = fault.Wrap(memory.New())
+	)
+
+	return New(primary, replica1, replica2), primary, replica1, replica2
+}
+
+func TestAdapterRead(t *testing.T) {
+	adapter, primary, replica1, replica2 := setup()
+	repo := grimoire.New(adapter)
+
+	repo.From("users").MustCount()
+	repo.From("users").MustAll(&[]User{})
+	repo.From("users").Each(&User{}, func() error { return nil })
+
+	assert.Nil(t, ops(primary))
+	assert.Equal(t, []string{fault.OpCount, fault.OpIterate}, ops(replica1))
+	assert.Equal(t, []string{fault.OpAll}, ops(replica2))
+}
+
+func TestAdapterWrite(t *testing.T) {
+	adapter, primary, replica1, replica2 := setup()
+	repo := grimoire.New(adapter)
+	ch := changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	user := User{}
+	repo.From("users").MustInsert(&user, ch)
+	repo.From("users").MustInsert(nil, ch, ch)
+	repo.From("users").Find(user.ID).MustUpdate(nil, ch)
+	repo.From("users").Find(user.ID).MustDelete()
+
+	// inserted record is retrieved from primary.
+	assert.Equal(t, []string{
+		fault.OpInsert, fault.OpAll, fault.OpInsertAll, fault.OpUpdate, fault.OpDelete,
+	}, ops(primary))
+	assert.Nil(t, ops(replica1))
+	assert.Nil(t, ops(replica2))
+}
+
+func TestAdapterRaw(t *testing.T) {
+	adapter, primary, replica1, _ := setup()
+	repo := grimoire.New(adapter)
+
+	repo.Raw("SELECT 1;").All(&[]User{})
+	repo.Raw("DELETE FROM users;").Exec()
+
+	assert.Equal(t, []string{fault.OpRawQuery, fault.OpRawExec}, ops(primary))
+	assert.Nil(t, ops(replica1))
+}
+
+func TestAdapterPrimary(t *testing.T) {
+	adapter, primary, replica1, _ := setup()
+	repo := grimoire.New(adapter)
+
+	repo.From("users").Primary().MustCount()
+
+	assert.Equal(t, []string{fault.OpCount}, ops(primary))
+	assert.Nil(t, ops(replica1))
+}
+
+func TestAdapterTransaction(t *testing.T) {
+	adapter, primary, replica1, _ := setup()
+	repo := grimoire.New(adapter)
+
+	assert.Nil(t, repo.Transaction(func(repo grimoire.Repo) error {
+		repo.From("users").MustCount()
+		return nil
+	}))
+
+	assert.Equal(t, []string{fault.OpBegin, fault.OpCount, fault.OpCommit}, ops(primary))
+	assert.Nil(t, ops(replica1))
+
+	assert.NotNil(t, adapter.Commit())
+	assert.NotNil(t, adapter.Rollback())
+}
+
+func TestAdapterWithoutReplica(t *testing.T) {
+	primary := fault.Wrap(memory.New())
+	repo := grimoire.New(New(primary))
+
+	repo.From("users").MustCount()
+
+	assert.Equal(t, []string{fault.OpCount}, ops(primary))
+}
+
+func TestAdapterPin(t *testing.T) {
+	adapter, primary, replica1, replica2 := setup()
+	adapter.Window = 50 * time.Millisecond
+
+	var (
+		repo   = grimoire.New(adapter)
+		ctx    = Pin(context.Background())
+		pinned = repo.WithContext(ctx)
+		ch     = changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"})
+	)
+
+	// not pinned before write.
+	pinned.From("users").MustCount()
+	assert.Equal(t, []string{fault.OpCount}, ops(replica1))
+
+	pinned.From("users").MustInsert(nil, ch)
+	pinned.From("users").MustCount()
+	assert.Equal(t, []string{fault.OpInsert, fault.OpCount}, ops(primary))
+
+	// other context is not affected.
+	repo.From("users").MustCount()
+	assert.Equal(t, []string{fault.OpCount}, ops(replica2))
+
+	// window expired.
+	time.Sleep(adapter.Window)
+	pinned.From("users").MustCount()
+	assert.Equal(t, []string{fault.OpCount, fault.OpCount}, ops(replica1))
+}
+
+func TestAdapterPinTransaction(t *testing.T) {
+	adapter, primary, replica1, _ := setup()
+	adapter.Window = 20 * time.Millisecond
+
+	var (
+		pinned = grimoire.New(adapter).WithContext(Pin(context.Background()))
+		ch     = changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"})
+	)
+
+	// transaction lasts longer than the window.
+	assert.Nil(t, pinned.Transaction(func(repo grimoire.Repo) error {
+		repo.From("users").MustInsert(nil, ch)
+		time.Sleep(2 * adapter.Window)
+		return nil
+	}))
+
+	pinned.From("users").MustCount()
+
+	assert.Equal(t, []string{fault.OpBegin, fault.OpInsert, fault.OpCommit, fault.OpCount}, ops(primary))
+	assert.Nil(t, ops(replica1))
+}
+
+func TestAdapterPinDisabled(t *testing.T) {
+	adapter, primary, replica1, _ := setup()
+
+	var (
+		pinned = grimoire.New(adapter).WithContext(Pin(context.Background()))
+		ch     = changeset.Cast(User{}, map[string]interface{}{"name": "name"}, []string{"name"})
+	)
+
+	pinned.From("users").MustInsert(nil, ch)
+	pinned.From("users").MustCount()
+
+	assert.Equal(t, []string{fault.OpInsert}, ops(primary))
+	assert.Equal(t, []string{fault.OpCount}, ops(replica1))
+}
+
+func TestAdapterBalancerDone(t *testing.T) {
+	adapter, _, replica1, _ := setup()
+	adapter.Balancer = LeastLatency()
+
+	replica1.On(fault.OpCount).Once().Return(errors.UnexpectedError("error"))
+
+	_, err := grimoire.New(adapter).From("users").Count()
+	assert.NotNil(t, err)
+	assert.NotZero(t, adapter.Balancer.(*leastLatency).latencies[0])
+}
+
+type recordBalancer struct {
+	done []error
+}
+
+func (balancer *recordBalancer) Pick(n int) int {
+	return 0
+}
+
+func (balancer *recordBalancer) Done(index int, duration time.Duration, err error) {
+	balancer.done = append(balancer.done, err)
+}
+
+func TestAdapterIterateDone(t *testing.T) {
+	adapter, _, replica1, _ := setup()
+	balancer := &recordBalancer{}
+	adapter.Balancer = balancer
+
+	it, err := grimoire.New(adapter).From("users").Iterate()
+	assert.Nil(t, err)
+	assert.Nil(t, balancer.done)
+
+	assert.Equal(t, io.EOF, it.Next(&User{}))
+	assert.Nil(t, balancer.done)
+
+	assert.Nil(t, it.Close())
+	assert.Nil(t, it.Close())
+	assert.Equal(t, []error{nil}, balancer.done)
+
+	replica1.On(fault.OpIterate).Once().Return(errors.UnexpectedError("error"))
+
+	_, err = grimoire.New(adapter).From("users").Iterate()
+	assert.Equal(t, errors.UnexpectedError("error"), err)
+	assert.Equal(t, []error{nil, errors.UnexpectedError("error")}, balancer.done)
+}
+
+func TestRoundRobin(t *testing.T) {
+	balancer := RoundRobin()
+
+	assert.Equal(t, 0, balancer.Pick(3))
+	assert.Equal(t, 1, balancer.Pick(3))
+	assert.Equal(t, 2, balancer.Pick(3))
+	assert.Equal(t, 0, balancer.Pick(3))
+}
+
+func TestLeastLatency(t *testing.T) {
+	balancer := LeastLatency()
+
+	// unused replica is picked first.
+	assert.Equal(t, 0, balancer.Pick(2))
+	balancer.Done(0, 10*time.Millisecond, nil)
+	assert.Equal(t, 1, balancer.Pick(2))
+	balancer.Done(1, 15*time.Millisecond, nil)
+
+	assert.Equal(t, 0, balancer.Pick(2))
+
+	// failure doubles the latency.
+	balancer.Done(0, time.Millisecond, errors.UnexpectedError("error"))
+	assert.Equal(t, 1, balancer.Pick(2))
+
+	// moving average.
+	balancer.Done(1, 10*time.Millisecond, nil)
+	assert.Equal(t, 13500*time.Microsecond, balancer.(*leastLatency).latencies[1])
+}
+
+type renderer struct {
+	grimoire.Adapter
+	name string
+}
+
+func (r renderer) Render(op grimoire.Operation) (string, []interface{}, error) {
+	return r.name, nil, nil
+}
+
+func TestAdapterRender(t *testing.T) {
+	adapter := New(renderer{memory.New(), "primary"}, renderer{memory.New(), "replica"})
+
+	statement, _, err := grimoire.New(adapter).From("users").ToSQL("all")
+	assert.Nil(t, err)
+	assert.Equal(t, "primary", statement)
+}
@@ -0,0 +1,118 @@
+package specs
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
+	"github.com/stretchr/testify/assert"
+)
+
+// Adapter tests specifications of adapter's methods when it's used without repo.
+func Adapter(t *testing.T, adapter grimoire.Adapter) {
+	query := grimoire.New(adapter).From(users)
+	now := time.Now().Round(time.Second)
+
+	insertedID, err := adapter.Insert(query, map[string]interface{}{"name": "adapter", "gender": "male", "age": 10, "created_at": now, "updated_at": now})
+	assert.Nil(t, err)
+	assert.NotNil(t, insertedID)
+
+	scoped := query.Where(c.Eq(id, insertedID))
+
+	t.Run("Adapter|Count", func(t *testing.T) {
+		count, err := adapter.Count(scoped)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Adapter|All", func(t *testing.T) {
+		var result []User
+		count, err := adapter.All(scoped, &result)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		if assert.Equal(t, 1, len(result)) {
+			assert.Equal(t, "adapter", result[0].Name)
+		}
+	})
+
+	t.Run("Adapter|Iterate", func(t *testing.T) {
+		iter, err := adapter.Iterate(scoped)
+		assert.Nil(t, err)
+
+		var result User
+		assert.Nil(t, iter.Next(&result))
+		assert.Equal(t, "adapter", result.Name)
+		assert.Equal(t, io.EOF, iter.Next(&result))
+		assert.Nil(t, iter.Close())
+	})
+
+	t.Run("Adapter|InsertAll", func(t *testing.T) {
+		fields := []string{"name", "gender", "age", "created_at", "updated_at"}
+		allchanges := []map[string]interface{}{
+			{"name": "adapter insert all", "gender": "male", "age": 10, "created_at": now, "updated_at": now},
+			{"name": "adapter insert all", "gender": "female", "age": 20, "created_at": now, "updated_at": now},
+		}
+
+		ids, err := adapter.InsertAll(query, fields, allchanges)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(ids))
+		assert.NotEqual(t, ids[0], ids[1])
+	})
+
+	t.Run("Adapter|Update", func(t *testing.T) {
+		var result []User
+		count, err := adapter.Update(scoped, map[string]interface{}{"age": 20})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+
+		_, err = adapter.All(scoped, &result)
+		assert.Nil(t, err)
+		assert.Equal(t, 20, result[0].Age)
+	})
+
+	t.Run("Adapter|Rollback", func(t *testing.T) {
+		var result []User
+
+		tx, err := adapter.Begin(context.Background(), nil)
+		assert.Nil(t, err)
+		_, err = tx.Update(scoped, map[string]interface{}{"age": 30})
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Rollback())
+
+		_, err = adapter.All(scoped, &result)
+		assert.Nil(t, err)
+		assert.Equal(t, 20, result[0].Age)
+	})
+
+	t.Run("Adapter|Commit", func(t *testing.T) {
+		var result []User
+
+		tx, err := adapter.Begin(context.Background(), nil)
+		assert.Nil(t, err)
+		_, err = tx.Update(scoped, map[string]interface{}{"age": 40})
+		assert.Nil(t, err)
+		assert.Nil(t, tx.Commit())
+
+		_, err = adapter.All(scoped, &result)
+		assert.Nil(t, err)
+		assert.Equal(t, 40, result[0].Age)
+	})
+
+	t.Run("Adapter|OutsideTransaction", func(t *testing.T) {
+		assert.NotNil(t, adapter.Commit())
+		assert.NotNil(t, adapter.Rollback())
+	})
+
+	t.Run("Adapter|Delete", func(t *testing.T) {
+		deleted, err := adapter.Delete(scoped)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		count, err := adapter.Count(scoped)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
@@ -0,0 +1,87 @@
+package specs
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
+	"github.com/stretchr/testify/assert"
+)
+
+// Concurrency tests specifications of repo that is used by multiple goroutines.
+func Concurrency(t *testing.T, repo grimoire.Repo) {
+	const n = 10
+
+	t.Run("Concurrency|Insert", func(t *testing.T) {
+		var (
+			wg    sync.WaitGroup
+			mutex sync.Mutex
+			ids   = make(map[int64]bool, n)
+			errs  []error
+		)
+
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				user := User{Name: "concurrent", Gender: "male", Age: i}
+				err := repo.From(users).Save(&user)
+
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				if err != nil {
+					errs = append(errs, err)
+					return
+				}
+
+				ids[user.ID] = true
+			}(i)
+		}
+
+		wg.Wait()
+
+		assert.Nil(t, errs)
+		assert.Equal(t, n, len(ids))
+
+		count, err := repo.From(users).Where(c.Eq(name, "concurrent")).Count()
+		assert.Nil(t, err)
+		assert.Equal(t, n, count)
+	})
+
+	t.Run("Concurrency|Transaction", func(t *testing.T) {
+		var (
+			wg    sync.WaitGroup
+			mutex sync.Mutex
+			errs  []error
+		)
+
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				err := repo.Transaction(func(repo grimoire.Repo) error {
+					user := User{Name: "concurrent transaction", Gender: "male", Age: i}
+					return repo.From(users).Save(&user)
+				})
+
+				if err != nil {
+					mutex.Lock()
+					errs = append(errs, err)
+					mutex.Unlock()
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		assert.Nil(t, errs)
+
+		count, err := repo.From(users).Where(c.Eq(name, "concurrent transaction")).Count()
+		assert.Nil(t, err)
+		assert.Equal(t, n, count)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/adapter/sql"
 	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,10 +31,16 @@ func Delete(t *testing.T, repo grimoire.Repo) {
 			assert.Nil(t, query.All(&result))
 			assert.NotEqual(t, 0, len(result))
 
-			assert.Nil(t, query.Delete())
+			count, err := query.DeleteAll()
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(result)), count)
 
 			assert.Nil(t, query.All(&result))
 			assert.Equal(t, 0, len(result))
+
+			err = query.MustAffect(1).Delete()
+			assert.NotNil(t, err)
+			assert.True(t, err.(errors.Error).NotFoundError())
 		})
 	}
 }
@@ -0,0 +1,55 @@
+package specs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// Errors tests error classification specifications.
+func Errors(t *testing.T, repo grimoire.Repo) {
+	user := User{Name: "errors", Gender: "male", Age: 10}
+	assert.Nil(t, repo.From(users).Save(&user))
+
+	t.Run("Errors|NotFound", func(t *testing.T) {
+		result := User{}
+		err := repo.From(users).Where(c.Eq(name, "errors not found")).One(&result)
+		assert.NotNil(t, err)
+		assert.True(t, err.(errors.Error).NotFoundError())
+	})
+
+	t.Run("Errors|Duplicate", func(t *testing.T) {
+		ch := changeset.Cast(User{}, map[string]interface{}{"name": "duplicate"}, []string{"name"})
+		err := repo.From(users).Set("id", user.ID).Insert(nil, ch)
+		assert.NotNil(t, err)
+		assert.True(t, err.(errors.Error).DuplicateError())
+	})
+
+	t.Run("Errors|Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		repo := repo.WithContext(ctx)
+		ch := changeset.Cast(User{}, map[string]interface{}{"name": "canceled"}, []string{"name"})
+
+		_, err := repo.From(users).Count()
+		assertCanceled(t, err)
+		assertCanceled(t, repo.From(users).All(&[]User{}))
+		assertCanceled(t, repo.From(users).Insert(nil, ch))
+		assertCanceled(t, repo.From(users).Find(user.ID).Update(nil, ch))
+		assertCanceled(t, repo.From(users).Find(user.ID).Delete())
+		assertCanceled(t, repo.Transaction(queryAll(t)))
+	})
+}
+
+func assertCanceled(t *testing.T, err error) {
+	assert.NotNil(t, err)
+	if e, ok := err.(errors.Error); assert.True(t, ok) {
+		assert.True(t, e.CanceledError())
+	}
+}
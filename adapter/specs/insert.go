@@ -110,3 +110,86 @@ func InsertSet(t *testing.T, repo grimoire.Repo) {
 		})
 	}
 }
+
+// InsertAssoc tests insert specifications with associations.
+func InsertAssoc(t *testing.T, repo grimoire.Repo) {
+	t.Run("InsertAssoc|HasMany", func(t *testing.T) {
+		user := User{}
+		ch := changeUser(user, params)
+		assert.Nil(t, ch.Error())
+
+		assert.Nil(t, repo.From(users).Insert(&user, ch))
+		assert.NotEqual(t, int64(0), user.ID)
+		assert.Equal(t, 2, len(user.Addresses))
+
+		for _, address := range user.Addresses {
+			assert.Equal(t, user.ID, *address.UserID)
+		}
+	})
+
+	t.Run("InsertAssoc|BelongsTo", func(t *testing.T) {
+		address := Address{}
+		ch := changeset.Cast(address, map[string]interface{}{
+			"address": "insert assoc",
+			"user": map[string]interface{}{
+				"name":   "insert assoc",
+				"gender": "male",
+				"age":    20,
+			},
+		}, []string{"address"})
+		changeset.CastAssoc(ch, "user", changeUser)
+		assert.Nil(t, ch.Error())
+
+		assert.Nil(t, repo.From(addresses).Insert(&address, ch))
+		assert.NotNil(t, address.User)
+		assert.Equal(t, "insert assoc", address.User.Name)
+		assert.Equal(t, address.User.ID, *address.UserID)
+	})
+}
+
+// InsertOnConflict tests insert specifications with conflict resolution.
+func InsertOnConflict(t *testing.T, repo grimoire.Repo) {
+	user := User{Name: "insert conflict", Gender: "male", Age: 10}
+	assert.Nil(t, repo.From(users).Save(&user))
+
+	fields := []string{"name", "gender", "age"}
+
+	t.Run("InsertOnConflict|DoUpdate", func(t *testing.T) {
+		result := User{}
+		ch := changeset.Cast(User{}, map[string]interface{}{"name": "upsert", "gender": "male", "age": 20}, fields)
+
+		assert.Nil(t, repo.From(users).Set("id", user.ID).OnConflict("id").DoUpdate().Insert(&result, ch))
+		assert.Equal(t, user.ID, result.ID)
+		assert.Equal(t, "upsert", result.Name)
+		assert.Equal(t, 20, result.Age)
+	})
+
+	t.Run("InsertOnConflict|DoUpdateFields", func(t *testing.T) {
+		result := User{}
+		ch := changeset.Cast(User{}, map[string]interface{}{"name": "upsert fields", "gender": "male", "age": 30}, fields)
+
+		assert.Nil(t, repo.From(users).Set("id", user.ID).OnConflict("id").DoUpdate("age").Insert(&result, ch))
+		assert.Equal(t, user.ID, result.ID)
+		assert.Equal(t, "upsert", result.Name)
+		assert.Equal(t, 30, result.Age)
+	})
+
+	t.Run("InsertOnConflict|DoNothing", func(t *testing.T) {
+		result := User{}
+		ch := changeset.Cast(User{}, map[string]interface{}{"name": "ignored", "gender": "male", "age": 40}, fields)
+
+		assert.Nil(t, repo.From(users).Set("id", user.ID).OnConflict("id").DoNothing().Insert(nil, ch))
+		assert.Nil(t, repo.From(users).Find(user.ID).One(&result))
+		assert.Equal(t, "upsert", result.Name)
+		assert.Equal(t, 30, result.Age)
+	})
+
+	t.Run("InsertOnConflict|Inserted", func(t *testing.T) {
+		result := User{}
+		ch := changeset.Cast(User{}, map[string]interface{}{"name": "upsert insert", "gender": "male", "age": 10}, fields)
+
+		assert.Nil(t, repo.From(users).OnConflict("id").DoUpdate().Insert(&result, ch))
+		assert.NotEqual(t, user.ID, result.ID)
+		assert.Equal(t, "upsert insert", result.Name)
+	})
+}
@@ -0,0 +1,60 @@
+package specs
+
+import (
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/adapter/sql"
+	"github.com/Fs02/grimoire/c"
+	"github.com/stretchr/testify/assert"
+)
+
+// Preload tests preload specifications.
+func Preload(t *testing.T, repo grimoire.Repo) {
+	// preparte tests data
+	user := User{Name: "preload", Gender: "male", Age: 10}
+	assert.Nil(t, repo.From(users).Save(&user))
+	assert.Nil(t, repo.From(users).Save(&User{Name: "preload", Gender: "female", Age: 20}))
+
+	assert.Nil(t, repo.From(addresses).Save(&Address{Address: "preload1", UserID: &user.ID}))
+	assert.Nil(t, repo.From(addresses).Save(&Address{Address: "preload2", UserID: &user.ID}))
+
+	tests := []grimoire.Query{
+		repo.From(users).Find(user.ID).Preload("addresses"),
+		repo.From(users).Where(c.Eq(name, "preload")).Preload("addresses"),
+		repo.From(users).Where(c.Eq(name, "preload")).Preload("addresses.user"),
+	}
+
+	for _, query := range tests {
+		statement, _ := sql.NewBuilder("?", false).Find(query)
+		t.Run("Preload|"+statement, func(t *testing.T) {
+			var result []User
+			assert.Nil(t, query.All(&result))
+			assert.NotEqual(t, 0, len(result))
+
+			for _, r := range result {
+				if r.ID == user.ID {
+					assert.Equal(t, 2, len(r.Addresses))
+				} else {
+					assert.Equal(t, 0, len(r.Addresses))
+				}
+
+				for _, a := range r.Addresses {
+					assert.Equal(t, user.ID, *a.UserID)
+
+					if query.PreloadFields[0] == "addresses.user" {
+						assert.NotNil(t, a.User)
+						assert.Equal(t, user.ID, a.User.ID)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("Preload|BelongsTo", func(t *testing.T) {
+		var result Address
+		assert.Nil(t, repo.From(addresses).Where(c.Eq(c.I("user_id"), user.ID)).Preload("user").One(&result))
+		assert.NotNil(t, result.User)
+		assert.Equal(t, user.ID, result.User.ID)
+	})
+}
@@ -46,6 +46,8 @@ func Query(t *testing.T, repo grimoire.Repo) {
 		repo.From(users).Where(c.Like(name, "name%")),
 		repo.From(users).Where(c.NotLike(name, "noname%")),
 		repo.From(users).Where(c.Fragment("id > 0")),
+		repo.From(users).Where(c.Fragment("age > ?", 5), c.Eq(name, "name1")),
+		repo.From(users).Where(c.Fragment("age > :min_age", map[string]interface{}{"min_age": 5}), c.Eq(name, "name1")),
 		repo.From(users).Where(c.Not(c.Eq(id, 1), c.Eq(name, "name1"), c.Eq(age, 10))),
 		repo.From(users).Order(c.Asc(name)),
 		repo.From(users).Order(c.Desc(name)),
@@ -119,3 +121,134 @@ func QueryNotFound(t *testing.T, repo grimoire.Repo) {
 		assert.True(t, err.(errors.Error).NotFoundError())
 	})
 }
+
+// QueryLock tests query specifications with row locking.
+func QueryLock(t *testing.T, repo grimoire.Repo) {
+	user := User{}
+	repo.From(users).MustSave(&user)
+
+	t.Run("Lock|ForUpdate", func(t *testing.T) {
+		result := User{}
+
+		err := repo.Transaction(func(repo grimoire.Repo) error {
+			return repo.From(users).Lock(c.ForUpdate()).Find(user.ID).One(&result)
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, user.ID, result.ID)
+	})
+
+	t.Run("Lock|ForShare", func(t *testing.T) {
+		result := []User{}
+
+		err := repo.Transaction(func(repo grimoire.Repo) error {
+			return repo.From(users).Lock(c.ForShare()).Where(c.Eq(id, user.ID)).All(&result)
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(result))
+	})
+
+	t.Run("Lock|OutsideTransaction", func(t *testing.T) {
+		result := User{}
+		assert.NotNil(t, repo.From(users).Lock(c.ForUpdate()).Find(user.ID).One(&result))
+	})
+}
+
+// Raw tests raw query specifications.
+func Raw(t *testing.T, repo grimoire.Repo) {
+	user := User{Name: "raw", Gender: "male", Age: 10}
+	repo.From(users).MustSave(&user)
+
+	t.Run("Raw|One", func(t *testing.T) {
+		result := User{}
+		assert.Nil(t, repo.Raw("SELECT * FROM users WHERE id=? AND name=?;", user.ID, "raw").One(&result))
+		assert.Equal(t, user.ID, result.ID)
+		assert.Equal(t, "raw", result.Name)
+	})
+
+	t.Run("Raw|All", func(t *testing.T) {
+		result := []User{}
+		assert.Nil(t, repo.Raw("SELECT * FROM users WHERE name=?;", "raw").All(&result))
+		assert.NotEqual(t, 0, len(result))
+	})
+
+	t.Run("Raw|NotFound", func(t *testing.T) {
+		result := User{}
+		err := repo.Raw("SELECT * FROM users WHERE id=?;", 0).One(&result)
+		assert.NotNil(t, err)
+		assert.True(t, err.(errors.Error).NotFoundError())
+	})
+
+	t.Run("Raw|Exec", func(t *testing.T) {
+		affected, err := repo.Raw("UPDATE users SET note=? WHERE id=?;", "raw note", user.ID).Exec()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), affected)
+	})
+
+	t.Run("Raw|Transaction", func(t *testing.T) {
+		err := repo.Transaction(func(repo grimoire.Repo) error {
+			repo.Raw("UPDATE users SET note=? WHERE id=?;", "rollback", user.ID).MustExec()
+			return errors.NotFoundError("let's rollback")
+		})
+		assert.NotNil(t, err)
+
+		result := User{}
+		repo.Raw("SELECT * FROM users WHERE id=?;", user.ID).MustOne(&result)
+		assert.Equal(t, "raw note", *result.Note)
+	})
+}
+
+// Iterate tests iterate and batch query specifications.
+func Iterate(t *testing.T, repo grimoire.Repo) {
+	for i := 0; i < 5; i++ {
+		repo.From(users).MustSave(&User{Name: "iterate", Gender: "male", Age: 10})
+	}
+
+	t.Run("Iterate|Each", func(t *testing.T) {
+		user := User{}
+		count := 0
+
+		err := repo.From(users).Where(c.Eq(name, "iterate")).Each(&user, func() error {
+			assert.Equal(t, "iterate", user.Name)
+			count++
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 5, count)
+	})
+
+	t.Run("Iterate|Each|Error", func(t *testing.T) {
+		user := User{}
+		count := 0
+
+		err := repo.From(users).Where(c.Eq(name, "iterate")).Each(&user, func() error {
+			count++
+			return errors.NotFoundError("stop")
+		})
+
+		assert.Equal(t, errors.NotFoundError("stop"), err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Iterate|FindInBatches", func(t *testing.T) {
+		result := []User{}
+		ids := map[int64]bool{}
+		batches := 0
+
+		err := repo.From(users).Where(c.Eq(name, "iterate")).FindInBatches(&result, 2, func() error {
+			assert.True(t, len(result) <= 2)
+			for _, user := range result {
+				ids[user.ID] = true
+			}
+
+			batches++
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(ids))
+		assert.Equal(t, 3, batches)
+	})
+}
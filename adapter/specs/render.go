@@ -0,0 +1,61 @@
+package specs
+
+import (
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
+	"github.com/stretchr/testify/assert"
+)
+
+// Render tests that statement returned by ToSQL is the same as the executed statement.
+func Render(t *testing.T, repo grimoire.Repo) {
+	var event grimoire.QueryEvent
+	repo.SetInstrumenter(grimoire.InstrumenterFunc(func(e grimoire.QueryEvent) {
+		event = e
+	}))
+
+	user := User{Name: "render", Gender: "male", Age: 10}
+	assert.Nil(t, repo.From(users).Save(&user))
+
+	tests := []struct {
+		op    string
+		query grimoire.Query
+		exec  func(query grimoire.Query) error
+	}{
+		{"count", repo.From(users).Where(c.Eq(name, "render")), func(query grimoire.Query) error {
+			_, err := query.Count()
+			return err
+		}},
+		{"all", repo.From(users).Where(c.Eq(id, user.ID)).Limit(1), func(query grimoire.Query) error {
+			return query.All(&[]User{})
+		}},
+		{"insert", repo.From(users).Set("name", "render").Set("age", 20), func(query grimoire.Query) error {
+			return query.Insert(nil)
+		}},
+		{"insert", repo.From(users).Set("id", user.ID).Set("name", "render").OnConflict("id").DoUpdate(), func(query grimoire.Query) error {
+			return query.Insert(nil)
+		}},
+		{"insert", repo.From(users).Set("id", user.ID).Set("name", "render").OnConflict("id").DoNothing(), func(query grimoire.Query) error {
+			return query.Insert(nil)
+		}},
+		{"update", repo.From(users).Where(c.Eq(name, "render")).Set("note", "render"), func(query grimoire.Query) error {
+			return query.Update(nil)
+		}},
+		{"delete", repo.From(users).Where(c.Eq(name, "render")), func(query grimoire.Query) error {
+			return query.Delete()
+		}},
+	}
+
+	for _, test := range tests {
+		statement, args, err := test.query.ToSQL(test.op)
+		assert.Nil(t, err)
+
+		t.Run("Render|"+statement, func(t *testing.T) {
+			event = grimoire.QueryEvent{}
+			assert.Nil(t, test.exec(test.query))
+			assert.Equal(t, event.Statement, statement)
+			assert.Equal(t, event.Args, args)
+		})
+	}
+}
@@ -22,6 +22,7 @@ type User struct {
 type Address struct {
 	ID        int64
 	UserID    *int64
+	User      *User
 	Address   string
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -0,0 +1,174 @@
+package specs
+
+import (
+	"testing"
+
+	"github.com/Fs02/grimoire"
+)
+
+// Capability defines a group of specifications that can be skipped by adapter that doesn't support it.
+type Capability string
+
+// Capabilities that are covered by the suite.
+const (
+	CapabilityQuery         Capability = "Query"
+	CapabilityJoin          Capability = "Join"
+	CapabilityLock          Capability = "Lock"
+	CapabilityRaw           Capability = "Raw"
+	CapabilityIterate       Capability = "Iterate"
+	CapabilityPreload       Capability = "Preload"
+	CapabilityCount         Capability = "Count"
+	CapabilityInsert        Capability = "Insert"
+	CapabilityUpsert        Capability = "Upsert"
+	CapabilityAssoc         Capability = "Assoc"
+	CapabilityUpdate        Capability = "Update"
+	CapabilitySave          Capability = "Save"
+	CapabilityDelete        Capability = "Delete"
+	CapabilityTransaction   Capability = "Transaction"
+	CapabilityAdapter       Capability = "Adapter"
+	CapabilityRender        Capability = "Render"
+	CapabilityErrors        Capability = "Errors"
+	CapabilityConcurrency   Capability = "Concurrency"
+	CapabilitySerialization Capability = "Serialization"
+)
+
+// Capabilities lists every capability in the order they are run by the suite.
+var Capabilities = []Capability{
+	CapabilityQuery,
+	CapabilityJoin,
+	CapabilityLock,
+	CapabilityRaw,
+	CapabilityIterate,
+	CapabilityPreload,
+	CapabilityCount,
+	CapabilityInsert,
+	CapabilityUpsert,
+	CapabilityAssoc,
+	CapabilityUpdate,
+	CapabilitySave,
+	CapabilityDelete,
+	CapabilityTransaction,
+	CapabilityAdapter,
+	CapabilityRender,
+	CapabilityErrors,
+	CapabilityConcurrency,
+	CapabilitySerialization,
+}
+
+// Suite is a conformance suite that can be run against any adapter.
+// Specifications use users and addresses collection, their fields are described by User and Address struct.
+//
+//	func TestSpecs(t *testing.T) {
+//		specs.Suite{
+//			Adapter: adapter,
+//			Setup:   createTables,
+//			Skip:    []specs.Capability{specs.CapabilitySerialization},
+//		}.Run(t)
+//	}
+type Suite struct {
+	// Adapter to be tested.
+	Adapter grimoire.Adapter
+
+	// Setup is called before specifications are run, it should (re)create users and addresses collection.
+	// Setup can be left nil for schemaless adapter.
+	Setup func(repo grimoire.Repo) error
+
+	// Skip lists capabilities that aren't supported by the adapter.
+	Skip []Capability
+}
+
+// Run runs every specifications of supported capabilities.
+// Each capability is run as a subtest, and the result of each capability is reported when all of them are done.
+func (suite Suite) Run(t *testing.T) {
+	repo := grimoire.New(suite.Adapter)
+
+	if suite.Setup != nil {
+		if err := suite.Setup(repo); err != nil {
+			t.Fatalf("setup failed: %s", err)
+		}
+	}
+
+	report := make(map[Capability]string, len(Capabilities))
+	for _, capability := range Capabilities {
+		if suite.skipped(capability) {
+			report[capability] = "skip"
+			t.Run(string(capability), func(t *testing.T) {
+				t.Skip("not supported by adapter")
+			})
+			continue
+		}
+
+		report[capability] = "fail"
+		if t.Run(string(capability), suite.spec(capability, repo)) {
+			report[capability] = "pass"
+		}
+	}
+
+	for _, capability := range Capabilities {
+		t.Logf("%-14s %s", capability, report[capability])
+	}
+}
+
+func (suite Suite) skipped(capability Capability) bool {
+	for _, skip := range suite.Skip {
+		if skip == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (suite Suite) spec(capability Capability, repo grimoire.Repo) func(t *testing.T) {
+	return func(t *testing.T) {
+		switch capability {
+		case CapabilityQuery:
+			Query(t, repo)
+			QueryNotFound(t, repo)
+		case CapabilityJoin:
+			QueryJoin(t, repo)
+		case CapabilityLock:
+			QueryLock(t, repo)
+		case CapabilityRaw:
+			Raw(t, repo)
+		case CapabilityIterate:
+			Iterate(t, repo)
+		case CapabilityPreload:
+			Preload(t, repo)
+		case CapabilityCount:
+			Count(t, repo)
+		case CapabilityInsert:
+			Insert(t, repo)
+			InsertAll(t, repo)
+			InsertSet(t, repo)
+		case CapabilityUpsert:
+			InsertOnConflict(t, repo)
+		case CapabilityAssoc:
+			InsertAssoc(t, repo)
+			UpdateAssoc(t, repo)
+		case CapabilityUpdate:
+			Update(t, repo)
+			UpdateWhere(t, repo)
+			UpdateSet(t, repo)
+		case CapabilitySave:
+			SaveInsert(t, repo)
+			SaveInsertAll(t, repo)
+			SaveUpdate(t, repo)
+		case CapabilityDelete:
+			Delete(t, repo)
+		case CapabilityTransaction:
+			Transaction(t, repo)
+			TransactionWith(t, repo)
+		case CapabilityAdapter:
+			Adapter(t, suite.Adapter)
+		case CapabilityRender:
+			Render(t, repo)
+		case CapabilityErrors:
+			Errors(t, repo)
+		case CapabilityConcurrency:
+			Concurrency(t, repo)
+		case CapabilitySerialization:
+			Serialization(t, repo)
+		}
+	}
+}
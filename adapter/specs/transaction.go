@@ -1,7 +1,9 @@
 package specs
 
 import (
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/c"
@@ -37,6 +39,8 @@ func Transaction(t *testing.T, repo grimoire.Repo) {
 		{"InsertWithAssocError", insertWithAssocError, errors.NotFoundError("let's rollback")},
 		{"InsertWithAssocPanic", insertWithAssocPanic, errors.NotFoundError("let's rollback")},
 		{"ReplaceAssoc", replaceAssoc, nil},
+		{"NestedRollback", nestedRollback, nil},
+		{"NestedError", nestedError, errors.NotFoundError("let's rollback")},
 	}
 
 	for _, tt := range tests {
@@ -46,6 +50,48 @@ func Transaction(t *testing.T, repo grimoire.Repo) {
 	}
 }
 
+// TransactionWith tests transaction specifications with options.
+func TransactionWith(t *testing.T, repo grimoire.Repo) {
+	tests := []struct {
+		name string
+		opts grimoire.TxOptions
+	}{
+		{"Serializable", grimoire.TxOptions{Isolation: sql.LevelSerializable}},
+		{"ReadOnly", grimoire.TxOptions{ReadOnly: true}},
+		{"Retry", grimoire.TxOptions{Retry: grimoire.Retry{MaxRetries: 3, Backoff: time.Millisecond}}},
+	}
+
+	for _, tt := range tests {
+		t.Run("TransactionWith|"+tt.name, func(t *testing.T) {
+			assert.Nil(t, repo.TransactionWith(tt.opts, queryAll(t)))
+		})
+	}
+}
+
+// Serialization tests that conflicting serializable transactions are reported as serialization error.
+// The first transaction reads users that are then inserted by the second transaction, and inserts a user that would have been read by the second transaction.
+func Serialization(t *testing.T, repo grimoire.Repo) {
+	opts := grimoire.TxOptions{Isolation: sql.LevelSerializable}
+
+	t.Run("Serialization|Conflict", func(t *testing.T) {
+		err := repo.TransactionWith(opts, func(tx grimoire.Repo) error {
+			tx.From(users).Where(c.Eq(name, "serialization")).MustCount()
+
+			assert.Nil(t, repo.TransactionWith(opts, func(tx grimoire.Repo) error {
+				tx.From(users).Where(c.Eq(name, "serialization")).MustCount()
+				return tx.From(users).Save(&User{Name: "serialization", Age: 1})
+			}))
+
+			return tx.From(users).Save(&User{Name: "serialization", Age: 2})
+		})
+
+		assert.NotNil(t, err)
+		if e, ok := err.(errors.Error); assert.True(t, ok) {
+			assert.True(t, e.SerializationError())
+		}
+	})
+}
+
 func queryAll(t *testing.T) func(repo grimoire.Repo) error {
 	users := []User{}
 
@@ -125,6 +171,50 @@ func replaceAssoc(t *testing.T) func(repo grimoire.Repo) error {
 	}
 }
 
+func nestedRollback(t *testing.T) func(repo grimoire.Repo) error {
+	user := User{}
+
+	ch := changeUser(user, params)
+	assert.Nil(t, ch.Error())
+
+	// transaction block
+	return func(repo grimoire.Repo) error {
+		repo.From("users").MustInsert(&user, ch)
+
+		// inner transaction should only rollback its own changes
+		err := repo.Transaction(func(repo grimoire.Repo) error {
+			repo.From("users").Find(user.ID).Set("name", "nested").MustUpdate(nil)
+			return errors.NotFoundError("let's rollback")
+		})
+		assert.Equal(t, errors.NotFoundError("let's rollback"), err)
+
+		result := User{}
+		repo.From("users").Find(user.ID).MustOne(&result)
+		assert.Equal(t, user.Name, result.Name)
+
+		return nil
+	}
+}
+
+func nestedError(t *testing.T) func(repo grimoire.Repo) error {
+	user := User{}
+
+	ch := changeUser(user, params)
+	assert.Nil(t, ch.Error())
+
+	// transaction block
+	return func(repo grimoire.Repo) error {
+		err := repo.Transaction(func(repo grimoire.Repo) error {
+			repo.From("users").MustInsert(&user, ch)
+			return nil
+		})
+		assert.Nil(t, err)
+
+		// should rollback changes made by released inner transaction
+		return errors.NotFoundError("let's rollback")
+	}
+}
+
 func changeUser(user interface{}, params map[string]interface{}) *changeset.Changeset {
 	ch := changeset.Cast(user, params, []string{
 		"name",
@@ -137,6 +227,6 @@ func changeUser(user interface{}, params map[string]interface{}) *changeset.Chan
 }
 
 func changeAddress(address interface{}, params map[string]interface{}) *changeset.Changeset {
-	ch := changeset.Cast(address, params, []string{"address"})
+	ch := changeset.Cast(address, params, []string{"id", "address"})
 	return ch
 }
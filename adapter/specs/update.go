@@ -103,3 +103,51 @@ func UpdateSet(t *testing.T, repo grimoire.Repo) {
 		})
 	}
 }
+
+// UpdateAssoc tests update specifications with associations.
+func UpdateAssoc(t *testing.T, repo grimoire.Repo) {
+	user := User{}
+	assert.Nil(t, repo.From(users).Insert(&user, changeUser(user, params)))
+	assert.Equal(t, 2, len(user.Addresses))
+
+	t.Run("UpdateAssoc|HasMany", func(t *testing.T) {
+		ch := changeUser(user, map[string]interface{}{
+			"name": "update assoc",
+			"addresses": []map[string]interface{}{
+				{"address": "update assoc"},
+			},
+		})
+		assert.Nil(t, ch.Error())
+
+		assert.Nil(t, repo.From(users).Find(user.ID).Update(&user, ch))
+		assert.Equal(t, "update assoc", user.Name)
+		assert.Equal(t, 1, len(user.Addresses))
+		assert.Equal(t, "update assoc", user.Addresses[0].Address)
+
+		count, err := repo.From(addresses).Where(c.Eq(c.I("user_id"), user.ID)).Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("UpdateAssoc|HasManyExisting", func(t *testing.T) {
+		existing := user.Addresses[0]
+		ch := changeUser(user, map[string]interface{}{
+			"addresses": []map[string]interface{}{
+				{"id": existing.ID, "address": "update existing"},
+				{"address": "insert new"},
+			},
+		})
+		assert.Nil(t, ch.Error())
+
+		assert.Nil(t, repo.From(users).Find(user.ID).Update(&user, ch))
+		assert.Equal(t, 2, len(user.Addresses))
+
+		var address Address
+		assert.Nil(t, repo.From(addresses).Find(existing.ID).One(&address))
+		assert.Equal(t, "update existing", address.Address)
+
+		count, err := repo.From(addresses).Where(c.Eq(c.I("user_id"), user.ID)).Count()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
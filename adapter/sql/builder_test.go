@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
@@ -9,6 +10,23 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// clause returns the statement written by build without the leading space, along with its arguments.
+func clause(build func(buffer *bytes.Buffer) []interface{}) (string, []interface{}) {
+	var buffer bytes.Buffer
+	args := build(&buffer)
+	return strings.TrimPrefix(buffer.String(), " "), args
+}
+
+// write returns the statement written by build without the leading space.
+func write(build func(buffer *bytes.Buffer)) string {
+	qs, _ := clause(func(buffer *bytes.Buffer) []interface{} {
+		build(buffer)
+		return nil
+	})
+
+	return qs
+}
+
 func TestBuilderFind(t *testing.T) {
 	users := grimoire.Query{
 		Collection: "users",
@@ -60,6 +78,11 @@ func TestBuilderFind(t *testing.T) {
 			nil,
 			users.Offset(10).Limit(10),
 		},
+		{
+			"SELECT * FROM users WHERE id=? LIMIT 10 OFFSET 10 FOR UPDATE;",
+			[]interface{}{10},
+			users.Where(Eq(I("id"), 10)).Offset(10).Limit(10).Lock(ForUpdate()),
+		},
 	}
 
 	for _, tt := range tests {
@@ -147,16 +170,12 @@ func TestBuilderInsert(t *testing.T) {
 	assert.Equal(t, "INSERT INTO users (name) VALUES ($1) RETURNING id;", qs)
 	assert.Equal(t, args, qargs)
 
-	// test for multiple changes since map is randomly ordered
+	// columns are sorted, so multiple changes always generates the same statement
 	changes["age"] = 10
 	changes["agree"] = true
-	qs, _ = NewBuilder("?", false).Insert("users", changes)
-
-	assert.True(t, strings.HasPrefix(qs, "INSERT INTO users ("))
-	assert.True(t, strings.Contains(qs, "name"))
-	assert.True(t, strings.Contains(qs, "age"))
-	assert.True(t, strings.Contains(qs, "agree"))
-	assert.True(t, strings.HasSuffix(qs, ";"))
+	qs, qargs = NewBuilder("?", false).Insert("users", changes)
+	assert.Equal(t, "INSERT INTO users (age,agree,name) VALUES (?,?,?);", qs)
+	assert.Equal(t, []interface{}{10, true, "foo"}, qargs)
 }
 
 func TestBuilderInsertAll(t *testing.T) {
@@ -204,6 +223,89 @@ func TestBuilderInsertAll(t *testing.T) {
 	assert.Equal(t, []interface{}{"foo", 10, "zoo", 12, "boo", 20}, args)
 }
 
+func TestBuilderInsertOnConflict(t *testing.T) {
+	changes := map[string]interface{}{
+		"email": "foo@bar.com",
+	}
+	args := []interface{}{"foo@bar.com"}
+
+	tests := []struct {
+		QueryString  string
+		DuplicateKey bool
+		Returning    string
+		OnConflict   OnConflict
+	}{
+		{
+			"INSERT INTO users (email) VALUES (?) ON CONFLICT (email) DO NOTHING;",
+			false,
+			"",
+			OnConflict{Fields: []string{"email"}, Action: ConflictIgnore},
+		},
+		{
+			"INSERT INTO users (email) VALUES (?) ON CONFLICT DO NOTHING;",
+			false,
+			"",
+			OnConflict{Action: ConflictIgnore},
+		},
+		{
+			"INSERT INTO users (email) VALUES (?) ON CONFLICT (email) DO UPDATE SET name=EXCLUDED.name,age=EXCLUDED.age RETURNING id;",
+			false,
+			"id",
+			OnConflict{Fields: []string{"email"}, Action: ConflictUpdate, UpdateFields: []string{"name", "age"}},
+		},
+		{
+			"INSERT INTO users (email) VALUES (?) ON CONFLICT (email) DO NOTHING;",
+			false,
+			"",
+			OnConflict{Fields: []string{"email"}, Action: ConflictUpdate},
+		},
+		{
+			"INSERT INTO users (email) VALUES (?) ON DUPLICATE KEY UPDATE id=id;",
+			true,
+			"",
+			OnConflict{Fields: []string{"email"}, Action: ConflictIgnore},
+		},
+		{
+			"INSERT INTO users (email) VALUES (?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id),name=VALUES(name),age=VALUES(age);",
+			true,
+			"",
+			OnConflict{Fields: []string{"email"}, Action: ConflictUpdate, UpdateFields: []string{"name", "age"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.QueryString, func(t *testing.T) {
+			builder := NewBuilder("?", false).Returning(tt.Returning).OnConflict(tt.OnConflict)
+			builder.DuplicateKey = tt.DuplicateKey
+
+			qs, qargs := builder.Insert("users", changes)
+			assert.Equal(t, tt.QueryString, qs)
+			assert.Equal(t, args, qargs)
+		})
+	}
+}
+
+func TestBuilderInsertAllOnConflict(t *testing.T) {
+	fields := []string{"email", "name"}
+	allchanges := []map[string]interface{}{
+		{"email": "foo@bar.com", "name": "foo"},
+		{"email": "boo@bar.com", "name": "boo"},
+	}
+
+	onConflict := OnConflict{Fields: []string{"email"}, Action: ConflictUpdate, UpdateFields: []string{"name"}}
+
+	statement, args := NewBuilder("$", true).Returning("id").OnConflict(onConflict).InsertAll("users", fields, allchanges)
+	assert.Equal(t, "INSERT INTO users (email,name) VALUES ($1,$2),($3,$4) ON CONFLICT (email) DO UPDATE SET name=EXCLUDED.name RETURNING id;", statement)
+	assert.Equal(t, []interface{}{"foo@bar.com", "foo", "boo@bar.com", "boo"}, args)
+
+	builder := NewBuilder("?", false).OnConflict(onConflict)
+	builder.DuplicateKey = true
+
+	statement, args = builder.InsertAll("users", fields, allchanges)
+	assert.Equal(t, "INSERT INTO users (email,name) VALUES (?,?),(?,?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id),name=VALUES(name);", statement)
+	assert.Equal(t, []interface{}{"foo@bar.com", "foo", "boo@bar.com", "boo"}, args)
+}
+
 func TestBuilderUdate(t *testing.T) {
 	changes := map[string]interface{}{
 		"name": "foo",
@@ -230,16 +332,12 @@ func TestBuilderUdate(t *testing.T) {
 	assert.Equal(t, "UPDATE users SET name=$1 WHERE id=$2;", qs)
 	assert.Equal(t, args, qargs)
 
-	// test for multiple changes since map is randomly ordered
+	// columns are sorted, so multiple changes always generates the same statement
 	changes["age"] = 10
 	changes["agree"] = true
-	qs, _ = NewBuilder("$", true).Update("users", changes, And())
-
-	assert.True(t, strings.HasPrefix(qs, "UPDATE users SET "))
-	assert.True(t, strings.Contains(qs, "name"))
-	assert.True(t, strings.Contains(qs, "age"))
-	assert.True(t, strings.Contains(qs, "agree"))
-	assert.True(t, strings.HasSuffix(qs, ";"))
+	qs, qargs = NewBuilder("$", true).Update("users", changes, Eq(I("id"), 1))
+	assert.Equal(t, "UPDATE users SET age=$1,agree=$2,name=$3 WHERE id=$4;", qs)
+	assert.Equal(t, []interface{}{10, true, "foo", 1}, qargs)
 }
 
 func TestBuilderDelete(t *testing.T) {
@@ -257,15 +355,94 @@ func TestBuilderDelete(t *testing.T) {
 }
 
 func TestBuilderSelect(t *testing.T) {
-	assert.Equal(t, "SELECT *", NewBuilder("?", false).fields(false, "*"))
-	assert.Equal(t, "SELECT id, name", NewBuilder("?", false).fields(false, "id", "name"))
+	qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+		return NewBuilder("?", false).fields(buffer, nil, false, []string{"*"}, nil)
+	})
+	assert.Equal(t, "SELECT *", qs)
+	assert.Nil(t, args)
+
+	qs, _ = clause(func(buffer *bytes.Buffer) []interface{} {
+		return NewBuilder("?", false).fields(buffer, nil, false, []string{"id", "name"}, nil)
+	})
+	assert.Equal(t, "SELECT id, name", qs)
+
+	qs, _ = clause(func(buffer *bytes.Buffer) []interface{} {
+		return NewBuilder("?", false).fields(buffer, nil, true, []string{"*"}, nil)
+	})
+	assert.Equal(t, "SELECT DISTINCT *", qs)
+
+	qs, _ = clause(func(buffer *bytes.Buffer) []interface{} {
+		return NewBuilder("?", false).fields(buffer, nil, true, []string{"id", "name"}, nil)
+	})
+	assert.Equal(t, "SELECT DISTINCT id, name", qs)
+
+	qs, args = clause(func(buffer *bytes.Buffer) []interface{} {
+		return NewBuilder("$", true).fields(buffer, nil, false, []string{"id", "age > ? AS adult"}, []interface{}{17})
+	})
+	assert.Equal(t, "SELECT id, age > $1 AS adult", qs)
+	assert.Equal(t, []interface{}{17}, args)
+}
+
+func TestBuilderFragment(t *testing.T) {
+	tests := []struct {
+		QueryString string
+		Args        []interface{}
+		Expr        string
+		Values      []interface{}
+	}{
+		{"age > 10", nil, "age > 10", nil},
+		{"age > $1 AND age < $2", []interface{}{10, 20}, "age > ? AND age < ?", []interface{}{10, 20}},
+		{"data ? $1", []interface{}{"key"}, "data ?? ?", []interface{}{"key"}},
+		{"note = '?' AND \"what?\" = $1", []interface{}{"a"}, "note = '?' AND \"what?\" = ?", []interface{}{"a"}},
+		{"created_at::date = $1", []interface{}{"2020-01-01"}, "created_at::date = ?", []interface{}{"2020-01-01"}},
+		{
+			"age > $1 AND age < $2 AND age <> $3",
+			[]interface{}{10, 20, 10},
+			"age > :min_age AND age < :max_age AND age <> :min_age",
+			[]interface{}{map[string]interface{}{"min_age": 10, "max_age": 20}},
+		},
+		{
+			"created_at::date = $1 AND note = ':note' AND name = :name AND data ? 'key'",
+			[]interface{}{"2020-01-01"},
+			"created_at::date = :date AND note = ':note' AND name = :name AND data ? 'key'",
+			[]interface{}{map[string]interface{}{"date": "2020-01-01"}},
+		},
+		{"age > 10", nil, "age > 10", []interface{}{map[string]interface{}{"min_age": 10}}},
+	}
 
-	assert.Equal(t, "SELECT DISTINCT *", NewBuilder("?", false).fields(true, "*"))
-	assert.Equal(t, "SELECT DISTINCT id, name", NewBuilder("?", false).fields(true, "id", "name"))
+	for _, tt := range tests {
+		t.Run(tt.QueryString, func(t *testing.T) {
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("$", true).fragment(buffer, nil, tt.Expr, tt.Values)
+			})
+			assert.Equal(t, tt.QueryString, qs)
+			assert.Equal(t, tt.Args, args)
+		})
+	}
+}
+
+func TestBuilderFragmentOrdinal(t *testing.T) {
+	users := grimoire.Query{
+		Collection: "users",
+		Fields:     []string{"*"},
+	}
+
+	query := users.
+		SelectFragment("age > ? AS adult", 17).
+		Join("addresses", Fragment("addresses.user_id = users.id AND addresses.city = ?", "Bandung")).
+		Where(Fragment("age > :min_age", map[string]interface{}{"min_age": 10}), Eq(I("name"), "Alice")).
+		Group("age").
+		Having(Fragment("COUNT(*) > ?", 1))
+
+	qs, args := NewBuilder("$", true).Find(query)
+	assert.Equal(t, "SELECT *, age > $1 AS adult FROM users JOIN addresses ON addresses.user_id = users.id AND addresses.city = $2 WHERE (age > $3 AND name=$4) GROUP BY age HAVING COUNT(*) > $5;", qs)
+	assert.Equal(t, []interface{}{17, "Bandung", 10, "Alice", 1}, args)
 }
 
 func TestBuilderFrom(t *testing.T) {
-	assert.Equal(t, "FROM users", NewBuilder("?", false).from("users"))
+	assert.Equal(t, "FROM users", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).from(buffer, "users")
+	}))
 }
 
 func TestBuilderJoin(t *testing.T) {
@@ -299,7 +476,9 @@ func TestBuilderJoin(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("?", false).join(tt.JoinClause...)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("?", false).join(buffer, nil, tt.JoinClause...)
+			})
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
@@ -331,7 +510,9 @@ func TestBuilderWhere(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("?", false).where(tt.Condition)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("?", false).where(buffer, nil, tt.Condition)
+			})
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
@@ -363,7 +544,9 @@ func TestBuilderWhereOrdinal(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("$", true).where(tt.Condition)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("$", true).where(buffer, nil, tt.Condition)
+			})
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
@@ -371,9 +554,15 @@ func TestBuilderWhereOrdinal(t *testing.T) {
 }
 
 func TestBuilderGroupBy(t *testing.T) {
-	assert.Equal(t, "", NewBuilder("?", false).groupBy())
-	assert.Equal(t, "GROUP BY city", NewBuilder("?", false).groupBy("city"))
-	assert.Equal(t, "GROUP BY city, nation", NewBuilder("?", false).groupBy("city", "nation"))
+	assert.Equal(t, "", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).groupBy(buffer)
+	}))
+	assert.Equal(t, "GROUP BY city", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).groupBy(buffer, "city")
+	}))
+	assert.Equal(t, "GROUP BY city, nation", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).groupBy(buffer, "city", "nation")
+	}))
 }
 
 func TestBuilderHaving(t *testing.T) {
@@ -401,7 +590,9 @@ func TestBuilderHaving(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("?", false).having(tt.Condition)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("?", false).having(buffer, nil, tt.Condition)
+			})
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
@@ -433,7 +624,9 @@ func TestBuilderHavingOrdinal(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("$", true).having(tt.Condition)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("$", true).having(buffer, nil, tt.Condition)
+			})
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
@@ -441,19 +634,47 @@ func TestBuilderHavingOrdinal(t *testing.T) {
 }
 
 func TestBuilderOrderBy(t *testing.T) {
-	assert.Equal(t, "", NewBuilder("?", false).orderBy())
-	assert.Equal(t, "ORDER BY name ASC", NewBuilder("?", false).orderBy(Asc("name")))
-	assert.Equal(t, "ORDER BY name ASC, created_at DESC", NewBuilder("?", false).orderBy(Asc("name"), Desc("created_at")))
+	assert.Equal(t, "", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).orderBy(buffer)
+	}))
+	assert.Equal(t, "ORDER BY name ASC", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).orderBy(buffer, Asc("name"))
+	}))
+	assert.Equal(t, "ORDER BY name ASC, created_at DESC", write(func(buffer *bytes.Buffer) {
+		NewBuilder("?", false).orderBy(buffer, Asc("name"), Desc("created_at"))
+	}))
 }
 
-func TestBuilderOffset(t *testing.T) {
-	assert.Equal(t, "", NewBuilder("?", false).offset(0))
-	assert.Equal(t, "OFFSET 10", NewBuilder("?", false).offset(10))
+func TestStandardLimit(t *testing.T) {
+	assert.Equal(t, "", StandardLimit(0, 0))
+	assert.Equal(t, "", StandardLimit(0, 10))
+	assert.Equal(t, "LIMIT 10", StandardLimit(10, 0))
+	assert.Equal(t, "LIMIT 10 OFFSET 20", StandardLimit(10, 20))
 }
 
-func TestBuilderLimit(t *testing.T) {
-	assert.Equal(t, "", NewBuilder("?", false).limit(0))
-	assert.Equal(t, "LIMIT 10", NewBuilder("?", false).limit(10))
+func TestBuilderLock(t *testing.T) {
+	tests := []struct {
+		QueryString string
+		Lock        Lock
+	}{
+		{"", Lock{}},
+		{"FOR UPDATE", ForUpdate()},
+		{"FOR UPDATE NOWAIT", ForUpdate().NoWait()},
+		{"FOR UPDATE SKIP LOCKED", ForUpdate().SkipLocked()},
+		{"FOR SHARE", ForShare()},
+		{"FOR SHARE NOWAIT", ForShare().NoWait()},
+		{"FOR SHARE SKIP LOCKED", ForShare().SkipLocked()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.QueryString, func(t *testing.T) {
+			assert.Equal(t, tt.QueryString, NewBuilder("?", false).lock(tt.Lock))
+		})
+	}
+
+	builder := NewBuilder("?", false)
+	builder.LockFunc = func(Lock) string { return "" }
+	assert.Equal(t, "", builder.lock(ForUpdate()))
 }
 
 func TestBuilderCondition(t *testing.T) {
@@ -681,7 +902,9 @@ func TestBuilderCondition(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("?", false).condition(tt.Condition)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("?", false).condition(buffer, nil, tt.Condition)
+			})
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
@@ -913,9 +1136,176 @@ func TestBuilderConditionOrdinal(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.QueryString, func(t *testing.T) {
-			qs, args := NewBuilder("$", true).condition(tt.Condition)
+			qs, args := clause(func(buffer *bytes.Buffer) []interface{} {
+				return NewBuilder("$", true).condition(buffer, nil, tt.Condition)
+			})
+			assert.Equal(t, tt.QueryString, qs)
+			assert.Equal(t, tt.Args, args)
+		})
+	}
+}
+
+type quoteDialect struct {
+	Dialect
+}
+
+func (quoteDialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func TestBuilderDialect(t *testing.T) {
+	users := grimoire.Query{
+		Collection: "users",
+		Fields:     []string{"*"},
+	}
+
+	tests := []struct {
+		QueryString string
+		Args        []interface{}
+		Query       grimoire.Query
+	}{
+		{
+			`SELECT * FROM "users";`,
+			nil,
+			users,
+		},
+		{
+			`SELECT "users".*, "order", COUNT(*) AS count FROM "users";`,
+			nil,
+			users.Select("users.*", "order", "COUNT(*) AS count"),
+		},
+		{
+			`SELECT * FROM "users" JOIN "transactions" ON "transactions"."id"="users"."transaction_id";`,
+			nil,
+			users.Join("transactions", Eq(I("transactions.id"), I("users.transaction_id"))),
+		},
+		{
+			`SELECT * FROM "users" WHERE ("user"=$1 AND "deleted_at" IS NULL AND LOWER(name)=$2 AND name LIKE 'a%');`,
+			[]interface{}{10, "a"},
+			users.Where(Eq(I("user"), 10), Nil(I("deleted_at")), Eq(I("LOWER(name)"), "a"), Fragment("name LIKE 'a%'")),
+		},
+		{
+			`SELECT * FROM "users" GROUP BY "type" HAVING "price">$1 ORDER BY "created_at" DESC LIMIT 10 OFFSET 20;`,
+			[]interface{}{1000},
+			users.Group("type").Having(Gt(I("price"), 1000)).Order(Desc("created_at")).Limit(10).Offset(20),
+		},
+		{
+			`SELECT * FROM "users" WHERE ("id" IN ($1,$2) AND FALSE AND TRUE);`,
+			[]interface{}{1, 2},
+			users.Where(In(I("id"), 1, 2), In(I("id")), Nin(I("id"))),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.QueryString, func(t *testing.T) {
+			qs, args := NewDialectBuilder(quoteDialect{StandardDialect("$", true)}).Find(tt.Query)
 			assert.Equal(t, tt.QueryString, qs)
 			assert.Equal(t, tt.Args, args)
 		})
 	}
 }
+
+func TestBuilderDialectWrite(t *testing.T) {
+	builder := func() *Builder {
+		return NewDialectBuilder(quoteDialect{StandardDialect("?", false)})
+	}
+
+	qs, args := builder().Returning("id").Insert("users", map[string]interface{}{"order": 1})
+	assert.Equal(t, `INSERT INTO "users" ("order") VALUES (?) RETURNING "id";`, qs)
+	assert.Equal(t, []interface{}{1}, args)
+
+	qs, args = builder().OnConflict(OnConflict{Fields: []string{"email"}, Action: ConflictUpdate, UpdateFields: []string{"name"}}).
+		InsertAll("users", []string{"email", "name"}, []map[string]interface{}{{"email": "a@b.c", "name": "a"}})
+	assert.Equal(t, `INSERT INTO "users" ("email","name") VALUES (?,?) ON CONFLICT ("email") DO UPDATE SET "name"=EXCLUDED."name";`, qs)
+	assert.Equal(t, []interface{}{"a@b.c", "a"}, args)
+
+	duplicateKey := builder().OnConflict(OnConflict{Fields: []string{"email"}, Action: ConflictUpdate, UpdateFields: []string{"name"}})
+	duplicateKey.DuplicateKey = true
+
+	qs, args = duplicateKey.Insert("users", map[string]interface{}{"email": "a@b.c"})
+	assert.Equal(t, `INSERT INTO "users" ("email") VALUES (?) ON DUPLICATE KEY UPDATE "id"=LAST_INSERT_ID("id"),"name"=VALUES("name");`, qs)
+	assert.Equal(t, []interface{}{"a@b.c"}, args)
+
+	duplicateKey = builder().OnConflict(OnConflict{Fields: []string{"email"}, Action: ConflictIgnore})
+	duplicateKey.DuplicateKey = true
+
+	qs, _ = duplicateKey.Insert("users", map[string]interface{}{"email": "a@b.c"})
+	assert.Equal(t, `INSERT INTO "users" ("email") VALUES (?) ON DUPLICATE KEY UPDATE "id"="id";`, qs)
+
+	qs, args = builder().Update("users", map[string]interface{}{"order": 1}, Eq(I("users.id"), 10))
+	assert.Equal(t, `UPDATE "users" SET "order"=? WHERE "users"."id"=?;`, qs)
+	assert.Equal(t, []interface{}{1, 10}, args)
+
+	qs, args = builder().Delete("users", Eq(I("id"), 10))
+	assert.Equal(t, `DELETE FROM "users" WHERE "id"=?;`, qs)
+	assert.Equal(t, []interface{}{10}, args)
+}
+
+func TestPlainIdentifier(t *testing.T) {
+	tests := []struct {
+		name  string
+		plain bool
+	}{
+		{"id", true},
+		{"_id2", true},
+		{"users.id", true},
+		{"users.*", true},
+		{"*", true},
+		{"2id", false},
+		{"", false},
+		{"users.", false},
+		{"*.id", false},
+		{"COUNT(*) AS count", false},
+		{"name; DROP TABLE users", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.plain, plainIdentifier(tt.name), tt.name)
+	}
+}
+
+func BenchmarkBuilderFind(b *testing.B) {
+	query := grimoire.Query{
+		Collection: "users",
+		Fields:     []string{"users.*"},
+	}.
+		Join("addresses", Eq(I("addresses.user_id"), I("users.id"))).
+		Where(Eq(I("users.name"), "Alice"), Or(Gt(I("users.age"), 18), Nil(I("users.note"))), In(I("users.id"), 1, 2, 3)).
+		Order(Asc("users.name"), Desc("users.age")).
+		Limit(10).Offset(20)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewDialectBuilder(quoteDialect{StandardDialect("$", true)}).Find(query)
+	}
+}
+
+func BenchmarkBuilderInsert(b *testing.B) {
+	changes := map[string]interface{}{
+		"name":       "Alice",
+		"age":        18,
+		"note":       "note",
+		"created_at": "2020-01-01",
+		"updated_at": "2020-01-01",
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewDialectBuilder(quoteDialect{StandardDialect("$", true)}).Returning("id").Insert("users", changes)
+	}
+}
+
+func BenchmarkBuilderUpdate(b *testing.B) {
+	changes := map[string]interface{}{
+		"name":       "Alice",
+		"age":        18,
+		"note":       "note",
+		"updated_at": "2020-01-01",
+	}
+	cond := And(Eq(I("users.id"), 1), Nil(I("users.deleted_at")))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewDialectBuilder(quoteDialect{StandardDialect("$", true)}).Update("users", changes, cond)
+	}
+}
@@ -2,69 +2,67 @@ package sql
 
 import (
 	"bytes"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/c"
 )
 
+// bufferPool reuses buffers across statements, so building a query only allocates the resulting string and arguments.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+func putBuffer(buffer *bytes.Buffer) {
+	bufferPool.Put(buffer)
+}
+
 // Builder defines information of query builder.
 type Builder struct {
-	Placeholder string
-	Ordinal     bool
-	ReturnField string
-	count       int
+	Dialect      Dialect
+	ReturnField  string
+	DuplicateKey bool
+	Conflict     c.OnConflict
+	LockFunc     func(c.Lock) string
+	count        int
 }
 
 // Find generates query for select.
 func (builder *Builder) Find(q grimoire.Query) (string, []interface{}) {
-	var buffer bytes.Buffer
-	var args []interface{}
-
-	if s := builder.fields(q.AsDistinct, q.Fields...); s != "" {
-		buffer.WriteString(s)
-	}
-
-	if s := builder.from(q.Collection); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
-	}
-
-	if s, arg := builder.join(q.JoinClause...); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
-		args = append(args, arg...)
-	}
+	buffer := getBuffer()
+	defer putBuffer(buffer)
 
-	if s, arg := builder.where(q.Condition); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
-		args = append(args, arg...)
-	}
+	var args []interface{}
 
-	if s := builder.groupBy(q.GroupFields...); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
+	args = builder.fields(buffer, args, q.AsDistinct, q.Fields, q.FieldValues)
+	builder.from(buffer, q.Collection)
+	args = builder.join(buffer, args, q.JoinClause...)
+	args = builder.where(buffer, args, q.Condition)
 
-		if s, arg := builder.having(q.HavingCondition); s != "" {
-			buffer.WriteString(" ")
-			buffer.WriteString(s)
-			args = append(args, arg...)
-		}
+	if len(q.GroupFields) > 0 {
+		builder.groupBy(buffer, q.GroupFields...)
+		args = builder.having(buffer, args, q.HavingCondition)
 	}
 
-	if s := builder.orderBy(q.OrderClause...); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
-	}
+	builder.orderBy(buffer, q.OrderClause...)
 
-	if s := builder.limit(q.LimitResult); s != "" {
+	if s := builder.Dialect.Limit(q.LimitResult, q.OffsetResult); s != "" {
 		buffer.WriteString(" ")
 		buffer.WriteString(s)
 	}
 
-	if s := builder.offset(q.OffsetResult); s != "" && q.LimitResult != 0 {
+	if s := builder.lock(q.LockClause); s != "" {
 		buffer.WriteString(" ")
 		buffer.WriteString(s)
 	}
@@ -75,42 +73,46 @@ func (builder *Builder) Find(q grimoire.Query) (string, []interface{}) {
 }
 
 // Insert generates query for insert.
+// Columns are sorted by name, so the same changes always generates the same statement.
 func (builder *Builder) Insert(collection string, changes map[string]interface{}) (string, []interface{}) {
-	length := len(changes)
+	buffer := getBuffer()
+	defer putBuffer(buffer)
 
-	var buffer bytes.Buffer
-	var args = make([]interface{}, 0, length)
+	var (
+		fields = sortedFields(changes)
+		args   = make([]interface{}, 0, len(fields))
+	)
 
 	buffer.WriteString("INSERT INTO ")
-	buffer.WriteString(collection)
+	builder.identifier(buffer, collection)
 	buffer.WriteString(" (")
 
-	curr := 0
-	for field, value := range changes {
-		buffer.WriteString(field)
-		args = append(args, value)
-
-		if curr < length-1 {
+	for i, field := range fields {
+		if i > 0 {
 			buffer.WriteString(",")
 		}
 
-		curr++
+		builder.identifier(buffer, field)
+		args = append(args, changes[field])
 	}
-	buffer.WriteString(") VALUES ")
 
-	buffer.WriteString("(")
-	for i := 0; i < length; i++ {
-		buffer.WriteString(builder.ph())
+	buffer.WriteString(") VALUES (")
 
-		if i < length-1 {
+	for i := range fields {
+		if i > 0 {
 			buffer.WriteString(",")
 		}
+
+		builder.ph(buffer)
 	}
+
 	buffer.WriteString(")")
 
-	if builder.ReturnField != "" {
+	builder.onConflict(buffer)
+
+	if builder.ReturnField != "" && builder.Dialect.Returning() {
 		buffer.WriteString(" RETURNING ")
-		buffer.WriteString(builder.ReturnField)
+		builder.identifier(buffer, builder.ReturnField)
 	}
 
 	buffer.WriteString(";")
@@ -120,41 +122,45 @@ func (builder *Builder) Insert(collection string, changes map[string]interface{}
 
 // InsertAll generates query for multiple insert.
 func (builder *Builder) InsertAll(collection string, fields []string, allchanges []map[string]interface{}) (string, []interface{}) {
-	var buffer bytes.Buffer
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+
 	var args = make([]interface{}, 0, len(fields)*len(allchanges))
 
 	buffer.WriteString("INSERT INTO ")
-	buffer.WriteString(collection)
+	builder.identifier(buffer, collection)
 	buffer.WriteString(" (")
-	buffer.WriteString(strings.Join(fields, ","))
+	builder.identifiers(buffer, fields, ",")
 	buffer.WriteString(") VALUES ")
 
 	for i, changes := range allchanges {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+
 		buffer.WriteString("(")
 
 		for j, field := range fields {
+			if j > 0 {
+				buffer.WriteString(",")
+			}
+
 			if val, exist := changes[field]; exist {
-				buffer.WriteString(builder.ph())
+				builder.ph(buffer)
 				args = append(args, val)
 			} else {
 				buffer.WriteString("DEFAULT")
 			}
-
-			if j < len(fields)-1 {
-				buffer.WriteString(",")
-			}
 		}
 
-		if i < len(allchanges)-1 {
-			buffer.WriteString("),")
-		} else {
-			buffer.WriteString(")")
-		}
+		buffer.WriteString(")")
 	}
 
-	if builder.ReturnField != "" {
+	builder.onConflict(buffer)
+
+	if builder.ReturnField != "" && builder.Dialect.Returning() {
 		buffer.WriteString(" RETURNING ")
-		buffer.WriteString(builder.ReturnField)
+		builder.identifier(buffer, builder.ReturnField)
 	}
 
 	buffer.WriteString(";")
@@ -163,35 +169,32 @@ func (builder *Builder) InsertAll(collection string, fields []string, allchanges
 }
 
 // Update generates query for update.
+// Columns are sorted by name, so the same changes always generates the same statement.
 func (builder *Builder) Update(collection string, changes map[string]interface{}, cond c.Condition) (string, []interface{}) {
-	length := len(changes)
+	buffer := getBuffer()
+	defer putBuffer(buffer)
 
-	var buffer bytes.Buffer
-	var args = make([]interface{}, 0, length)
+	var (
+		fields = sortedFields(changes)
+		args   = make([]interface{}, 0, len(fields))
+	)
 
 	buffer.WriteString("UPDATE ")
-	buffer.WriteString(collection)
+	builder.identifier(buffer, collection)
 	buffer.WriteString(" SET ")
 
-	curr := 0
-	for field, value := range changes {
-		buffer.WriteString(field)
-		buffer.WriteString("=")
-		buffer.WriteString(builder.ph())
-		args = append(args, value)
-
-		if curr < length-1 {
+	for i, field := range fields {
+		if i > 0 {
 			buffer.WriteString(",")
 		}
 
-		curr++
+		builder.identifier(buffer, field)
+		buffer.WriteString("=")
+		builder.ph(buffer)
+		args = append(args, changes[field])
 	}
 
-	if s, arg := builder.where(cond); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
-		args = append(args, arg...)
-	}
+	args = builder.where(buffer, args, cond)
 
 	buffer.WriteString(";")
 
@@ -200,181 +203,257 @@ func (builder *Builder) Update(collection string, changes map[string]interface{}
 
 // Delete generates query for delete.
 func (builder *Builder) Delete(collection string, cond c.Condition) (string, []interface{}) {
-	var buffer bytes.Buffer
+	buffer := getBuffer()
+	defer putBuffer(buffer)
+
 	var args []interface{}
 
 	buffer.WriteString("DELETE FROM ")
-	buffer.WriteString(collection)
+	builder.identifier(buffer, collection)
 
-	if s, arg := builder.where(cond); s != "" {
-		buffer.WriteString(" ")
-		buffer.WriteString(s)
-		args = append(args, arg...)
-	}
+	args = builder.where(buffer, args, cond)
 
 	buffer.WriteString(";")
 
 	return buffer.String(), args
 }
 
-func (builder *Builder) fields(distinct bool, fields ...string) string {
-	if distinct {
-		return "SELECT DISTINCT " + strings.Join(fields, ", ")
+// sortedFields returns fields of changes sorted by name.
+func sortedFields(changes map[string]interface{}) []string {
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
 	}
 
-	return "SELECT " + strings.Join(fields, ", ")
+	sort.Strings(fields)
+	return fields
 }
 
-func (builder *Builder) from(collection string) string {
-	return "FROM " + collection
-}
+func (builder *Builder) onConflict(buffer *bytes.Buffer) {
+	if builder.Conflict.None() {
+		return
+	}
 
-func (builder *Builder) join(join ...c.Join) (string, []interface{}) {
-	if len(join) == 0 {
-		return "", nil
+	if builder.DuplicateKey {
+		builder.onDuplicateKey(buffer)
+		return
 	}
 
-	var qs string
-	var args []interface{}
-	for i, j := range join {
-		cs, jargs := builder.condition(j.Condition)
-		qs += j.Mode + " " + j.Collection + " ON " + cs
-		args = append(args, jargs...)
+	buffer.WriteString(" ON CONFLICT")
+	if len(builder.Conflict.Fields) > 0 {
+		buffer.WriteString(" (")
+		builder.identifiers(buffer, builder.Conflict.Fields, ",")
+		buffer.WriteString(")")
+	}
 
-		if i < len(join)-1 {
-			qs += " "
+	if builder.Conflict.Action == c.ConflictIgnore || len(builder.Conflict.UpdateFields) == 0 {
+		buffer.WriteString(" DO NOTHING")
+		return
+	}
+
+	buffer.WriteString(" DO UPDATE SET ")
+	for i, field := range builder.Conflict.UpdateFields {
+		if i > 0 {
+			buffer.WriteString(",")
 		}
+
+		builder.identifier(buffer, field)
+		buffer.WriteString("=EXCLUDED.")
+		builder.identifier(buffer, field)
+	}
+}
+
+// onDuplicateKey generates mysql's conflict resolution.
+// Primary key is assigned using LAST_INSERT_ID, so the id of updated record is returned as insert id.
+func (builder *Builder) onDuplicateKey(buffer *bytes.Buffer) {
+	buffer.WriteString(" ON DUPLICATE KEY UPDATE ")
+	builder.identifier(buffer, "id")
+
+	if builder.Conflict.Action == c.ConflictIgnore || len(builder.Conflict.UpdateFields) == 0 {
+		buffer.WriteString("=")
+		builder.identifier(buffer, "id")
+		return
 	}
 
-	return qs, args
+	buffer.WriteString("=LAST_INSERT_ID(")
+	builder.identifier(buffer, "id")
+	buffer.WriteString(")")
+	for _, field := range builder.Conflict.UpdateFields {
+		buffer.WriteString(",")
+		builder.identifier(buffer, field)
+		buffer.WriteString("=VALUES(")
+		builder.identifier(buffer, field)
+		buffer.WriteString(")")
+	}
+}
+
+func (builder *Builder) fields(buffer *bytes.Buffer, args []interface{}, distinct bool, fields []string, values []interface{}) []interface{} {
+	if distinct {
+		buffer.WriteString("SELECT DISTINCT ")
+	} else {
+		buffer.WriteString("SELECT ")
+	}
+
+	// fields only need to be processed as fragment when it contains placeholder or escape.
+	if len(values) == 0 && !containsPlaceholder(fields) {
+		builder.identifiers(buffer, fields, ", ")
+		return args
+	}
+
+	expr := getBuffer()
+	defer putBuffer(expr)
+
+	builder.identifiers(expr, fields, ", ")
+	return builder.fragment(buffer, args, expr.String(), values)
 }
 
-func (builder *Builder) where(condition c.Condition) (string, []interface{}) {
+func (builder *Builder) from(buffer *bytes.Buffer, collection string) {
+	buffer.WriteString(" FROM ")
+	builder.identifier(buffer, collection)
+}
+
+func (builder *Builder) join(buffer *bytes.Buffer, args []interface{}, join ...c.Join) []interface{} {
+	for _, j := range join {
+		buffer.WriteString(" ")
+		buffer.WriteString(j.Mode)
+		buffer.WriteString(" ")
+		builder.identifier(buffer, j.Collection)
+		buffer.WriteString(" ON ")
+		args = builder.condition(buffer, args, j.Condition)
+	}
+
+	return args
+}
+
+func (builder *Builder) where(buffer *bytes.Buffer, args []interface{}, condition c.Condition) []interface{} {
 	if condition.None() {
-		return "", nil
+		return args
 	}
 
-	qs, args := builder.condition(condition)
-	return "WHERE " + qs, args
+	buffer.WriteString(" WHERE ")
+	return builder.condition(buffer, args, condition)
 }
 
-func (builder *Builder) groupBy(fields ...string) string {
-	if len(fields) > 0 {
-		return "GROUP BY " + strings.Join(fields, ", ")
+func (builder *Builder) groupBy(buffer *bytes.Buffer, fields ...string) {
+	if len(fields) == 0 {
+		return
 	}
 
-	return ""
+	buffer.WriteString(" GROUP BY ")
+	builder.identifiers(buffer, fields, ", ")
 }
 
-func (builder *Builder) having(condition c.Condition) (string, []interface{}) {
+func (builder *Builder) having(buffer *bytes.Buffer, args []interface{}, condition c.Condition) []interface{} {
 	if condition.None() {
-		return "", nil
+		return args
 	}
 
-	qs, args := builder.condition(condition)
-	return "HAVING " + qs, args
+	buffer.WriteString(" HAVING ")
+	return builder.condition(buffer, args, condition)
 }
 
-func (builder *Builder) orderBy(orders ...c.Order) string {
-	length := len(orders)
-	if length == 0 {
-		return ""
+func (builder *Builder) orderBy(buffer *bytes.Buffer, orders ...c.Order) {
+	if len(orders) == 0 {
+		return
 	}
 
-	qs := "ORDER BY "
+	buffer.WriteString(" ORDER BY ")
 	for i, o := range orders {
-		if o.Asc() {
-			qs += string(o.Field) + " ASC"
-		} else {
-			qs += string(o.Field) + " DESC"
+		if i > 0 {
+			buffer.WriteString(", ")
 		}
 
-		if i < length-1 {
-			qs += ", "
+		builder.identifier(buffer, string(o.Field))
+
+		if o.Asc() {
+			buffer.WriteString(" ASC")
+		} else {
+			buffer.WriteString(" DESC")
 		}
 	}
-
-	return qs
 }
 
-func (builder *Builder) offset(n int) string {
-	if n > 0 {
-		return "OFFSET " + strconv.Itoa(n)
+func (builder *Builder) lock(lock c.Lock) string {
+	if lock.None() {
+		return ""
 	}
 
-	return ""
-}
-
-func (builder *Builder) limit(n int) string {
-	if n > 0 {
-		return "LIMIT " + strconv.Itoa(n)
+	if builder.LockFunc != nil {
+		return builder.LockFunc(lock)
 	}
 
-	return ""
+	return StandardLock(lock)
 }
 
-func (builder *Builder) condition(cond c.Condition) (string, []interface{}) {
+func (builder *Builder) condition(buffer *bytes.Buffer, args []interface{}, cond c.Condition) []interface{} {
 	switch cond.Type {
 	case c.ConditionAnd:
-		return builder.build("AND", cond.Inner)
+		return builder.build(buffer, args, "AND", cond.Inner)
 	case c.ConditionOr:
-		return builder.build("OR", cond.Inner)
+		return builder.build(buffer, args, "OR", cond.Inner)
 	case c.ConditionNot:
-		qs, args := builder.build("AND", cond.Inner)
-		return "NOT " + qs, args
+		buffer.WriteString("NOT ")
+		return builder.build(buffer, args, "AND", cond.Inner)
 	case c.ConditionEq,
 		c.ConditionNe,
 		c.ConditionLt,
 		c.ConditionLte,
 		c.ConditionGt,
 		c.ConditionGte:
-		return builder.buildComparison(cond)
+		return builder.buildComparison(buffer, args, cond)
 	case c.ConditionNil:
-		return string(cond.Left.Column) + " IS NULL", cond.Right.Values
+		builder.identifier(buffer, string(cond.Left.Column))
+		buffer.WriteString(" IS NULL")
+		return append(args, cond.Right.Values...)
 	case c.ConditionNotNil:
-		return string(cond.Left.Column) + " IS NOT NULL", cond.Right.Values
+		builder.identifier(buffer, string(cond.Left.Column))
+		buffer.WriteString(" IS NOT NULL")
+		return append(args, cond.Right.Values...)
 	case c.ConditionIn,
 		c.ConditionNin:
-		return builder.buildInclusion(cond)
+		return builder.buildInclusion(buffer, args, cond)
 	case c.ConditionLike:
-		return string(cond.Left.Column) + " LIKE " + builder.ph(), cond.Right.Values
+		builder.identifier(buffer, string(cond.Left.Column))
+		buffer.WriteString(" LIKE ")
+		builder.ph(buffer)
+		return append(args, cond.Right.Values...)
 	case c.ConditionNotLike:
-		return string(cond.Left.Column) + " NOT LIKE " + builder.ph(), cond.Right.Values
+		builder.identifier(buffer, string(cond.Left.Column))
+		buffer.WriteString(" NOT LIKE ")
+		builder.ph(buffer)
+		return append(args, cond.Right.Values...)
 	case c.ConditionFragment:
-		return string(cond.Left.Column), cond.Right.Values
+		return builder.fragment(buffer, args, string(cond.Left.Column), cond.Right.Values)
 	}
 
-	return "", nil
+	return args
 }
 
-func (builder *Builder) build(op string, inner []c.Condition) (string, []interface{}) {
+func (builder *Builder) build(buffer *bytes.Buffer, args []interface{}, op string, inner []c.Condition) []interface{} {
 	length := len(inner)
-	var qstring string
-	var args []interface{}
 
 	if length > 1 {
-		qstring += "("
+		buffer.WriteString("(")
 	}
 
 	for i, c := range inner {
-		cQstring, cArgs := builder.condition(c)
-		qstring += cQstring
-		args = append(args, cArgs...)
-
-		if i < length-1 {
-			qstring += " " + op + " "
+		if i > 0 {
+			buffer.WriteString(" ")
+			buffer.WriteString(op)
+			buffer.WriteString(" ")
 		}
+
+		args = builder.condition(buffer, args, c)
 	}
 
 	if length > 1 {
-		qstring += ")"
+		buffer.WriteString(")")
 	}
 
-	return qstring, args
+	return args
 }
 
-func (builder *Builder) buildComparison(cond c.Condition) (string, []interface{}) {
-	var cs string
+func (builder *Builder) buildComparison(buffer *bytes.Buffer, args []interface{}, cond c.Condition) []interface{} {
 	var op string
 
 	switch cond.Type {
@@ -393,23 +472,31 @@ func (builder *Builder) buildComparison(cond c.Condition) (string, []interface{}
 	}
 
 	if cond.Left.Column != "" {
-		cs = string(cond.Left.Column) + op
+		builder.identifier(buffer, string(cond.Left.Column))
 	} else {
-		cs = builder.ph() + op
+		builder.ph(buffer)
 	}
 
+	buffer.WriteString(op)
+
 	if cond.Right.Column != "" {
-		cs += string(cond.Right.Column)
+		builder.identifier(buffer, string(cond.Right.Column))
 	} else {
-		cs += builder.ph()
+		builder.ph(buffer)
 	}
 
-	return cs, append(cond.Left.Values, cond.Right.Values...)
+	args = append(args, cond.Left.Values...)
+	return append(args, cond.Right.Values...)
 }
 
-func (builder *Builder) buildInclusion(cond c.Condition) (string, []interface{}) {
-	var buffer bytes.Buffer
-	buffer.WriteString(string(cond.Left.Column))
+func (builder *Builder) buildInclusion(buffer *bytes.Buffer, args []interface{}, cond c.Condition) []interface{} {
+	// empty list never matches, so it's replaced with boolean literal.
+	if len(cond.Right.Values) == 0 {
+		buffer.WriteString(builder.Dialect.Bool(cond.Type == c.ConditionNin))
+		return args
+	}
+
+	builder.identifier(buffer, string(cond.Left.Column))
 
 	if cond.Type == c.ConditionIn {
 		buffer.WriteString(" IN (")
@@ -417,23 +504,180 @@ func (builder *Builder) buildInclusion(cond c.Condition) (string, []interface{})
 		buffer.WriteString(" NOT IN (")
 	}
 
-	buffer.WriteString(builder.ph())
-	for i := 1; i <= len(cond.Right.Values)-1; i++ {
-		buffer.WriteString(",")
-		buffer.WriteString(builder.ph())
+	for i := range cond.Right.Values {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+
+		builder.ph(buffer)
 	}
+
 	buffer.WriteString(")")
 
-	return buffer.String(), cond.Right.Values
+	return append(args, cond.Right.Values...)
+}
+
+// fragment replaces placeholders in expression with dialect's placeholder and returns the bound arguments.
+// If the only value is a map, named placeholders such as :min_age are bound from the map, otherwise ? placeholders are bound in order.
+// Use ?? or :: to write literal ? or ::, placeholders inside quoted string or identifier are left as is.
+func (builder *Builder) fragment(buffer *bytes.Buffer, args []interface{}, expr string, values []interface{}) []interface{} {
+	named, isNamed := namedValues(values)
+	if !strings.ContainsAny(expr, "?:") {
+		buffer.WriteString(expr)
+
+		if isNamed {
+			return args
+		}
+
+		return append(args, values...)
+	}
+
+	var quote byte
+
+	// placeholders, quotes and escapes are ascii, so the expression can be scanned byte by byte.
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case (ch == '?' || ch == ':') && i+1 < len(expr) && expr[i+1] == ch:
+			// escaped ?? is written as single ?, while :: is kept as is since it's used for type cast.
+			if ch == ':' {
+				buffer.WriteByte(ch)
+			}
+
+			i++
+		case ch == '?' && !isNamed:
+			builder.ph(buffer)
+			continue
+		case ch == ':' && isNamed:
+			name := expr[i+1 : i+1+identifierLength(expr[i+1:])]
+			if value, ok := named[name]; ok && name != "" {
+				builder.ph(buffer)
+				args = append(args, value)
+				i += len(name)
+				continue
+			}
+		}
+
+		buffer.WriteByte(ch)
+	}
+
+	if !isNamed {
+		args = append(args, values...)
+	}
+
+	return args
+}
+
+func (builder *Builder) ph(buffer *bytes.Buffer) {
+	builder.count++
+	buffer.WriteString(builder.Dialect.Placeholder(builder.count))
+}
+
+// identifier quotes plain identifier such as name, users.name or users.* using dialect.
+// Anything else such as COUNT(*) AS count is considered as expression and left as is.
+func (builder *Builder) identifier(buffer *bytes.Buffer, name string) {
+	if !plainIdentifier(name) {
+		buffer.WriteString(name)
+		return
+	}
+
+	for {
+		part := name
+		dot := strings.IndexByte(name, '.')
+		if dot >= 0 {
+			part = name[:dot]
+		}
+
+		if part == "*" {
+			buffer.WriteString(part)
+		} else {
+			buffer.WriteString(builder.Dialect.Quote(part))
+		}
+
+		if dot < 0 {
+			return
+		}
+
+		buffer.WriteString(".")
+		name = name[dot+1:]
+	}
+}
+
+func (builder *Builder) identifiers(buffer *bytes.Buffer, names []string, sep string) {
+	for i := range names {
+		if i > 0 {
+			buffer.WriteString(sep)
+		}
+
+		builder.identifier(buffer, names[i])
+	}
+}
+
+// plainIdentifier returns true if name only contains dot separated words, the last one can be a wildcard.
+func plainIdentifier(name string) bool {
+	start := true
+
+	for i, r := range name {
+		switch {
+		case r == '.' && !start:
+			start = true
+		case r == '*' && start && i == len(name)-1:
+			start = false
+		case start && identifierStart(r), !start && identifierPart(r):
+			start = false
+		default:
+			return false
+		}
+	}
+
+	return !start
 }
 
-func (builder *Builder) ph() string {
-	if builder.Ordinal {
-		builder.count++
-		return builder.Placeholder + strconv.Itoa(builder.count)
+// identifierLength returns length of identifier at the beginning of s.
+func identifierLength(s string) int {
+	for i, r := range s {
+		if (i == 0 && !identifierStart(r)) || !identifierPart(r) {
+			return i
+		}
 	}
 
-	return builder.Placeholder
+	return len(s)
+}
+
+func identifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func identifierPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// containsPlaceholder returns true if any of fields contains placeholder or escape character.
+func containsPlaceholder(fields []string) bool {
+	for i := range fields {
+		if strings.ContainsAny(fields[i], "?:") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// namedValues returns the map if it's the only value.
+func namedValues(values []interface{}) (map[string]interface{}, bool) {
+	if len(values) != 1 {
+		return nil, false
+	}
+
+	named, ok := values[0].(map[string]interface{})
+	return named, ok
 }
 
 // Returning append returning to insert query.
@@ -442,10 +686,43 @@ func (builder *Builder) Returning(field string) *Builder {
 	return builder
 }
 
-// NewBuilder create new SQL builder.
+// OnConflict append conflict resolution to insert query.
+func (builder *Builder) OnConflict(onConflict c.OnConflict) *Builder {
+	builder.Conflict = onConflict
+	return builder
+}
+
+// StandardLock generates standard locking clause of select query.
+func StandardLock(lock c.Lock) string {
+	var qs string
+
+	switch lock.Mode {
+	case c.LockForUpdate:
+		qs = "FOR UPDATE"
+	case c.LockForShare:
+		qs = "FOR SHARE"
+	default:
+		return ""
+	}
+
+	switch lock.Wait {
+	case c.LockNoWait:
+		qs += " NOWAIT"
+	case c.LockSkipLocked:
+		qs += " SKIP LOCKED"
+	}
+
+	return qs
+}
+
+// NewBuilder create new SQL builder using standard dialect with the given placeholder.
 func NewBuilder(placeholder string, ordinal bool) *Builder {
+	return NewDialectBuilder(StandardDialect(placeholder, ordinal))
+}
+
+// NewDialectBuilder create new SQL builder using dialect.
+func NewDialectBuilder(dialect Dialect) *Builder {
 	return &Builder{
-		Placeholder: placeholder,
-		Ordinal:     ordinal,
+		Dialect: dialect,
 	}
 }
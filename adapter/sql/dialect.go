@@ -0,0 +1,74 @@
+package sql
+
+import (
+	"strconv"
+)
+
+// Dialect defines database specific syntax used by builder.
+type Dialect interface {
+	// Quote quotes a single identifier, such as collection or column name.
+	Quote(name string) string
+	// Placeholder returns placeholder of nth argument, n starts from 1.
+	Placeholder(n int) string
+	// Limit returns limit and offset clause, offset is only used alongside limit.
+	Limit(limit int, offset int) string
+	// Returning returns true if insert supports returning clause.
+	Returning() bool
+	// Bool returns boolean literal.
+	Bool(value bool) string
+}
+
+type standardDialect struct {
+	placeholder string
+	ordinal     bool
+}
+
+// StandardDialect returns dialect that uses the given placeholder and standard syntax for everything else.
+// Identifiers are not quoted, so it generates the same statement as builder prior to dialect.
+func StandardDialect(placeholder string, ordinal bool) Dialect {
+	return standardDialect{
+		placeholder: placeholder,
+		ordinal:     ordinal,
+	}
+}
+
+func (dialect standardDialect) Quote(name string) string {
+	return name
+}
+
+func (dialect standardDialect) Placeholder(n int) string {
+	if dialect.ordinal {
+		return dialect.placeholder + strconv.Itoa(n)
+	}
+
+	return dialect.placeholder
+}
+
+func (dialect standardDialect) Limit(limit int, offset int) string {
+	return StandardLimit(limit, offset)
+}
+
+func (dialect standardDialect) Returning() bool {
+	return true
+}
+
+func (dialect standardDialect) Bool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
+// StandardLimit generates standard limit and offset clause of select query.
+func StandardLimit(limit int, offset int) string {
+	if limit <= 0 {
+		return ""
+	}
+
+	if offset > 0 {
+		return "LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(offset)
+	}
+
+	return "LIMIT " + strconv.Itoa(limit)
+}
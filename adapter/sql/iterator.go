@@ -0,0 +1,62 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"reflect"
+)
+
+// Iterator iterates rows of query result, it's returned by Adapter.Iterate.
+type Iterator struct {
+	ctx     context.Context
+	adapter *Adapter
+	rows    *sql.Rows
+	release func()
+	columns []string
+	typ     reflect.Type
+	index   map[string]int
+}
+
+// Next scans the next row into record, it'll return io.EOF if there's no more row.
+func (iterator *Iterator) Next(record interface{}) error {
+	if !iterator.rows.Next() {
+		if err := iterator.rows.Err(); err != nil {
+			return iterator.adapter.error(iterator.ctx, err)
+		}
+
+		return io.EOF
+	}
+
+	rv := reflect.ValueOf(record)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic("record must be pointer")
+	}
+
+	rv = rv.Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+
+	if rv.Addr().Type().Implements(typeScanner) {
+		return iterator.adapter.error(iterator.ctx, iterator.rows.Scan(record))
+	}
+
+	// field index is cached, since the same record type is usually used for every row.
+	if iterator.typ != rv.Type() {
+		iterator.typ = rv.Type()
+		iterator.index = fieldIndex(rv.Type())
+	}
+
+	err := iterator.rows.Scan(fieldPtr(rv, iterator.index, iterator.columns)...)
+	return iterator.adapter.error(iterator.ctx, err)
+}
+
+// Close closes the underlying rows and releases its cached statement.
+func (iterator *Iterator) Close() error {
+	err := iterator.rows.Close()
+	if iterator.release != nil {
+		iterator.release()
+		iterator.release = nil
+	}
+
+	return err
+}
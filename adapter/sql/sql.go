@@ -1,70 +1,150 @@
 package sql
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
 	"github.com/Fs02/grimoire/errors"
 )
 
 // Adapter definition for mysql database.
 type Adapter struct {
-	Placeholder   string
-	Ordinal       bool
+	Dialect       Dialect
+	DuplicateKey  bool
 	ErrorFunc     func(error) error
 	IncrementFunc func(Adapter) int
+	LockFunc      func(c.Lock) string
+	Observer      grimoire.Observer
+	Statements    *StatementCache
 	DB            *sql.DB
 	Tx            *sql.Tx
+	ctx           context.Context
+	savepoint     int
 }
 
-var _ grimoire.Adapter = (*Adapter)(nil)
+var (
+	_ grimoire.Adapter  = (*Adapter)(nil)
+	_ grimoire.Renderer = (*Adapter)(nil)
+)
 
-// New initialize adapter without db.
+// New initialize adapter without db using standard dialect with the given placeholder.
+//
+// Deprecated: use NewWithDialect, so identifiers are quoted using database's dialect.
 func New(placeholder string, ordinal bool, errfn func(error) error, incfn func(Adapter) int) *Adapter {
+	return NewWithDialect(StandardDialect(placeholder, ordinal), errfn, incfn)
+}
+
+// NewWithDialect initialize adapter without db using dialect.
+func NewWithDialect(dialect Dialect, errfn func(error) error, incfn func(Adapter) int) *Adapter {
 	return &Adapter{
-		Placeholder:   placeholder,
-		Ordinal:       ordinal,
+		Dialect:       dialect,
 		ErrorFunc:     errfn,
 		IncrementFunc: incfn,
 	}
 }
 
-// Close mysql connection.
+// Builder returns a new SQL builder configured for the adapter.
+func (adapter *Adapter) Builder() *Builder {
+	builder := NewDialectBuilder(adapter.Dialect)
+	builder.DuplicateKey = adapter.DuplicateKey
+	builder.LockFunc = adapter.LockFunc
+
+	return builder
+}
+
+// Close mysql connection, cached statements are closed before closing the connection.
 func (adapter *Adapter) Close() error {
+	if adapter.Statements != nil {
+		adapter.Statements.Close()
+	}
+
 	return adapter.DB.Close()
 }
 
 // Count retrieves count of record that match the query.
-func (adapter *Adapter) Count(query grimoire.Query, loggers ...grimoire.Logger) (int, error) {
+func (adapter *Adapter) Count(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int, error) {
 	var doc struct {
 		Count int
 	}
 
-	query.Fields = []string{"COUNT(*) AS count"}
-	statement, args := NewBuilder(adapter.Placeholder, adapter.Ordinal).Find(query)
-	_, err := adapter.Query(&doc, statement, args, loggers...)
+	statement, args := adapter.Builder().Find(countQuery(query))
+	_, err := adapter.Query(query.Context(), &doc, statement, args, instrumenters...)
 	return doc.Count, err
 }
 
+// countQuery replaces fields of query with count, lock is removed since it can't be used with aggregate function.
+func countQuery(query grimoire.Query) grimoire.Query {
+	query.Fields = []string{"COUNT(*) AS count"}
+	query.FieldValues = nil
+	query.LockClause = c.Lock{}
+	return query
+}
+
 // All retrieves all record that match the query.
-func (adapter *Adapter) All(query grimoire.Query, doc interface{}, loggers ...grimoire.Logger) (int, error) {
-	statement, args := NewBuilder(adapter.Placeholder, adapter.Ordinal).Find(query)
-	count, err := adapter.Query(doc, statement, args, loggers...)
+func (adapter *Adapter) All(query grimoire.Query, doc interface{}, instrumenters ...grimoire.Instrumenter) (int, error) {
+	statement, args := adapter.Builder().Find(query)
+	count, err := adapter.Query(query.Context(), doc, statement, args, instrumenters...)
 	return int(count), err
 }
 
+// Iterate returns iterator of records that match the query.
+func (adapter *Adapter) Iterate(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (grimoire.Iterator, error) {
+	statement, args := adapter.Builder().Find(query)
+	ctx, finish := adapter.observe(query.Context(), "query")
+
+	start := time.Now()
+	rows, release, err := adapter.query(ctx, statement, args)
+
+	var columns []string
+	if err == nil {
+		if columns, err = rows.Columns(); err != nil {
+			rows.Close()
+		}
+	}
+
+	if err != nil {
+		release()
+	}
+
+	err = adapter.error(ctx, err)
+	adapter.instrument(ctx, instrumenters, statement, args, start, 0, err)
+	finish(grimoire.SpanResult{Statement: statement, Error: err})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Iterator{
+		ctx:     ctx,
+		adapter: adapter,
+		rows:    rows,
+		release: release,
+		columns: columns,
+	}, nil
+}
+
 // Insert inserts a record to database and returns its id.
-func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, loggers ...grimoire.Logger) (interface{}, error) {
-	statement, args := NewBuilder(adapter.Placeholder, adapter.Ordinal).Insert(query.Collection, changes)
-	id, _, err := adapter.Exec(statement, args, loggers...)
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	statement, args := adapter.Builder().OnConflict(query.OnConflictClause).Insert(query.Collection, changes)
+	id, _, err := adapter.Exec(query.Context(), statement, args, instrumenters...)
 	return id, err
 }
 
 // InsertAll inserts all record to database and returns its ids.
-func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, loggers ...grimoire.Logger) ([]interface{}, error) {
-	statement, args := NewBuilder(adapter.Placeholder, adapter.Ordinal).InsertAll(query.Collection, fields, allchanges)
-	id, _, err := adapter.Exec(statement, args, loggers...)
+// Multiple records with conflict resolution can't be inserted using duplicate key, since ids of conflicting records are unknown.
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	if adapter.DuplicateKey && !query.OnConflictClause.None() && len(allchanges) > 1 {
+		return nil, errors.UnexpectedError("conflict resolution of multiple records is not supported")
+	}
+
+	statement, args := adapter.Builder().OnConflict(query.OnConflictClause).InsertAll(query.Collection, fields, allchanges)
+	id, _, err := adapter.Exec(query.Context(), statement, args, instrumenters...)
 	if err != nil {
 		return nil, err
 	}
@@ -83,94 +163,315 @@ func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchan
 	return ids, nil
 }
 
-// Update updates a record in database.
-func (adapter *Adapter) Update(query grimoire.Query, changes map[string]interface{}, loggers ...grimoire.Logger) error {
-	statement, args := NewBuilder(adapter.Placeholder, adapter.Ordinal).Update(query.Collection, changes, query.Condition)
-	_, _, err := adapter.Exec(statement, args, loggers...)
-	return err
+// Update updates a record in database, and returns the number of affected rows.
+func (adapter *Adapter) Update(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	statement, args := adapter.Builder().Update(query.Collection, changes, query.Condition)
+	_, count, err := adapter.Exec(query.Context(), statement, args, instrumenters...)
+	return count, err
 }
 
-// Delete deletes all results that match the query.
-func (adapter *Adapter) Delete(query grimoire.Query, loggers ...grimoire.Logger) error {
-	statement, args := NewBuilder(adapter.Placeholder, adapter.Ordinal).Delete(query.Collection, query.Condition)
-	_, _, err := adapter.Exec(statement, args, loggers...)
-	return err
+// Delete deletes all results that match the query, and returns the number of affected rows.
+func (adapter *Adapter) Delete(query grimoire.Query, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	statement, args := adapter.Builder().Delete(query.Collection, query.Condition)
+	_, count, err := adapter.Exec(query.Context(), statement, args, instrumenters...)
+	return count, err
+}
+
+// Render renders statement and arguments of operation without performing it.
+func (adapter *Adapter) Render(op grimoire.Operation) (string, []interface{}, error) {
+	var (
+		statement string
+		args      []interface{}
+		query     = op.Query
+	)
+
+	switch op.Op {
+	case "count":
+		statement, args = adapter.Builder().Find(countQuery(query))
+	case "all", "iterate":
+		statement, args = adapter.Builder().Find(query)
+	case "insert":
+		statement, args = adapter.Builder().OnConflict(query.OnConflictClause).Insert(query.Collection, op.Changes)
+	case "insert_all":
+		statement, args = adapter.Builder().OnConflict(query.OnConflictClause).InsertAll(query.Collection, op.Fields, op.AllChanges)
+	case "update":
+		statement, args = adapter.Builder().Update(query.Collection, op.Changes, query.Condition)
+	case "delete":
+		statement, args = adapter.Builder().Delete(query.Collection, query.Condition)
+	case "raw_query", "raw_exec":
+		statement, args = adapter.rewrite(op.Statement), op.Args
+	default:
+		return "", nil, errors.UnexpectedError("unsupported operation: " + op.Op)
+	}
+
+	return statement, args, nil
 }
 
 // Begin begins a new transaction.
-func (adapter *Adapter) Begin() (grimoire.Adapter, error) {
-	Tx, err := adapter.DB.Begin()
+// If adapter is already in transaction, it'll create a savepoint instead and opts will be ignored.
+func (adapter *Adapter) Begin(ctx context.Context, opts *sql.TxOptions) (grimoire.Adapter, error) {
+	spanCtx, finish := adapter.observe(ctx, "begin")
+
+	if adapter.Tx != nil {
+		spAdapter, err := adapter.beginSavepoint(spanCtx)
+		spAdapter.ctx = ctx
+		finish(grimoire.SpanResult{Statement: "SAVEPOINT " + spAdapter.savepointName() + ";", Error: err})
+		return spAdapter, err
+	}
+
+	Tx, err := adapter.DB.BeginTx(spanCtx, opts)
+	err = adapter.error(spanCtx, err)
+	finish(grimoire.SpanResult{Error: err})
 
 	return &Adapter{
-		Placeholder:   adapter.Placeholder,
-		Ordinal:       adapter.Ordinal,
+		Dialect:       adapter.Dialect,
+		DuplicateKey:  adapter.DuplicateKey,
 		IncrementFunc: adapter.IncrementFunc,
 		ErrorFunc:     adapter.ErrorFunc,
+		LockFunc:      adapter.LockFunc,
+		Observer:      adapter.Observer,
+		Statements:    adapter.Statements,
 		Tx:            Tx,
+		ctx:           ctx,
 	}, err
 }
 
 // Commit commits current transaction.
+// If current transaction is a savepoint, it'll release the savepoint instead.
 func (adapter *Adapter) Commit() error {
 	if adapter.Tx == nil {
 		return errors.UnexpectedError("not in transaction")
 	}
 
-	err := adapter.Tx.Commit()
-	return adapter.ErrorFunc(err)
+	ctx, finish := adapter.observe(adapter.context(), "commit")
+
+	var err error
+	var statement string
+	if adapter.savepoint > 0 {
+		statement = "RELEASE SAVEPOINT " + adapter.savepointName() + ";"
+		_, _, err = adapter.Exec(ctx, statement, nil)
+	} else {
+		err = adapter.ErrorFunc(adapter.Tx.Commit())
+	}
+
+	finish(grimoire.SpanResult{Statement: statement, Error: err})
+	return err
 }
 
 // Rollback revert current transaction.
+// If current transaction is a savepoint, only changes made after the savepoint will be reverted.
 func (adapter *Adapter) Rollback() error {
 	if adapter.Tx == nil {
 		return errors.UnexpectedError("not in transaction")
 	}
 
-	err := adapter.Tx.Rollback()
-	return adapter.ErrorFunc(err)
+	ctx, finish := adapter.observe(adapter.context(), "rollback")
+
+	var err error
+	var statement string
+	if adapter.savepoint > 0 {
+		statement = "ROLLBACK TO SAVEPOINT " + adapter.savepointName() + ";"
+		_, _, err = adapter.Exec(ctx, statement, nil)
+	} else {
+		err = adapter.ErrorFunc(adapter.Tx.Rollback())
+	}
+
+	finish(grimoire.SpanResult{Statement: statement, Error: err})
+	return err
+}
+
+func (adapter *Adapter) beginSavepoint(ctx context.Context) (*Adapter, error) {
+	spAdapter := *adapter
+	spAdapter.savepoint++
+
+	_, _, err := spAdapter.Exec(ctx, "SAVEPOINT "+spAdapter.savepointName()+";", nil)
+	return &spAdapter, err
+}
+
+func (adapter *Adapter) savepointName() string {
+	return "sp" + strconv.Itoa(adapter.savepoint)
 }
 
 // Query performs query operation.
-func (adapter *Adapter) Query(out interface{}, statement string, args []interface{}, loggers ...grimoire.Logger) (int64, error) {
-	var rows *sql.Rows
-	var err error
+func (adapter *Adapter) Query(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	var count int64
+
+	ctx, finish := adapter.observe(ctx, "query")
 
 	start := time.Now()
-	if adapter.Tx != nil {
-		rows, err = adapter.Tx.Query(statement, args...)
-	} else {
-		rows, err = adapter.DB.Query(statement, args...)
+	rows, release, err := adapter.query(ctx, statement, args)
+	if err == nil {
+		count, err = Scan(out, rows)
+		rows.Close()
 	}
-	go grimoire.Log(loggers, statement, time.Since(start), err)
 
-	if err != nil {
-		return 0, adapter.ErrorFunc(err)
-	}
+	release()
 
-	defer rows.Close()
-	count, err := Scan(out, rows)
-	return count, adapter.ErrorFunc(err)
+	err = adapter.error(ctx, err)
+	adapter.instrument(ctx, instrumenters, statement, args, start, count, err)
+	finish(grimoire.SpanResult{Statement: statement, Rows: count, Error: err})
+
+	return count, err
 }
 
 // Exec performs exec operation.
-func (adapter *Adapter) Exec(statement string, args []interface{}, loggers ...grimoire.Logger) (int64, int64, error) {
-	var res sql.Result
-	var err error
+func (adapter *Adapter) Exec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	var lastID, rowCount int64
+
+	ctx, finish := adapter.observe(ctx, "exec")
 
 	start := time.Now()
-	if adapter.Tx != nil {
-		res, err = adapter.Tx.Exec(statement, args...)
-	} else {
-		res, err = adapter.DB.Exec(statement, args...)
+	res, err := adapter.exec(ctx, statement, args)
+
+	if err == nil {
+		lastID, _ = res.LastInsertId()
+		rowCount, _ = res.RowsAffected()
 	}
-	go grimoire.Log(loggers, statement, time.Since(start), err)
 
+	err = adapter.error(ctx, err)
+	adapter.instrument(ctx, instrumenters, statement, args, start, rowCount, err)
+	finish(grimoire.SpanResult{Statement: statement, Rows: rowCount, Error: err})
+
+	return lastID, rowCount, err
+}
+
+// RawQuery performs raw query operation, ? placeholders will be rewritten if adapter uses ordinal placeholder.
+// Use ?? to write literal ?.
+func (adapter *Adapter) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, error) {
+	return adapter.Query(ctx, out, adapter.rewrite(statement), args, instrumenters...)
+}
+
+// RawExec performs raw exec operation, ? placeholders will be rewritten if adapter uses ordinal placeholder.
+// Use ?? to write literal ?.
+func (adapter *Adapter) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...grimoire.Instrumenter) (int64, int64, error) {
+	return adapter.Exec(ctx, adapter.rewrite(statement), args, instrumenters...)
+}
+
+// query performs query using cached prepared statement if adapter has statement cache.
+// release must be called after rows is closed, it's never nil.
+func (adapter *Adapter) query(ctx context.Context, statement string, args []interface{}) (*sql.Rows, func(), error) {
+	if adapter.Statements == nil {
+		var rows *sql.Rows
+		var err error
+
+		if adapter.Tx != nil {
+			rows, err = adapter.Tx.QueryContext(ctx, statement, args...)
+		} else {
+			rows, err = adapter.DB.QueryContext(ctx, statement, args...)
+		}
+
+		return rows, func() {}, err
+	}
+
+	stmt, release, err := adapter.Statements.prepare(ctx, adapter.Tx, statement)
 	if err != nil {
-		return 0, 0, adapter.ErrorFunc(err)
+		return nil, release, err
 	}
 
-	lastID, _ := res.LastInsertId()
-	rowCount, _ := res.RowsAffected()
+	rows, err := stmt.QueryContext(ctx, args...)
+	return rows, release, err
+}
+
+// exec performs exec using cached prepared statement if adapter has statement cache.
+func (adapter *Adapter) exec(ctx context.Context, statement string, args []interface{}) (sql.Result, error) {
+	if adapter.Statements == nil {
+		if adapter.Tx != nil {
+			return adapter.Tx.ExecContext(ctx, statement, args...)
+		}
 
-	return lastID, rowCount, nil
+		return adapter.DB.ExecContext(ctx, statement, args...)
+	}
+
+	stmt, release, err := adapter.Statements.prepare(ctx, adapter.Tx, statement)
+	defer release()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.ExecContext(ctx, args...)
+}
+
+// rewrite replaces ? placeholders outside of quoted string with dialect's placeholder.
+// Escaped ?? is written as single ?, so operators such as postgres jsonb ?| can be used.
+func (adapter *Adapter) rewrite(statement string) string {
+	if !strings.Contains(statement, "?") {
+		return statement
+	}
+
+	if adapter.Dialect.Placeholder(1) == "?" && !strings.Contains(statement, "??") {
+		return statement
+	}
+
+	var buffer bytes.Buffer
+	var quote byte
+	count := 0
+
+	// placeholders, quotes and escapes are ascii, so the statement can be scanned byte by byte.
+	for i := 0; i < len(statement); i++ {
+		ch := statement[i]
+
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?' && i+1 < len(statement) && statement[i+1] == '?':
+			i++
+		case ch == '?':
+			count++
+			buffer.WriteString(adapter.Dialect.Placeholder(count))
+			continue
+		}
+
+		buffer.WriteByte(ch)
+	}
+
+	return buffer.String()
+}
+
+// observe starts a span using adapter's observer if it's set.
+func (adapter *Adapter) observe(ctx context.Context, op string) (context.Context, func(grimoire.SpanResult)) {
+	if adapter.Observer == nil {
+		return ctx, func(grimoire.SpanResult) {}
+	}
+
+	return adapter.Observer.Start(ctx, op)
+}
+
+// context returns context used to begin the transaction, so commit and rollback span can be nested under the same span.
+func (adapter *Adapter) context() context.Context {
+	if adapter.ctx == nil {
+		return context.Background()
+	}
+
+	return adapter.ctx
+}
+
+// instrument sends query event to instrumenters, rows is the number of rows returned or affected by the query.
+func (adapter *Adapter) instrument(ctx context.Context, instrumenters []grimoire.Instrumenter, statement string, args []interface{}, start time.Time, rows int64, err error) {
+	if len(instrumenters) == 0 {
+		return
+	}
+
+	grimoire.Instrument(instrumenters, grimoire.QueryEvent{
+		Context:       ctx,
+		Statement:     statement,
+		Args:          args,
+		Duration:      time.Since(start),
+		Rows:          rows,
+		Error:         err,
+		InTransaction: adapter.Tx != nil,
+	})
+}
+
+// error maps context cancellation to grimoire's error before passing it to ErrorFunc.
+func (adapter *Adapter) error(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return errors.CanceledError(ctx.Err().Error())
+	}
+
+	return adapter.ErrorFunc(err)
 }
@@ -1,13 +1,17 @@
 package sql
 
 import (
+	"context"
 	db "database/sql"
+	"io"
 	"testing"
 
 	paranoid "github.com/Fs02/go-paranoid"
 	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
 	"github.com/Fs02/grimoire/changeset"
 	"github.com/Fs02/grimoire/errors"
+	"github.com/Fs02/grimoire/observer"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,8 +19,7 @@ import (
 func open() (*Adapter, error) {
 	var err error
 	adapter := &Adapter{
-		Placeholder:   "?",
-		Ordinal:       false,
+		Dialect:       StandardDialect("?", false),
 		IncrementFunc: func(Adapter) int { return 1 },
 		ErrorFunc:     func(err error) error { return err },
 	}
@@ -24,7 +27,7 @@ func open() (*Adapter, error) {
 	// simplified tests using sqlite backend.
 	adapter.DB, err = db.Open("sqlite3", "file::memory:?mode=memory&cache=shared")
 
-	_, _, execerr := adapter.Exec(`CREATE TABLE test (
+	_, _, execerr := adapter.Exec(context.Background(), `CREATE TABLE test (
 		id INTEGER PRIMARY KEY,
 		name STRING
 	);`, nil)
@@ -34,7 +37,8 @@ func open() (*Adapter, error) {
 }
 
 func TestAdapterNew(t *testing.T) {
-	assert.NotNil(t, New("?", false, nil, nil))
+	assert.NotNil(t, NewWithDialect(StandardDialect("?", false), nil, nil))
+	assert.Equal(t, StandardDialect("$", true), New("$", true, nil, nil).Dialect)
 }
 
 func TestAdapterCount(t *testing.T) {
@@ -74,6 +78,21 @@ func TestAdapterInsert(t *testing.T) {
 	assert.Nil(t, grimoire.New(adapter).From("test").Insert(nil, ch, ch))
 }
 
+func TestAdapterInsertAllDuplicateKey(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	adapter.DuplicateKey = true
+	ch := changeset.Change(struct{ Name string }{Name: "name"})
+	query := grimoire.New(adapter).From("test").OnConflict("id").DoNothing()
+
+	assert.Equal(t, errors.UnexpectedError("conflict resolution of multiple records is not supported"), query.Insert(nil, ch, ch))
+	assert.Equal(t, 0, grimoire.New(adapter).From("test").Where(c.Eq(c.I("name"), "name")).MustCount())
+}
+
 func TestAdapterUpdate(t *testing.T) {
 	adapter, err := open()
 	if err != nil {
@@ -133,6 +152,291 @@ func TestAdapterTransactionRollback(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestAdapterTransactionNested(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+	repo.From("test").Delete()
+
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.From("test").Set("name", "outer").MustInsert(nil)
+
+		// inner transaction rolled back to savepoint.
+		err := repo.Transaction(func(repo grimoire.Repo) error {
+			repo.From("test").Set("name", "inner rollback").MustInsert(nil)
+			return errors.NotFoundError("rollback")
+		})
+		assert.Equal(t, errors.NotFoundError("rollback"), err)
+
+		// inner transaction released.
+		return repo.Transaction(func(repo grimoire.Repo) error {
+			repo.From("test").Set("name", "inner commit").MustInsert(nil)
+
+			return repo.Transaction(func(repo grimoire.Repo) error {
+				repo.From("test").Set("name", "inner nested commit").MustInsert(nil)
+				return nil
+			})
+		})
+	})
+	assert.Nil(t, err)
+
+	result := []struct {
+		Name string
+	}{}
+	repo.From("test").Order(c.Asc("id")).MustAll(&result)
+
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, "outer", result[0].Name)
+	assert.Equal(t, "inner commit", result[1].Name)
+	assert.Equal(t, "inner nested commit", result[2].Name)
+}
+
+func TestAdapterTransactionNestedRollback(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+	repo.From("test").Delete()
+
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.From("test").Set("name", "outer").MustInsert(nil)
+
+		assert.Nil(t, repo.Transaction(func(repo grimoire.Repo) error {
+			repo.From("test").Set("name", "inner").MustInsert(nil)
+			return nil
+		}))
+
+		return errors.NotFoundError("rollback")
+	})
+	assert.Equal(t, errors.NotFoundError("rollback"), err)
+
+	count, _ := repo.From("test").Count()
+	assert.Equal(t, 0, count)
+}
+
+func TestAdapterRaw(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+	affected, err := repo.Raw("INSERT INTO test (name) VALUES (?), (?);", "raw", "raw").Exec()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	result := []struct {
+		Name string
+	}{}
+	assert.Nil(t, repo.Raw("SELECT name FROM test WHERE name=?;", "raw").All(&result))
+	assert.Equal(t, 2, len(result))
+
+	count := struct {
+		Count int
+	}{}
+	assert.Nil(t, repo.Raw("SELECT COUNT(*) AS count FROM test WHERE name=?;", "raw").One(&count))
+	assert.Equal(t, 2, count.Count)
+}
+
+func TestAdapterIterate(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+	repo.Raw("INSERT INTO test (name) VALUES (?), (?);", "iterate", "iterate").MustExec()
+
+	it, err := repo.From("test").Where(c.Eq(c.I("name"), "iterate")).Iterate()
+	assert.Nil(t, err)
+	defer it.Close()
+
+	result := struct {
+		ID   int
+		Name string
+	}{}
+
+	count := 0
+	for {
+		if err := it.Next(&result); err == io.EOF {
+			break
+		} else {
+			assert.Nil(t, err)
+		}
+
+		assert.NotEqual(t, 0, result.ID)
+		assert.Equal(t, "iterate", result.Name)
+		count++
+	}
+
+	assert.Equal(t, 2, count)
+}
+
+func TestAdapterIterateError(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	_, err = grimoire.New(adapter).From("notexist").Iterate()
+	assert.NotNil(t, err)
+}
+
+func TestAdapterInstrument(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	var events []grimoire.QueryEvent
+	repo := grimoire.New(adapter)
+	repo.SetLogger()
+	repo.SetInstrumenter(grimoire.InstrumenterFunc(func(event grimoire.QueryEvent) {
+		events = append(events, event)
+	}))
+
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.Raw("INSERT INTO test (name) VALUES (?), (?);", "instrument", "instrument").MustExec()
+		return nil
+	})
+	assert.Nil(t, err)
+
+	result := []struct {
+		Name string
+	}{}
+	repo.From("test").Where(c.Eq(c.I("name"), "instrument")).MustAll(&result)
+
+	_, err = repo.From("notexist").Count()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 3, len(events))
+
+	assert.Equal(t, "raw_exec", events[0].Op)
+	assert.Equal(t, "INSERT INTO test (name) VALUES (?), (?);", events[0].Statement)
+	assert.Equal(t, []interface{}{"instrument", "instrument"}, events[0].Args)
+	assert.Equal(t, int64(2), events[0].Rows)
+	assert.True(t, events[0].InTransaction)
+
+	assert.Equal(t, "all", events[1].Op)
+	assert.Equal(t, "test", events[1].Collection)
+	assert.Equal(t, []interface{}{"instrument"}, events[1].Args)
+	assert.Equal(t, int64(2), events[1].Rows)
+	assert.False(t, events[1].InTransaction)
+	assert.Nil(t, events[1].Error)
+
+	assert.Equal(t, "count", events[2].Op)
+	assert.Equal(t, "notexist", events[2].Collection)
+	assert.NotNil(t, events[2].Error)
+}
+
+func TestAdapterObserver(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	recorder := observer.NewRecorder()
+	adapter.Observer = recorder
+	repo := grimoire.New(adapter)
+
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.Raw("INSERT INTO test (name) VALUES (?);", "observer").MustExec()
+
+		repo.Transaction(func(repo grimoire.Repo) error {
+			return errors.NotFoundError("rollback")
+		})
+
+		return nil
+	})
+	assert.Nil(t, err)
+
+	spans := recorder.Spans()
+	ops := make([]string, len(spans))
+	for i, span := range spans {
+		ops[i] = span.Op
+		assert.True(t, span.Finished)
+	}
+
+	assert.Equal(t, []string{"begin", "exec", "begin", "exec", "rollback", "exec", "commit"}, ops)
+	assert.Equal(t, "INSERT INTO test (name) VALUES (?);", spans[1].Statement)
+	assert.Equal(t, int64(1), spans[1].Rows)
+	assert.Equal(t, "SAVEPOINT sp1;", spans[2].Statement)
+	assert.Equal(t, spans[2].ID, spans[3].ParentID)
+	assert.Equal(t, "ROLLBACK TO SAVEPOINT sp1;", spans[4].Statement)
+	assert.Equal(t, spans[4].ID, spans[5].ParentID)
+}
+
+func TestAdapterRewrite(t *testing.T) {
+	adapter := NewWithDialect(StandardDialect("$", true), nil, nil)
+
+	assert.Equal(t, "SELECT * FROM users;", adapter.rewrite("SELECT * FROM users;"))
+	assert.Equal(t, "SELECT * FROM users WHERE id=$1 AND name=$2;", adapter.rewrite("SELECT * FROM users WHERE id=? AND name=?;"))
+	assert.Equal(t, "SELECT * FROM users WHERE note='?' AND id=$1;", adapter.rewrite("SELECT * FROM users WHERE note='?' AND id=?;"))
+	assert.Equal(t, `SELECT "?" FROM users WHERE note='it''s ?' AND id=$1;`, adapter.rewrite(`SELECT "?" FROM users WHERE note='it''s ?' AND id=?;`))
+	assert.Equal(t, "SELECT * FROM users WHERE tags ?| $1 AND data ? 'key' AND id=$2;", adapter.rewrite("SELECT * FROM users WHERE tags ??| ? AND data ?? 'key' AND id=?;"))
+
+	// not ordinal
+	adapter = NewWithDialect(StandardDialect("?", false), nil, nil)
+	assert.Equal(t, "SELECT * FROM users WHERE id=?;", adapter.rewrite("SELECT * FROM users WHERE id=?;"))
+	assert.Equal(t, "SELECT * FROM users WHERE data ? 'key' AND id=?;", adapter.rewrite("SELECT * FROM users WHERE data ?? 'key' AND id=?;"))
+}
+
+func TestAdapterRender(t *testing.T) {
+	var (
+		adapter = NewWithDialect(StandardDialect("$", true), nil, nil)
+		query   = grimoire.New(adapter).From("users").Where(c.Eq(c.I("id"), 1)).Lock(c.ForUpdate())
+		changes = map[string]interface{}{"name": "name", "age": 10}
+	)
+
+	tests := []struct {
+		Op        grimoire.Operation
+		Statement string
+		Args      []interface{}
+	}{
+		{grimoire.Operation{Op: "count", Query: query}, "SELECT COUNT(*) AS count FROM users WHERE id=$1;", []interface{}{1}},
+		{grimoire.Operation{Op: "all", Query: query}, "SELECT * FROM users WHERE id=$1 FOR UPDATE;", []interface{}{1}},
+		{grimoire.Operation{Op: "iterate", Query: query}, "SELECT * FROM users WHERE id=$1 FOR UPDATE;", []interface{}{1}},
+		{grimoire.Operation{Op: "insert", Query: query, Changes: changes}, "INSERT INTO users (age,name) VALUES ($1,$2);", []interface{}{10, "name"}},
+		{
+			grimoire.Operation{Op: "insert_all", Query: query, Fields: []string{"name"}, AllChanges: []map[string]interface{}{changes, changes}},
+			"INSERT INTO users (name) VALUES ($1),($2);",
+			[]interface{}{"name", "name"},
+		},
+		{grimoire.Operation{Op: "update", Query: query, Changes: changes}, "UPDATE users SET age=$1,name=$2 WHERE id=$3;", []interface{}{10, "name", 1}},
+		{grimoire.Operation{Op: "delete", Query: query}, "DELETE FROM users WHERE id=$1;", []interface{}{1}},
+		{grimoire.Operation{Op: "raw_exec", Statement: "DELETE FROM users WHERE id=?;", Args: []interface{}{1}}, "DELETE FROM users WHERE id=$1;", []interface{}{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Statement, func(t *testing.T) {
+			statement, args, err := adapter.Render(tt.Op)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Statement, statement)
+			assert.Equal(t, tt.Args, args)
+		})
+	}
+
+	_, _, err := adapter.Render(grimoire.Operation{Op: "begin"})
+	assert.Equal(t, errors.UnexpectedError("unsupported operation: begin"), err)
+
+	statement, args, err := query.ToSQL("delete")
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE FROM users WHERE id=$1;", statement)
+	assert.Equal(t, []interface{}{1}, args)
+}
+
 func TestAdapterInsertAllError(t *testing.T) {
 	adapter, err := open()
 	if err != nil {
@@ -180,7 +484,7 @@ func TestAdapterQueryError(t *testing.T) {
 
 	out := struct{}{}
 
-	_, err = adapter.Query(&out, "error", nil)
+	_, err = adapter.Query(context.Background(), &out, "error", nil)
 	assert.NotNil(t, err)
 }
 
@@ -191,6 +495,53 @@ func TestAdapterExecError(t *testing.T) {
 	}
 	defer adapter.Close()
 
-	_, _, err = adapter.Exec("error", nil)
+	_, _, err = adapter.Exec(context.Background(), "error", nil)
 	assert.NotNil(t, err)
 }
+
+func TestAdapterQueryCanceled(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out := struct{}{}
+
+	_, err = adapter.Query(ctx, &out, "SELECT * FROM test;", nil)
+	assert.Equal(t, errors.CanceledError("context canceled"), err)
+}
+
+func TestAdapterExecCanceled(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = adapter.Exec(ctx, "DELETE FROM test;", nil)
+	assert.Equal(t, errors.CanceledError("context canceled"), err)
+}
+
+func TestAdapterBeginCanceled(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = grimoire.New(adapter).WithContext(ctx).Transaction(func(repo grimoire.Repo) error {
+		return nil
+	})
+
+	assert.Equal(t, errors.CanceledError("context canceled"), err)
+}
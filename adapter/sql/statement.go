@@ -0,0 +1,216 @@
+package sql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StatementCache caches prepared statements of a database handle keyed by statement text.
+// Least recently used statement is closed when the number of statements exceeds its size.
+type StatementCache struct {
+	db        *sql.DB
+	size      int
+	mutex     sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// StatementCacheStats defines statistics of statement cache.
+type StatementCacheStats struct {
+	Size      int
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+// HitRate returns ratio of statements served from cache, it returns 0 if cache hasn't been used.
+func (stats StatementCacheStats) HitRate() float64 {
+	if stats.Hits+stats.Misses == 0 {
+		return 0
+	}
+
+	return float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+}
+
+// cachedStatement is reference counted, so evicted statement is only closed after it's no longer used.
+type cachedStatement struct {
+	statement string
+	stmt      *sql.Stmt
+	refs      int
+	evicted   bool
+}
+
+// NewStatementCache create a statement cache of db that holds at most size prepared statements.
+func NewStatementCache(db *sql.DB, size int) *StatementCache {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &StatementCache{
+		db:      db,
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+	}
+}
+
+// Stats returns current statistics of statement cache.
+func (cache *StatementCache) Stats() StatementCacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return StatementCacheStats{
+		Size:      cache.lru.Len(),
+		Hits:      cache.hits,
+		Misses:    cache.misses,
+		Evictions: cache.evictions,
+	}
+}
+
+// Close closes all cached statements, statements that are still in use are closed once they're released.
+func (cache *StatementCache) Close() error {
+	cache.mutex.Lock()
+	var closing []*sql.Stmt
+	for cache.lru.Len() > 0 {
+		if stmt := cache.evict(cache.lru.Back()); stmt != nil {
+			closing = append(closing, stmt)
+		}
+	}
+	cache.mutex.Unlock()
+
+	var err error
+	for _, stmt := range closing {
+		if cerr := stmt.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// prepare returns cached prepared statement, the statement is bound to tx if it's not nil.
+// Statement that isn't cached yet is prepared using tx and isn't cached, since preparing it on db needs another connection
+// while tx holds one, which never returns when the pool only has a single connection.
+// release must be called once the statement and its rows are no longer used, it's never nil.
+func (cache *StatementCache) prepare(ctx context.Context, tx *sql.Tx, statement string) (*sql.Stmt, func(), error) {
+	if tx == nil {
+		entry, err := cache.acquire(ctx, statement)
+		if err != nil {
+			return nil, func() {}, err
+		}
+
+		return entry.stmt, func() { cache.release(entry) }, nil
+	}
+
+	entry := cache.lookup(statement)
+	if entry == nil {
+		stmt, err := tx.PrepareContext(ctx, statement)
+		if err != nil {
+			return nil, func() {}, err
+		}
+
+		return stmt, func() { stmt.Close() }, nil
+	}
+
+	// statement prepared on db is re-bound to transaction's connection.
+	stmt := tx.StmtContext(ctx, entry.stmt)
+	return stmt, func() {
+		stmt.Close()
+		cache.release(entry)
+	}, nil
+}
+
+// lookup returns cached statement and counts the hit or miss, it returns nil if statement isn't cached.
+func (cache *StatementCache) lookup(statement string) *cachedStatement {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	elem, ok := cache.entries[statement]
+	if !ok {
+		cache.misses++
+		return nil
+	}
+
+	entry := elem.Value.(*cachedStatement)
+	entry.refs++
+	cache.hits++
+	cache.lru.MoveToFront(elem)
+
+	return entry
+}
+
+func (cache *StatementCache) acquire(ctx context.Context, statement string) (*cachedStatement, error) {
+	if entry := cache.lookup(statement); entry != nil {
+		return entry, nil
+	}
+
+	// statement is prepared without holding the lock, so slow prepare doesn't block other statements.
+	stmt, err := cache.db.PrepareContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mutex.Lock()
+	if elem, ok := cache.entries[statement]; ok {
+		// the same statement has been prepared concurrently, use the cached one.
+		entry := elem.Value.(*cachedStatement)
+		entry.refs++
+		cache.lru.MoveToFront(elem)
+		cache.mutex.Unlock()
+
+		stmt.Close()
+		return entry, nil
+	}
+
+	var closing []*sql.Stmt
+
+	entry := &cachedStatement{statement: statement, stmt: stmt, refs: 1}
+	cache.entries[statement] = cache.lru.PushFront(entry)
+
+	for cache.lru.Len() > cache.size {
+		cache.evictions++
+		if stmt := cache.evict(cache.lru.Back()); stmt != nil {
+			closing = append(closing, stmt)
+		}
+	}
+	cache.mutex.Unlock()
+
+	closeStatements(closing)
+	return entry, nil
+}
+
+func (cache *StatementCache) release(entry *cachedStatement) {
+	cache.mutex.Lock()
+	entry.refs--
+	closing := entry.evicted && entry.refs == 0
+	cache.mutex.Unlock()
+
+	if closing {
+		entry.stmt.Close()
+	}
+}
+
+// evict removes elem from cache and returns its statement if it can be closed immediately.
+// It must be called while holding the lock.
+func (cache *StatementCache) evict(elem *list.Element) *sql.Stmt {
+	entry := cache.lru.Remove(elem).(*cachedStatement)
+	delete(cache.entries, entry.statement)
+	entry.evicted = true
+
+	if entry.refs > 0 {
+		return nil
+	}
+
+	return entry.stmt
+}
+
+func closeStatements(stmts []*sql.Stmt) {
+	for _, stmt := range stmts {
+		stmt.Close()
+	}
+}
@@ -0,0 +1,152 @@
+package sql
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/c"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatementCacheStatsHitRate(t *testing.T) {
+	assert.Equal(t, float64(0), StatementCacheStats{}.HitRate())
+	assert.Equal(t, 0.75, StatementCacheStats{Hits: 3, Misses: 1}.HitRate())
+}
+
+func TestAdapterStatementCache(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	adapter.Statements = NewStatementCache(adapter.DB, 10)
+	repo := grimoire.New(adapter)
+
+	var result []struct {
+		ID   int
+		Name string
+	}
+
+	for i := 0; i < 3; i++ {
+		repo.Raw("INSERT INTO test (name) VALUES (?);", "cache").MustExec()
+		repo.From("test").Where(c.Eq(c.I("name"), "cache")).MustAll(&result)
+	}
+
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, StatementCacheStats{Size: 2, Hits: 4, Misses: 2}, adapter.Statements.Stats())
+	assert.Equal(t, 4.0/6.0, adapter.Statements.Stats().HitRate())
+}
+
+func TestAdapterStatementCacheEviction(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	adapter.Statements = NewStatementCache(adapter.DB, 1)
+	repo := grimoire.New(adapter)
+
+	repo.Raw("INSERT INTO test (name) VALUES (?), (?);", "evict", "evict").MustExec()
+
+	// statement of the open iterator is evicted, but it's only closed once the iterator is closed.
+	it, err := repo.From("test").Where(c.Eq(c.I("name"), "evict")).Iterate()
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, repo.From("test").Where(c.Eq(c.I("name"), "evict")).MustCount())
+	assert.Equal(t, StatementCacheStats{Size: 1, Misses: 3, Evictions: 2}, adapter.Statements.Stats())
+
+	var record struct {
+		ID   int
+		Name string
+	}
+
+	count := 0
+	for it.Next(&record) != io.EOF {
+		assert.Equal(t, "evict", record.Name)
+		count++
+	}
+
+	assert.Equal(t, 2, count)
+	assert.Nil(t, it.Close())
+	assert.Nil(t, adapter.Statements.Close())
+	assert.Equal(t, 0, adapter.Statements.Stats().Size)
+}
+
+func TestAdapterStatementCacheTransaction(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	adapter.Statements = NewStatementCache(adapter.DB, 10)
+	repo := grimoire.New(adapter)
+
+	// statement that's not cached is prepared using transaction and isn't cached.
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.Raw("INSERT INTO test (name) VALUES (?);", "transaction").MustExec()
+		repo.Raw("INSERT INTO test (name) VALUES (?);", "transaction").MustExec()
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, StatementCacheStats{Misses: 2}, adapter.Statements.Stats())
+
+	// cached statement is bound to transaction.
+	repo.Raw("INSERT INTO test (name) VALUES (?);", "transaction").MustExec()
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		repo.Raw("INSERT INTO test (name) VALUES (?);", "transaction").MustExec()
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, repo.From("test").Where(c.Eq(c.I("name"), "transaction")).MustCount())
+	assert.Equal(t, StatementCacheStats{Size: 2, Hits: 1, Misses: 4}, adapter.Statements.Stats())
+}
+
+func TestAdapterStatementCacheTransactionSingleConnection(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	adapter.DB.SetMaxOpenConns(1)
+	adapter.Statements = NewStatementCache(adapter.DB, 10)
+
+	// statement must not wait for another connection while the transaction holds the only one.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	repo := grimoire.New(adapter).WithContext(ctx)
+	err = repo.Transaction(func(repo grimoire.Repo) error {
+		_, err := repo.Raw("INSERT INTO test (name) VALUES (?);", "single").Exec()
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, repo.From("test").Where(c.Eq(c.I("name"), "single")).MustCount())
+}
+
+func TestAdapterStatementCachePrepareError(t *testing.T) {
+	adapter, err := open()
+	if err != nil {
+		panic(err)
+	}
+	defer adapter.Close()
+
+	adapter.Statements = NewStatementCache(adapter.DB, 10)
+
+	_, err = adapter.Query(context.Background(), nil, "SELECT * FROM unknown;", nil)
+	assert.NotNil(t, err)
+
+	_, _, err = adapter.Exec(context.Background(), "DELETE FROM unknown;", nil)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, StatementCacheStats{Misses: 2}, adapter.Statements.Stats())
+}
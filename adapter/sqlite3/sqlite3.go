@@ -1,10 +1,13 @@
 package sqlite3
 
 import (
+	"context"
 	db "database/sql"
+	"strings"
 
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/adapter/sql"
+	"github.com/Fs02/grimoire/c"
 	"github.com/Fs02/grimoire/errors"
 	"github.com/mattn/go-sqlite3"
 )
@@ -20,22 +23,131 @@ var _ grimoire.Adapter = (*Adapter)(nil)
 func Open(dsn string) (*Adapter, error) {
 	var err error
 
-	adapter := &Adapter{sql.New("?", false, errorFunc, incrementFunc)}
+	adapter := &Adapter{sql.NewWithDialect(Dialect{}, errorFunc, incrementFunc)}
+	adapter.LockFunc = lockFunc
 	adapter.DB, err = db.Open("sqlite3", dsn)
 
 	return adapter, err
 }
 
+// Dialect of sqlite, identifiers are quoted using double quote.
+type Dialect struct{}
+
+// Quote identifier using double quote.
+func (Dialect) Quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Placeholder returns ? placeholder.
+func (Dialect) Placeholder(n int) string {
+	return "?"
+}
+
+// Limit returns standard limit and offset clause.
+func (Dialect) Limit(limit int, offset int) string {
+	return sql.StandardLimit(limit, offset)
+}
+
+// Returning returns true, returning clause is supported since sqlite 3.35.
+func (Dialect) Returning() bool {
+	return true
+}
+
+// Bool returns 1 or 0, since boolean keywords are only recognized since sqlite 3.23.
+func (Dialect) Bool(value bool) string {
+	if value {
+		return "1"
+	}
+
+	return "0"
+}
+
+// Insert inserts a record to database and returns its id.
+// Conflicting insert uses returning clause, since last insert id is not updated when existing record is updated.
+func (adapter *Adapter) Insert(query grimoire.Query, changes map[string]interface{}, instrumenters ...grimoire.Instrumenter) (interface{}, error) {
+	if query.OnConflictClause.None() {
+		return adapter.Adapter.Insert(query, changes, instrumenters...)
+	}
+
+	statement, args := adapter.insertBuilder(query).Insert(query.Collection, changes)
+
+	var result struct {
+		ID int64
+	}
+
+	_, err := adapter.Query(query.Context(), &result, statement, args, instrumenters...)
+	return result.ID, err
+}
+
+// InsertAll inserts all record to database and returns its ids.
+func (adapter *Adapter) InsertAll(query grimoire.Query, fields []string, allchanges []map[string]interface{}, instrumenters ...grimoire.Instrumenter) ([]interface{}, error) {
+	if query.OnConflictClause.None() {
+		return adapter.Adapter.InsertAll(query, fields, allchanges, instrumenters...)
+	}
+
+	statement, args := adapter.insertBuilder(query).InsertAll(query.Collection, fields, allchanges)
+
+	var result []struct {
+		ID int64
+	}
+
+	_, err := adapter.Query(query.Context(), &result, statement, args, instrumenters...)
+
+	ids := make([]interface{}, 0, len(result))
+	for _, r := range result {
+		ids = append(ids, r.ID)
+	}
+
+	return ids, err
+}
+
+// Render returns statement and arguments of operation without executing it.
+// Conflicting insert is rendered with returning clause, the same way it's executed.
+func (adapter *Adapter) Render(op grimoire.Operation) (string, []interface{}, error) {
+	if op.Query.OnConflictClause.None() {
+		return adapter.Adapter.Render(op)
+	}
+
+	switch op.Op {
+	case "insert":
+		statement, args := adapter.insertBuilder(op.Query).Insert(op.Query.Collection, op.Changes)
+		return statement, args, nil
+	case "insert_all":
+		statement, args := adapter.insertBuilder(op.Query).InsertAll(op.Query.Collection, op.Fields, op.AllChanges)
+		return statement, args, nil
+	}
+
+	return adapter.Adapter.Render(op)
+}
+
+func (adapter *Adapter) insertBuilder(query grimoire.Query) *sql.Builder {
+	return adapter.Builder().Returning("id").OnConflict(query.OnConflictClause)
+}
+
+// Begin begins a new transaction.
+func (adapter *Adapter) Begin(ctx context.Context, opts *db.TxOptions) (grimoire.Adapter, error) {
+	txAdapter, err := adapter.Adapter.Begin(ctx, opts)
+
+	return &Adapter{txAdapter.(*sql.Adapter)}, err
+}
+
 func incrementFunc(adapter sql.Adapter) int {
 	// decrement
 	return -1
 }
 
+// lockFunc omits locking clause, sqlite locks the whole database when writing inside transaction.
+func lockFunc(lock c.Lock) string {
+	return ""
+}
+
 func errorFunc(err error) error {
 	if err == nil {
 		return nil
-	} else if e, ok := err.(sqlite3.Error); ok && e.ExtendedCode == sqlite3.ErrConstraintUnique {
+	} else if e, ok := err.(sqlite3.Error); ok && (e.ExtendedCode == sqlite3.ErrConstraintUnique || e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
 		return errors.DuplicateError(e.Error(), "")
+	} else if e, ok := err.(sqlite3.Error); ok && e.Code == sqlite3.ErrBusy {
+		return errors.SerializationError(e.Error())
 	}
 
 	return err
@@ -1,30 +1,31 @@
 package sqlite3
 
 import (
+	"context"
+	db "database/sql"
 	"os"
 	"testing"
+	"time"
 
-	"github.com/Fs02/go-paranoid"
 	"github.com/Fs02/grimoire"
 	"github.com/Fs02/grimoire/adapter/specs"
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
 	"github.com/Fs02/grimoire/errors"
 	sqlite3 "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 )
 
-func init() {
-	adapter, err := Open(dsn())
-	if err != nil {
-		panic(err)
+func setup(repo grimoire.Repo) error {
+	if _, err := repo.Raw(`DROP TABLE IF EXISTS addresses;`).Exec(); err != nil {
+		return err
 	}
-	defer adapter.Close()
 
-	_, _, err = adapter.Exec(`DROP TABLE IF EXISTS addresses;`, nil)
-	paranoid.Panic(err)
-	_, _, err = adapter.Exec(`DROP TABLE IF EXISTS users;`, nil)
-	paranoid.Panic(err)
+	if _, err := repo.Raw(`DROP TABLE IF EXISTS users;`).Exec(); err != nil {
+		return err
+	}
 
-	_, _, err = adapter.Exec(`CREATE TABLE users (
+	if _, err := repo.Raw(`CREATE TABLE users (
 		id INTEGER PRIMARY KEY,
 		name VARCHAR(30) NOT NULL DEFAULT '',
 		gender VARCHAR(10) NOT NULL DEFAULT 'male',
@@ -32,18 +33,22 @@ func init() {
 		note varchar(50),
 		created_at DATETIME,
 		updated_at DATETIME
-	);`, nil)
-	paranoid.Panic(err)
+	);`).Exec(); err != nil {
+		return err
+	}
 
-	_, _, err = adapter.Exec(`CREATE TABLE addresses (
+	if _, err := repo.Raw(`CREATE TABLE addresses (
 		id INTEGER PRIMARY KEY,
 		user_id INTEGER,
 		address VARCHAR(60) NOT NULL DEFAULT '',
 		created_at DATETIME,
 		updated_at DATETIME,
 		FOREIGN KEY (user_id) REFERENCES users(id)
-	);`, nil)
-	paranoid.Panic(err)
+	);`).Exec(); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func dsn() string {
@@ -60,36 +65,12 @@ func TestSpecs(t *testing.T) {
 		panic(err)
 	}
 	defer adapter.Close()
-	repo := grimoire.New(adapter)
-
-	// Query Specs
-	specs.Query(t, repo)
-	specs.QueryJoin(t, repo)
-	specs.QueryNotFound(t, repo)
-
-	// Count Specs
-	specs.Count(t, repo)
-
-	// Insert Specs
-	specs.Insert(t, repo)
-	specs.InsertAll(t, repo)
-	specs.InsertSet(t, repo)
-
-	// Update Specs
-	specs.Update(t, repo)
-	specs.UpdateWhere(t, repo)
-	specs.UpdateSet(t, repo)
 
-	// Put Specs
-	specs.SaveInsert(t, repo)
-	specs.SaveInsertAll(t, repo)
-	specs.SaveUpdate(t, repo)
-
-	// Delete specs
-	specs.Delete(t, repo)
-
-	// Transaction specs
-	specs.Transaction(t, repo)
+	specs.Suite{
+		Adapter: adapter,
+		Setup:   setup,
+		Skip:    []specs.Capability{specs.CapabilitySerialization},
+	}.Run(t)
 }
 
 func TestAdapterInsertAllError(t *testing.T) {
@@ -139,7 +120,7 @@ func TestAdapterQueryError(t *testing.T) {
 
 	out := struct{}{}
 
-	_, err = adapter.Query(&out, "error", nil)
+	_, err = adapter.Query(context.Background(), &out, "error", nil)
 	assert.NotNil(t, err)
 }
 
@@ -150,7 +131,7 @@ func TestAdapterExecError(t *testing.T) {
 	}
 	defer adapter.Close()
 
-	_, _, err = adapter.Exec("error", nil)
+	_, _, err = adapter.Exec(context.Background(), "error", nil)
 	assert.NotNil(t, err)
 }
 
@@ -163,7 +144,107 @@ func TestAdapterError(t *testing.T) {
 	duperr := errors.DuplicateError(rawerr.Error(), "")
 	assert.Equal(t, duperr, errorFunc(rawerr))
 
+	rawerr = sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintPrimaryKey}
+	duperr = errors.DuplicateError(rawerr.Error(), "")
+	assert.Equal(t, duperr, errorFunc(rawerr))
+
+	// Busy Error
+	rawerr = sqlite3.Error{Code: sqlite3.ErrBusy}
+	assert.Equal(t, errors.SerializationError(rawerr.Error()), errorFunc(rawerr))
+
 	// other errors
 	err := errors.UnexpectedError("error")
 	assert.Equal(t, err, errorFunc(err))
 }
+
+func TestLockFunc(t *testing.T) {
+	assert.Equal(t, "", lockFunc(c.ForUpdate()))
+	assert.Equal(t, "", lockFunc(c.ForShare().NoWait()))
+}
+
+func TestDialect(t *testing.T) {
+	dialect := Dialect{}
+
+	assert.Equal(t, `"order"`, dialect.Quote("order"))
+	assert.Equal(t, `"na""me"`, dialect.Quote(`na"me`))
+	assert.Equal(t, "?", dialect.Placeholder(2))
+	assert.Equal(t, "LIMIT 10 OFFSET 20", dialect.Limit(10, 20))
+	assert.True(t, dialect.Returning())
+	assert.Equal(t, "1", dialect.Bool(true))
+	assert.Equal(t, "0", dialect.Bool(false))
+}
+
+func TestAdapterRender(t *testing.T) {
+	adapter, err := Open(dsn())
+	assert.Nil(t, err)
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+
+	tests := []struct {
+		op        string
+		query     grimoire.Query
+		statement string
+	}{
+		{"all", repo.From("users").Find(1), `SELECT * FROM "users" WHERE "users"."id"=?;`},
+		{"insert", repo.From("users").Set("name", "name"), `INSERT INTO "users" ("name") VALUES (?);`},
+		{"insert", repo.From("users").Set("id", 1).Set("name", "name").OnConflict("id").DoUpdate(), `INSERT INTO "users" ("id","name") VALUES (?,?) ON CONFLICT ("id") DO UPDATE SET "name"=EXCLUDED."name" RETURNING "id";`},
+		{"update", repo.From("users").Find(1).Set("name", "name"), `UPDATE "users" SET "name"=? WHERE "users"."id"=?;`},
+		{"delete", repo.From("users").Find(1), `DELETE FROM "users" WHERE "users"."id"=?;`},
+	}
+
+	for _, test := range tests {
+		statement, _, err := test.query.ToSQL(test.op)
+		assert.Nil(t, err)
+		assert.Equal(t, test.statement, statement)
+	}
+}
+
+func TestAdapterSoftDelete(t *testing.T) {
+	adapter, err := Open(dsn())
+	assert.Nil(t, err)
+	defer adapter.Close()
+
+	repo := grimoire.New(adapter)
+	repo.Raw(`DROP TABLE IF EXISTS soft_users;`).MustExec()
+	repo.Raw(`CREATE TABLE soft_users (
+		id INTEGER PRIMARY KEY,
+		name VARCHAR(30) NOT NULL DEFAULT '',
+		deleted_at DATETIME
+	);`).MustExec()
+
+	type SoftUser struct {
+		ID        int64
+		Name      string
+		DeletedAt *time.Time
+	}
+
+	type NullSoftUser struct {
+		ID        int64
+		Name      string
+		DeletedAt db.NullTime
+	}
+
+	var (
+		user     SoftUser
+		nullUser NullSoftUser
+		ch       = changeset.Cast(SoftUser{}, map[string]interface{}{"name": "soft"}, []string{"name"})
+	)
+
+	assert.Nil(t, repo.From("soft_users").Insert(&user, ch))
+	assert.Nil(t, user.DeletedAt)
+
+	assert.Nil(t, repo.From("soft_users").Find(user.ID).One(&user))
+	assert.Nil(t, repo.From("soft_users").Find(user.ID).One(&nullUser))
+	assert.False(t, nullUser.DeletedAt.Valid)
+
+	assert.Nil(t, repo.From("soft_users").Find(user.ID).Delete(&user))
+	assert.NotNil(t, user.DeletedAt)
+
+	assert.True(t, repo.From("soft_users").Find(user.ID).One(&SoftUser{}).(errors.Error).NotFoundError())
+	assert.Nil(t, repo.From("soft_users").Find(user.ID).WithDeleted().One(&user))
+	assert.NotNil(t, user.DeletedAt)
+
+	assert.Nil(t, repo.From("soft_users").Find(user.ID).WithDeleted().One(&nullUser))
+	assert.True(t, nullUser.DeletedAt.Valid)
+}
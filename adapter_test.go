@@ -1,6 +1,10 @@
 package grimoire
 
 import (
+	"context"
+	"database/sql"
+	"reflect"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -10,6 +14,15 @@ type TestAdapter struct {
 
 var _ Adapter = (*TestAdapter)(nil)
 
+// matchQuery matches query argument without comparing its repo,
+// useful when the query is executed using transaction's repo.
+func matchQuery(expected Query) interface{} {
+	return mock.MatchedBy(func(query Query) bool {
+		expected.repo, query.repo = nil, nil
+		return reflect.DeepEqual(expected, query)
+	})
+}
+
 func (adapter TestAdapter) Open(dsn string) error {
 	args := adapter.Called(dsn)
 	return args.Error(0)
@@ -20,37 +33,53 @@ func (adapter TestAdapter) Close() error {
 	return args.Error(0)
 }
 
-func (adapter TestAdapter) Count(query Query, logger ...Logger) (int, error) {
+func (adapter TestAdapter) Count(query Query, instrumenter ...Instrumenter) (int, error) {
 	args := adapter.Called(query)
 	return args.Int(0), args.Error(1)
 }
 
-func (adapter TestAdapter) All(query Query, doc interface{}, logger ...Logger) (int, error) {
+func (adapter TestAdapter) All(query Query, doc interface{}, instrumenter ...Instrumenter) (int, error) {
 	args := adapter.Called(query, doc)
 	return args.Int(0), args.Error(1)
 }
 
-func (adapter TestAdapter) Insert(query Query, ch map[string]interface{}, logger ...Logger) (interface{}, error) {
+func (adapter TestAdapter) Iterate(query Query, instrumenter ...Instrumenter) (Iterator, error) {
+	args := adapter.Called(query)
+	it, _ := args.Get(0).(Iterator)
+	return it, args.Error(1)
+}
+
+func (adapter TestAdapter) Insert(query Query, ch map[string]interface{}, instrumenter ...Instrumenter) (interface{}, error) {
 	args := adapter.Called(query, ch)
 	return args.Get(0), args.Error(1)
 }
 
-func (adapter TestAdapter) InsertAll(query Query, fields []string, chs []map[string]interface{}, logger ...Logger) ([]interface{}, error) {
+func (adapter TestAdapter) InsertAll(query Query, fields []string, chs []map[string]interface{}, instrumenter ...Instrumenter) ([]interface{}, error) {
 	args := adapter.Called(query, chs)
 	return args.Get(0).([]interface{}), args.Error(1)
 }
 
-func (adapter TestAdapter) Update(query Query, ch map[string]interface{}, logger ...Logger) error {
+func (adapter TestAdapter) Update(query Query, ch map[string]interface{}, instrumenter ...Instrumenter) (int64, error) {
 	args := adapter.Called(query, ch)
-	return args.Error(0)
+	return int64(args.Int(0)), args.Error(1)
 }
 
-func (adapter TestAdapter) Delete(query Query, logger ...Logger) error {
+func (adapter TestAdapter) Delete(query Query, instrumenter ...Instrumenter) (int64, error) {
 	args := adapter.Called(query)
-	return args.Error(0)
+	return int64(args.Int(0)), args.Error(1)
+}
+
+func (adapter TestAdapter) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenter ...Instrumenter) (int64, error) {
+	ret := adapter.Called(out, statement, args)
+	return ret.Get(0).(int64), ret.Error(1)
+}
+
+func (adapter TestAdapter) RawExec(ctx context.Context, statement string, args []interface{}, instrumenter ...Instrumenter) (int64, int64, error) {
+	ret := adapter.Called(statement, args)
+	return ret.Get(0).(int64), ret.Get(1).(int64), ret.Error(2)
 }
 
-func (adapter TestAdapter) Begin() (Adapter, error) {
+func (adapter TestAdapter) Begin(ctx context.Context, opts *sql.TxOptions) (Adapter, error) {
 	args := adapter.Called()
 	return adapter, args.Error(0)
 }
@@ -64,3 +93,23 @@ func (adapter TestAdapter) Rollback() error {
 	args := adapter.Called()
 	return args.Error(0)
 }
+
+func (adapter TestAdapter) Render(op Operation) (string, []interface{}, error) {
+	args := adapter.Called(op.Op, op.Query, op.Changes)
+	statementArgs, _ := args.Get(1).([]interface{})
+	return args.String(0), statementArgs, args.Error(2)
+}
+
+type TestIterator struct {
+	mock.Mock
+}
+
+func (iterator *TestIterator) Next(record interface{}) error {
+	args := iterator.Called(record)
+	return args.Error(0)
+}
+
+func (iterator *TestIterator) Close() error {
+	args := iterator.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,357 @@
+package grimoire
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+)
+
+// assocChange defines changes of an association generated by changeset.CastAssoc.
+type assocChange struct {
+	association
+	field string
+	chs   []*changeset.Changeset
+}
+
+// insertWithAssoc inserts records alongside its associations inside a transaction.
+func (query Query) insertWithAssoc(record interface{}, chs []*changeset.Changeset) error {
+	var ids []interface{}
+
+	err := query.transaction(func(repo Repo) error {
+		query.repo = &repo
+
+		for _, ch := range chs {
+			id, err := query.insertOne(ch)
+			if err != nil {
+				return err
+			}
+
+			ids = append(ids, id)
+		}
+
+		if record == nil {
+			return nil
+		}
+
+		for _, assoc := range getAssocChanges(chs[0]) {
+			query = query.Preload(assoc.field)
+		}
+
+		var err error
+		if len(ids) == 1 {
+			err = query.Find(ids[0]).One(record)
+		} else {
+			err = query.Where(c.In(c.I("id"), ids...)).All(record)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return afterInsert(record)
+	})
+
+	return errors.Wrap(err)
+}
+
+func (query Query) insertOne(ch *changeset.Changeset) (interface{}, error) {
+	if err := beforeInsert([]*changeset.Changeset{ch}); err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]interface{})
+	cloneChangeset(changes, ch.Changes())
+	putTimestamp(changes, "created_at", ch.Types())
+	putTimestamp(changes, "updated_at", ch.Types())
+	cloneQuery(changes, query.Changes)
+
+	assocs := getAssocChanges(ch)
+
+	// belongs to association needs to be inserted first, so its key can be used as foreign key.
+	for _, assoc := range assocs {
+		if !assoc.belongsTo {
+			continue
+		}
+
+		id, err := query.assocQuery(assoc.association).insertOne(assoc.chs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		changes[assoc.parentKey] = referenceKey(assoc.childKey, id, assoc.chs[0].Changes())
+	}
+
+	query = query.resolveConflict(changesFields(changes))
+	id, err := query.repo.adapter.Insert(query, changes, query.instrumenters("insert")...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assoc := range assocs {
+		if assoc.belongsTo || len(assoc.chs) == 0 {
+			continue
+		}
+
+		key := referenceKey(assoc.parentKey, id, changes)
+		if err := query.assocQuery(assoc.association).Set(assoc.childKey, key).Insert(nil, assoc.chs...); err != nil {
+			return nil, err
+		}
+	}
+
+	return id, nil
+}
+
+// updateWithAssoc updates records alongside its associations inside a transaction.
+// Belongs to association will be updated if it's already exists, otherwise it'll be inserted.
+// Has one and has many children with primary key will be updated, new children will be inserted and the rest will be deleted.
+func (query Query) updateWithAssoc(record interface{}, ch *changeset.Changeset, changes map[string]interface{}, lock bool) (int64, error) {
+	var count int64
+
+	err := query.transaction(func(repo Repo) error {
+		query.repo = &repo
+		assocs := getAssocChanges(ch)
+
+		for _, assoc := range assocs {
+			if !assoc.belongsTo {
+				continue
+			}
+
+			child := assoc.chs[0]
+			if key, exist := existingKey(child, assoc.childKey); exist {
+				if err := query.assocQuery(assoc.association).Where(c.Eq(c.I(assoc.childKey), key)).Update(nil, child); err != nil {
+					return err
+				}
+
+				changes[assoc.parentKey] = key
+				continue
+			}
+
+			id, err := query.assocQuery(assoc.association).insertOne(child)
+			if err != nil {
+				return err
+			}
+
+			changes[assoc.parentKey] = referenceKey(assoc.childKey, id, child.Changes())
+		}
+
+		// retrieves parents before updating, so its keys can be used to update the associations.
+		parents, err := query.parents(ch, assocs)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) > 0 {
+			lockQuery, locked := query, false
+			if lock {
+				lockQuery, locked = query.optimisticLock(ch, changes)
+			}
+
+			if count, err = lockQuery.performUpdate(changes, locked); err != nil {
+				return err
+			}
+		}
+
+		for _, assoc := range assocs {
+			if assoc.belongsTo {
+				continue
+			}
+
+			if err := query.updateAssoc(assoc, parents); err != nil {
+				return err
+			}
+		}
+
+		if record == nil {
+			return nil
+		}
+
+		for _, assoc := range assocs {
+			query = query.Preload(assoc.field)
+		}
+
+		if err := query.All(record); err != nil {
+			return err
+		}
+
+		return afterUpdate(record)
+	})
+
+	return count, errors.Wrap(err)
+}
+
+// parents retrieves records to be updated without calling AfterFind hook, so its keys can be used to update the associations.
+func (query Query) parents(ch *changeset.Changeset, assocs []assocChange) ([]reflect.Value, error) {
+	for _, assoc := range assocs {
+		if assoc.belongsTo {
+			continue
+		}
+
+		parents := reflect.New(reflect.SliceOf(entityType(ch)))
+
+		q := query
+		q.Fields = []string{"*"}
+		q.PreloadFields = nil
+		if _, err := q.repo.adapter.All(q, parents.Interface(), q.instrumenters("all")...); err != nil {
+			return nil, err
+		}
+
+		return structValues(parents.Elem()), nil
+	}
+
+	return nil, nil
+}
+
+// updateAssoc updates has one and has many association of parents.
+// Children with primary key are updated in place, new children are inserted and the rest of existing children are deleted.
+func (query Query) updateAssoc(assoc assocChange, parents []reflect.Value) error {
+	if len(parents) == 0 {
+		return nil
+	}
+
+	_, keys := collectKeys(parents, assoc.parentKey)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var (
+		ids     []interface{}
+		updates []*changeset.Changeset
+		inserts []*changeset.Changeset
+	)
+
+	for _, child := range assoc.chs {
+		if id, exist := primaryKey(child); exist {
+			ids = append(ids, id)
+			updates = append(updates, child)
+		} else {
+			inserts = append(inserts, child)
+		}
+	}
+
+	deleteQuery := query.assocQuery(assoc.association).Where(c.In(c.I(assoc.childKey), keys...))
+	if len(ids) > 0 {
+		deleteQuery = deleteQuery.Where(c.Nin(c.I("id"), ids...))
+	}
+
+	if err := deleteQuery.Delete(); err != nil {
+		return err
+	}
+
+	// existing children are scoped to its parents, so children of other record won't be modified.
+	for i, child := range updates {
+		updateQuery := query.assocQuery(assoc.association).Where(c.Eq(c.I("id"), ids[i]), c.In(c.I(assoc.childKey), keys...))
+		if err := updateQuery.Update(nil, child); err != nil {
+			return err
+		}
+	}
+
+	if len(inserts) == 0 {
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := query.assocQuery(assoc.association).Set(assoc.childKey, key).Insert(nil, inserts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assocQuery returns a new query for association's collection.
+func (query Query) assocQuery(assoc association) Query {
+	return Query{
+		repo:       query.repo,
+		ctx:        query.ctx,
+		Collection: assoc.collection,
+		Fields:     []string{"*"},
+		UsePrimary: query.UsePrimary,
+	}
+}
+
+func getAssocChanges(ch *changeset.Changeset) []assocChange {
+	var assocs []assocChange
+
+	for field, value := range ch.Changes() {
+		var chs []*changeset.Changeset
+
+		switch v := value.(type) {
+		case *changeset.Changeset:
+			chs = []*changeset.Changeset{v}
+		case []*changeset.Changeset:
+			chs = v
+		default:
+			continue
+		}
+
+		assocs = append(assocs, assocChange{
+			association: getAssociation(entityType(ch), field),
+			field:       field,
+			chs:         chs,
+		})
+	}
+
+	// keeps the order of execution deterministic.
+	sort.Slice(assocs, func(i, j int) bool {
+		return assocs[i].field < assocs[j].field
+	})
+
+	return assocs
+}
+
+func hasAssocChanges(chs ...*changeset.Changeset) bool {
+	for _, ch := range chs {
+		for _, value := range ch.Changes() {
+			switch value.(type) {
+			case *changeset.Changeset, []*changeset.Changeset:
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func entityType(ch *changeset.Changeset) reflect.Type {
+	rt := reflect.TypeOf(ch.Entity())
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	return rt
+}
+
+// referenceKey returns value of referenced field, inserted id will be used if it's referencing id.
+func referenceKey(field string, id interface{}, changes map[string]interface{}) interface{} {
+	if field == "id" {
+		return id
+	}
+
+	return changes[field]
+}
+
+// primaryKey returns id of child from its changes or entity.
+func primaryKey(ch *changeset.Changeset) (interface{}, bool) {
+	if id, exist := ch.Changes()["id"]; exist && !isZero(id) {
+		return id, true
+	}
+
+	return existingKey(ch, "id")
+}
+
+// existingKey returns value of field from changeset's entity if it's not a zero value.
+func existingKey(ch *changeset.Changeset, field string) (interface{}, bool) {
+	value, exist := ch.Values()[field]
+	if !exist || isZero(value) {
+		return nil, false
+	}
+
+	return value, true
+}
+
+func isZero(value interface{}) bool {
+	return value == nil || reflect.DeepEqual(value, reflect.Zero(reflect.TypeOf(value)).Interface())
+}
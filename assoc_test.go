@@ -0,0 +1,206 @@
+package grimoire
+
+import (
+	"testing"
+
+	. "github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
+)
+
+func changeAddress(entity interface{}, params map[string]interface{}) *changeset.Changeset {
+	ch := changeset.Cast(entity, params, []string{"person_id"})
+	changeset.CastAssoc(ch, "city", func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
+		return changeset.Cast(entity, params, []string{"name"})
+	})
+
+	return ch
+}
+
+func TestQueryInsertAssocHasManyAndBelongsTo(t *testing.T) {
+	ch := changeset.Cast(Person{}, map[string]interface{}{
+		"name": "name",
+		"addresses": []map[string]interface{}{
+			{"city": map[string]interface{}{"name": "Bandung"}},
+		},
+	}, []string{"name"})
+	changeset.CastAssoc(ch, "addresses", changeAddress)
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people")
+
+	mock.On("Begin").Return(nil).
+		On("Insert", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("Insert", matchQuery(repo.From("cities")), map[string]interface{}{"name": "Bandung"}).Return(5, nil).
+		On("Insert", matchQuery(repo.From("addresses").Set("person_id", 1)), map[string]interface{}{"city_id": 5, "person_id": 1}).Return(10, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Insert(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertAssocErrorAndRollback(t *testing.T) {
+	ch := changeset.Cast(Person{}, map[string]interface{}{
+		"name": "name",
+		"profile": map[string]interface{}{
+			"bio": "bio",
+		},
+	}, []string{"name"})
+	changeset.CastAssoc(ch, "profile", func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
+		return changeset.Cast(entity, params, []string{"bio"})
+	})
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people")
+
+	mock.On("Begin").Return(nil).
+		On("Insert", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("Insert", matchQuery(repo.From("person_profiles").Set("person_id", 1)), map[string]interface{}{"bio": "bio", "person_id": 1}).Return(nil, errors.DuplicateError("duplicate", "person_id")).
+		On("Rollback").Return(nil)
+
+	assert.Equal(t, errors.DuplicateError("duplicate", "person_id"), query.Insert(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateAssocBelongsToExisting(t *testing.T) {
+	cityID := int64(5)
+	address := Address{ID: 10, CityID: &cityID, City: City{ID: 5, Name: "Jakarta"}}
+
+	ch := changeAddress(address, map[string]interface{}{
+		"city": map[string]interface{}{"name": "Bandung"},
+	})
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("addresses").Find(10)
+
+	mock.On("Begin").Return(nil).
+		On("Update", matchQuery(repo.From("cities").Where(Eq(I("id"), uint(5)))), map[string]interface{}{"name": "Bandung"}).Return(1, nil).
+		On("Update", matchQuery(query), map[string]interface{}{"city_id": uint(5)}).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateAssocHasManyReplace(t *testing.T) {
+	ch := changeset.Cast(Person{ID: 1}, map[string]interface{}{
+		"addresses": []map[string]interface{}{},
+	}, []string{"name"})
+	changeset.CastAssoc(ch, "addresses", changeAddress)
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("All", matchQuery(query), new([]Person)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}}
+	}).
+		On("Delete", matchQuery(repo.From("addresses").Where(In(I("person_id"), int64(1))))).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateAssocHasManyExisting(t *testing.T) {
+	ch := changeset.Cast(Person{ID: 1}, map[string]interface{}{
+		"addresses": []map[string]interface{}{
+			{"id": 10, "person_id": 1},
+			{"person_id": 1},
+		},
+	}, []string{"name"})
+	changeset.CastAssoc(ch, "addresses", func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
+		return changeset.Cast(entity, params, []string{"id", "person_id"})
+	})
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("All", matchQuery(query), new([]Person)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}}
+	}).
+		On("Delete", matchQuery(repo.From("addresses").Where(In(I("person_id"), int64(1)), Nin(I("id"), 10)))).Return(1, nil).
+		On("Update", matchQuery(repo.From("addresses").Where(Eq(I("id"), 10), In(I("person_id"), int64(1)))), map[string]interface{}{"id": 10, "person_id": 1}).Return(1, nil).
+		On("Insert", matchQuery(repo.From("addresses").Set("person_id", int64(1))), map[string]interface{}{"person_id": int64(1)}).Return(11, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+type FindFailPerson struct {
+	ID        int
+	Name      string
+	Addresses []Address
+}
+
+func (person *FindFailPerson) AfterFind() error {
+	return errors.UnexpectedError("AfterFind error")
+}
+
+func TestQueryUpdateAssocSkipAfterFind(t *testing.T) {
+	ch := changeset.Cast(FindFailPerson{ID: 1}, map[string]interface{}{
+		"name":      "name",
+		"addresses": []map[string]interface{}{},
+	}, []string{"name"})
+	changeset.CastAssoc(ch, "addresses", changeAddress)
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("All", matchQuery(query), new([]FindFailPerson)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]FindFailPerson) = []FindFailPerson{{ID: 1}}
+	}).
+		On("Update", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("Delete", matchQuery(repo.From("addresses").Where(In(I("find_fail_person_id"), int64(1))))).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateAssocInTransaction(t *testing.T) {
+	ch := changeset.Cast(Person{ID: 1}, map[string]interface{}{
+		"addresses": []map[string]interface{}{},
+	}, []string{"name"})
+	changeset.CastAssoc(ch, "addresses", changeAddress)
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Find(1)
+
+	mock.On("Begin").Return(nil).Once().
+		On("All", matchQuery(query), new([]Person)).Return(0, nil).
+		On("Commit").Return(nil)
+
+	err := repo.Transaction(func(repo Repo) error {
+		return repo.From("people").Find(1).Update(nil, ch)
+	})
+
+	assert.Nil(t, err)
+	mock.AssertExpectations(t)
+}
+
+func TestExistingKey(t *testing.T) {
+	ch := changeset.Cast(Person{ID: 1}, map[string]interface{}{}, []string{})
+
+	id, exist := existingKey(ch, "id")
+	assert.True(t, exist)
+	assert.Equal(t, 1, id)
+
+	_, exist = existingKey(ch, "name")
+	assert.False(t, exist)
+
+	_, exist = existingKey(ch, "profile")
+	assert.False(t, exist)
+}
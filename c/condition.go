@@ -277,6 +277,8 @@ func NotLike(col I, pattern string) Condition {
 }
 
 // Fragment add custom condition.
+// ? placeholders are bound to values in order, or named placeholders such as :min_age are bound from a map if it's the only value.
+// Use ?? to write a literal ?.
 func Fragment(expr I, values ...interface{}) Condition {
 	return Condition{
 		Type:  ConditionFragment,
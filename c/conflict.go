@@ -0,0 +1,25 @@
+package c
+
+// ConflictAction defines enumeration of actions taken when insert conflicts with existing record.
+type ConflictAction int
+
+const (
+	// ConflictError returns duplicate error when conflict occurred, this is the default action.
+	ConflictError ConflictAction = iota
+	// ConflictIgnore skips insertion of conflicting record.
+	ConflictIgnore
+	// ConflictUpdate updates conflicting record using inserted values.
+	ConflictUpdate
+)
+
+// OnConflict defines conflict resolution information of insert query.
+type OnConflict struct {
+	Fields       []string
+	Action       ConflictAction
+	UpdateFields []string
+}
+
+// None returns true if no conflict resolution is defined.
+func (onConflict OnConflict) None() bool {
+	return onConflict.Action == ConflictError
+}
@@ -0,0 +1,14 @@
+package c
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnConflictNone(t *testing.T) {
+	assert.True(t, OnConflict{}.None())
+	assert.True(t, OnConflict{Fields: []string{"email"}}.None())
+	assert.False(t, OnConflict{Action: ConflictIgnore}.None())
+	assert.False(t, OnConflict{Fields: []string{"email"}, Action: ConflictUpdate}.None())
+}
@@ -0,0 +1,58 @@
+package c
+
+// LockMode defines enumeration of row locking modes.
+type LockMode int
+
+const (
+	// LockNone is lock mode for query without locking clause.
+	LockNone LockMode = iota
+	// LockForUpdate is lock mode for exclusive row lock.
+	LockForUpdate
+	// LockForShare is lock mode for shared row lock.
+	LockForShare
+)
+
+// LockWait defines enumeration of behaviours when selected rows are already locked.
+type LockWait int
+
+const (
+	// LockWaitDefault waits until locked rows are released.
+	LockWaitDefault LockWait = iota
+	// LockNoWait returns error immediately if any selected row is locked.
+	LockNoWait
+	// LockSkipLocked skips rows that are locked.
+	LockSkipLocked
+)
+
+// Lock defines row locking information of select query.
+type Lock struct {
+	Mode LockMode
+	Wait LockWait
+}
+
+// ForUpdate locks selected rows as if for update.
+func ForUpdate() Lock {
+	return Lock{Mode: LockForUpdate}
+}
+
+// ForShare locks selected rows using shared lock, other transaction can read but not modify the rows.
+func ForShare() Lock {
+	return Lock{Mode: LockForShare}
+}
+
+// NoWait returns error instead of waiting when selected rows are locked.
+func (lock Lock) NoWait() Lock {
+	lock.Wait = LockNoWait
+	return lock
+}
+
+// SkipLocked skips selected rows that are locked.
+func (lock Lock) SkipLocked() Lock {
+	lock.Wait = LockSkipLocked
+	return lock
+}
+
+// None returns true if no locking is used.
+func (lock Lock) None() bool {
+	return lock.Mode == LockNone
+}
@@ -0,0 +1,24 @@
+package c
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForUpdate(t *testing.T) {
+	assert.Equal(t, Lock{Mode: LockForUpdate}, ForUpdate())
+	assert.Equal(t, Lock{Mode: LockForUpdate, Wait: LockNoWait}, ForUpdate().NoWait())
+	assert.Equal(t, Lock{Mode: LockForUpdate, Wait: LockSkipLocked}, ForUpdate().SkipLocked())
+}
+
+func TestForShare(t *testing.T) {
+	assert.Equal(t, Lock{Mode: LockForShare}, ForShare())
+	assert.Equal(t, Lock{Mode: LockForShare, Wait: LockNoWait}, ForShare().NoWait())
+	assert.Equal(t, Lock{Mode: LockForShare, Wait: LockSkipLocked}, ForShare().SkipLocked())
+}
+
+func TestLockNone(t *testing.T) {
+	assert.True(t, Lock{}.None())
+	assert.False(t, ForUpdate().None())
+}
@@ -15,7 +15,7 @@ var CastAssocErrorMessage = "{field} is invalid"
 type ChangeFunc func(interface{}, map[string]interface{}) *Changeset
 
 // CastAssoc casts association changes using changeset function.
-// Repo insert or update won't persist any changes generated by CastAssoc.
+// Repo insert and update will persist changes generated by CastAssoc alongside its parent inside a transaction.
 func CastAssoc(ch *Changeset, field string, fn ChangeFunc, opts ...Option) {
 	options := Options{
 		message: CastAssocErrorMessage,
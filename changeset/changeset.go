@@ -28,6 +28,11 @@ func (changeset *Changeset) Error() error {
 	return nil
 }
 
+// Entity of changeset.
+func (changeset *Changeset) Entity() interface{} {
+	return changeset.entity
+}
+
 // Changes of changeset.
 func (changeset *Changeset) Changes() map[string]interface{} {
 	return changeset.changes
@@ -0,0 +1,34 @@
+package grimoire
+
+import (
+	"github.com/Fs02/grimoire/c"
+)
+
+// Conflict defines information of conflict resolution that is being built using Query.OnConflict.
+type Conflict struct {
+	query  Query
+	fields []string
+}
+
+// DoNothing skips insertion of records that conflict with existing records.
+// Skipped records won't be returned to the inserted record.
+func (conflict Conflict) DoNothing() Query {
+	conflict.query.OnConflictClause = c.OnConflict{
+		Fields: conflict.fields,
+		Action: c.ConflictIgnore,
+	}
+
+	return conflict.query
+}
+
+// DoUpdate updates fields of existing records using inserted values when conflict occurred.
+// If no field specified, all inserted fields except conflict fields and created_at will be updated.
+func (conflict Conflict) DoUpdate(fields ...string) Query {
+	conflict.query.OnConflictClause = c.OnConflict{
+		Fields:       conflict.fields,
+		Action:       c.ConflictUpdate,
+		UpdateFields: fields,
+	}
+
+	return conflict.query
+}
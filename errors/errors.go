@@ -1,5 +1,9 @@
 package errors
 
+import (
+	"context"
+)
+
 // UnexpectedErrorCode defines default code for Unexpected Errors.
 var UnexpectedErrorCode = 0
 
@@ -12,6 +16,15 @@ var NotFoundErrorCode = 2
 // DuplicateErrorCode defines default code for Duplicate Errors.
 var DuplicateErrorCode = 3
 
+// CanceledErrorCode defines default code for Canceled Errors.
+var CanceledErrorCode = 4
+
+// SerializationErrorCode defines default code for Serialization Errors.
+var SerializationErrorCode = 5
+
+// StaleErrorCode defines default code for Stale Errors.
+var StaleErrorCode = 6
+
 // Error defines information about grimoire's error.
 type Error struct {
 	Message string `json:"message"`
@@ -44,6 +57,21 @@ func (e Error) DuplicateError() bool {
 	return e.Code == DuplicateErrorCode
 }
 
+// CanceledError returns true if error is an CanceledError.
+func (e Error) CanceledError() bool {
+	return e.Code == CanceledErrorCode
+}
+
+// SerializationError returns true if error is an SerializationError.
+func (e Error) SerializationError() bool {
+	return e.Code == SerializationErrorCode
+}
+
+// StaleError returns true if error is an StaleError.
+func (e Error) StaleError() bool {
+	return e.Code == StaleErrorCode
+}
+
 // New creates an error with custom image, field and error code.
 func New(message string, field string, code int) Error {
 	return Error{message, field, code}
@@ -83,14 +111,43 @@ func DuplicateError(message string, field string) Error {
 	}
 }
 
+// CanceledError creates a canceled error with custom message.
+func CanceledError(message string) Error {
+	return Error{
+		Message: message,
+		Code:    CanceledErrorCode,
+	}
+}
+
+// SerializationError creates a serialization error with custom message.
+// Serialization error is caused by serialization failure or deadlock, the transaction can be safely retried.
+func SerializationError(message string) Error {
+	return Error{
+		Message: message,
+		Code:    SerializationErrorCode,
+	}
+}
+
+// StaleError creates a stale error with custom message.
+// Stale error is caused by updating a record that has been modified since it was loaded.
+func StaleError(message string) Error {
+	return Error{
+		Message: message,
+		Code:    StaleErrorCode,
+	}
+}
+
 // Wrap errors as grimoire's error.
 // If error is grimoire error, it'll remain as is.
+// Context cancellation and deadline will be wrapped as canceled error.
 // Otherwise it'll be wrapped as unexpected error.
 func Wrap(err error) error {
 	if err == nil {
 		return nil
 	} else if _, ok := err.(Error); ok {
 		return err
+	} else if err == context.Canceled || err == context.DeadlineExceeded {
+		return CanceledError(err.Error())
 	} else {
 		return UnexpectedError(err.Error())
 	}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"context"
 	e "errors"
 	"testing"
 
@@ -51,8 +52,34 @@ func TestDuplicateError(t *testing.T) {
 	assert.True(t, err.DuplicateError())
 }
 
+func TestCanceledError(t *testing.T) {
+	err := CanceledError("error")
+
+	assert.Equal(t, "error", err.Error())
+	assert.Equal(t, "", err.Field)
+	assert.True(t, err.CanceledError())
+}
+
+func TestSerializationError(t *testing.T) {
+	err := SerializationError("error")
+
+	assert.Equal(t, "error", err.Error())
+	assert.Equal(t, "", err.Field)
+	assert.True(t, err.SerializationError())
+}
+
+func TestStaleError(t *testing.T) {
+	err := StaleError("error")
+
+	assert.Equal(t, "error", err.Error())
+	assert.Equal(t, "", err.Field)
+	assert.True(t, err.StaleError())
+}
+
 func TestWrap(t *testing.T) {
 	assert.Equal(t, nil, Wrap(nil))
 	assert.Equal(t, Error{}, Wrap(Error{}))
 	assert.Equal(t, UnexpectedError("error"), Wrap(e.New("error")))
+	assert.Equal(t, CanceledError("context canceled"), Wrap(context.Canceled))
+	assert.Equal(t, CanceledError("context deadline exceeded"), Wrap(context.DeadlineExceeded))
 }
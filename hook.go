@@ -0,0 +1,170 @@
+package grimoire
+
+import (
+	"reflect"
+
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+)
+
+// BeforeInsertHook can be implemented by changeset's entity to modify or validate the changeset before it's inserted.
+type BeforeInsertHook interface {
+	BeforeInsert(ch *changeset.Changeset) error
+}
+
+// AfterInsertHook can be implemented by record to perform action after it's inserted and retrieved from database.
+type AfterInsertHook interface {
+	AfterInsert() error
+}
+
+// BeforeUpdateHook can be implemented by changeset's entity to modify or validate the changeset before it's updated.
+type BeforeUpdateHook interface {
+	BeforeUpdate(ch *changeset.Changeset) error
+}
+
+// AfterUpdateHook can be implemented by record to perform action after it's updated and retrieved from database.
+type AfterUpdateHook interface {
+	AfterUpdate() error
+}
+
+// BeforeDeleteHook can be implemented by record to validate or perform action before it's deleted.
+type BeforeDeleteHook interface {
+	BeforeDelete() error
+}
+
+// AfterDeleteHook can be implemented by record to perform action after it's deleted.
+type AfterDeleteHook interface {
+	AfterDelete() error
+}
+
+// AfterFindHook can be implemented by record to perform action after it's retrieved from database.
+type AfterFindHook interface {
+	AfterFind() error
+}
+
+var (
+	typeAfterInsertHook  = reflect.TypeOf((*AfterInsertHook)(nil)).Elem()
+	typeAfterUpdateHook  = reflect.TypeOf((*AfterUpdateHook)(nil)).Elem()
+	typeBeforeDeleteHook = reflect.TypeOf((*BeforeDeleteHook)(nil)).Elem()
+	typeAfterDeleteHook  = reflect.TypeOf((*AfterDeleteHook)(nil)).Elem()
+)
+
+func beforeInsert(chs []*changeset.Changeset) error {
+	for _, ch := range chs {
+		if hook, ok := entityHook(ch.Entity()).(BeforeInsertHook); ok {
+			if err := hook.BeforeInsert(ch); err != nil {
+				return errors.Wrap(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func beforeUpdate(ch *changeset.Changeset) error {
+	if hook, ok := entityHook(ch.Entity()).(BeforeUpdateHook); ok {
+		return errors.Wrap(hook.BeforeUpdate(ch))
+	}
+
+	return nil
+}
+
+func afterInsert(record interface{}) error {
+	return eachRecord(record, func(rec interface{}) error {
+		if hook, ok := rec.(AfterInsertHook); ok {
+			return hook.AfterInsert()
+		}
+
+		return nil
+	})
+}
+
+func afterUpdate(record interface{}) error {
+	return eachRecord(record, func(rec interface{}) error {
+		if hook, ok := rec.(AfterUpdateHook); ok {
+			return hook.AfterUpdate()
+		}
+
+		return nil
+	})
+}
+
+func beforeDelete(record interface{}) error {
+	return eachRecord(record, func(rec interface{}) error {
+		if hook, ok := rec.(BeforeDeleteHook); ok {
+			return hook.BeforeDelete()
+		}
+
+		return nil
+	})
+}
+
+func afterDelete(record interface{}) error {
+	return eachRecord(record, func(rec interface{}) error {
+		if hook, ok := rec.(AfterDeleteHook); ok {
+			return hook.AfterDelete()
+		}
+
+		return nil
+	})
+}
+
+func afterFind(record interface{}) error {
+	return eachRecord(record, func(rec interface{}) error {
+		if hook, ok := rec.(AfterFindHook); ok {
+			return hook.AfterFind()
+		}
+
+		return nil
+	})
+}
+
+// entityHook returns pointer of changeset's entity, so hook with pointer receiver can be detected.
+// Entity that is not a pointer is copied, since the hook is expected to modify the changeset instead of the entity.
+func entityHook(entity interface{}) interface{} {
+	if entity == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(entity)
+	if rv.Kind() == reflect.Ptr {
+		return entity
+	}
+
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	return ptr.Interface()
+}
+
+// eachRecord calls fn with pointer of every struct in record, which can be a pointer to struct or a pointer to slice.
+func eachRecord(record interface{}, fn func(interface{}) error) error {
+	if record == nil {
+		return nil
+	}
+
+	for _, rv := range structValues(reflect.ValueOf(record)) {
+		if !rv.CanAddr() {
+			continue
+		}
+
+		if err := fn(rv.Addr().Interface()); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// hasHook checks whether record's type implements the hook, record doesn't need to contain any value.
+func hasHook(record interface{}, hook reflect.Type) bool {
+	if record == nil {
+		return false
+	}
+
+	rt := reflect.TypeOf(record)
+	for rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice {
+		rt = rt.Elem()
+	}
+
+	return reflect.PtrTo(rt).Implements(hook)
+}
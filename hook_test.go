@@ -0,0 +1,390 @@
+package grimoire
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
+)
+
+type HookUser struct {
+	ID    int
+	Name  string
+	Hooks []string
+	Fail  string
+}
+
+func (user *HookUser) call(hook string) error {
+	user.Hooks = append(user.Hooks, hook)
+	if user.Fail == hook {
+		return errors.UnexpectedError(hook + " error")
+	}
+
+	return nil
+}
+
+func (user *HookUser) BeforeInsert(ch *changeset.Changeset) error {
+	if name, ok := ch.Changes()["name"].(string); ok {
+		changeset.PutChange(ch, "name", strings.ToUpper(name))
+	}
+
+	return user.call("BeforeInsert")
+}
+
+func (user *HookUser) AfterInsert() error {
+	return user.call("AfterInsert")
+}
+
+func (user *HookUser) BeforeUpdate(ch *changeset.Changeset) error {
+	if name, ok := ch.Changes()["name"].(string); ok {
+		changeset.PutChange(ch, "name", strings.ToLower(name))
+	}
+
+	return user.call("BeforeUpdate")
+}
+
+func (user *HookUser) AfterUpdate() error {
+	return user.call("AfterUpdate")
+}
+
+func (user *HookUser) BeforeDelete() error {
+	return user.call("BeforeDelete")
+}
+
+func (user *HookUser) AfterDelete() error {
+	return user.call("AfterDelete")
+}
+
+func (user *HookUser) AfterFind() error {
+	return user.call("AfterFind")
+}
+
+func TestQueryInsertHook(t *testing.T) {
+	user := HookUser{}
+	ch := changeset.Cast(&user, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Begin").Return(nil).
+		On("Insert", matchQuery(query), map[string]interface{}{"name": "NAME"}).Return(1, nil).
+		On("All", matchQuery(query.Primary().Find(1).Limit(1)), &user).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Insert(&user, ch))
+	assert.Equal(t, []string{"BeforeInsert", "AfterFind", "AfterInsert"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertHookEntityValue(t *testing.T) {
+	ch := changeset.Cast(HookUser{}, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Insert", query, map[string]interface{}{"name": "NAME"}).Return(1, nil)
+
+	assert.Nil(t, query.Insert(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertHookInsideTransaction(t *testing.T) {
+	user := HookUser{}
+	ch := changeset.Cast(user, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+
+	mock.On("Begin").Return(nil).Once().
+		On("Insert", matchQuery(repo.From("users")), map[string]interface{}{"name": "NAME"}).Return(1, nil).
+		On("All", matchQuery(repo.From("users").Primary().Find(1).Limit(1)), &user).Return(1, nil).
+		On("Commit").Return(nil).Once()
+
+	assert.Nil(t, repo.Transaction(func(repo Repo) error {
+		return repo.From("users").Insert(&user, ch)
+	}))
+
+	assert.Equal(t, []string{"AfterFind", "AfterInsert"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertHookBeforeError(t *testing.T) {
+	user := HookUser{Fail: "BeforeInsert"}
+	ch := changeset.Cast(&user, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Begin").Return(nil).
+		On("Rollback").Return(nil)
+
+	assert.Equal(t, errors.UnexpectedError("BeforeInsert error"), query.Insert(&user, ch))
+	assert.Equal(t, []string{"BeforeInsert"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertHookAfterError(t *testing.T) {
+	user := HookUser{Fail: "AfterInsert"}
+	ch := changeset.Cast(user, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Begin").Return(nil).
+		On("Insert", matchQuery(query), map[string]interface{}{"name": "NAME"}).Return(1, nil).
+		On("All", matchQuery(query.Primary().Find(1).Limit(1)), &user).Return(1, nil).
+		On("Rollback").Return(nil)
+
+	assert.Equal(t, errors.UnexpectedError("AfterInsert error"), query.Insert(&user, ch))
+	mock.AssertExpectations(t)
+}
+
+type contextAdapter struct {
+	*TestAdapter
+	ctx context.Context
+}
+
+func (adapter *contextAdapter) Begin(ctx context.Context, opts *sql.TxOptions) (Adapter, error) {
+	adapter.ctx = ctx
+	return adapter.TestAdapter.Begin(ctx, opts)
+}
+
+func TestQueryInsertHookContext(t *testing.T) {
+	user := HookUser{}
+	ch := changeset.Cast(user, map[string]interface{}{"name": "name"}, []string{"name"})
+
+	ctx := context.WithValue(context.Background(), "key", "value")
+	mock := new(TestAdapter)
+	adapter := &contextAdapter{TestAdapter: mock}
+	query := Repo{adapter: adapter}.From("users").WithContext(ctx)
+
+	mock.On("Begin").Return(nil).
+		On("Insert", matchQuery(query), map[string]interface{}{"name": "NAME"}).Return(1, nil).
+		On("All", matchQuery(query.Primary().Find(1).Limit(1)), &user).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Insert(&user, ch))
+	assert.Equal(t, ctx, adapter.ctx)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertHookMultiple(t *testing.T) {
+	users := []HookUser{}
+	ch1 := changeset.Cast(HookUser{}, map[string]interface{}{"name": "name1"}, []string{"name"})
+	ch2 := changeset.Cast(HookUser{}, map[string]interface{}{"name": "name2"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	allchanges := []map[string]interface{}{
+		{"name": "NAME1"},
+		{"name": "NAME2"},
+	}
+
+	mock.On("Begin").Return(nil).
+		On("InsertAll", matchQuery(query), allchanges).Return([]interface{}{1, 2}, nil).
+		On("All", testmock.Anything, &users).Return(2, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]HookUser) = []HookUser{{ID: 1}, {ID: 2}}
+	}).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Insert(&users, ch1, ch2))
+	assert.Equal(t, []string{"AfterFind", "AfterInsert"}, users[0].Hooks)
+	assert.Equal(t, []string{"AfterFind", "AfterInsert"}, users[1].Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateHook(t *testing.T) {
+	user := HookUser{ID: 1}
+	ch := changeset.Cast(&user, map[string]interface{}{"name": "NAME"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("Update", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("All", matchQuery(query.Primary()), &user).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(&user, ch))
+	assert.Equal(t, []string{"BeforeUpdate", "AfterFind", "AfterUpdate"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateHookContext(t *testing.T) {
+	user := HookUser{ID: 1}
+	ch := changeset.Cast(&user, map[string]interface{}{"name": "NAME"}, []string{"name"})
+
+	ctx := context.WithValue(context.Background(), "key", "value")
+	mock := new(TestAdapter)
+	adapter := &contextAdapter{TestAdapter: mock}
+	query := Repo{adapter: adapter}.From("users").Find(1).WithContext(ctx)
+
+	mock.On("Begin").Return(nil).
+		On("Update", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("All", matchQuery(query.Primary()), &user).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(&user, ch))
+	assert.Equal(t, ctx, adapter.ctx)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateHookWithoutRecord(t *testing.T) {
+	ch := changeset.Cast(HookUser{}, map[string]interface{}{"name": "NAME"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	mock.On("Update", query, map[string]interface{}{"name": "name"}).Return(1, nil)
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateHookBeforeError(t *testing.T) {
+	ch := changeset.Cast(HookUser{Fail: "BeforeUpdate"}, map[string]interface{}{"name": "NAME"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	assert.Equal(t, errors.UnexpectedError("BeforeUpdate error"), query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateHookAfterError(t *testing.T) {
+	user := HookUser{ID: 1, Fail: "AfterUpdate"}
+	ch := changeset.Cast(user, map[string]interface{}{"name": "NAME"}, []string{"name"})
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("Update", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("All", matchQuery(query.Primary()), &user).Return(1, nil).
+		On("Rollback").Return(nil)
+
+	assert.Equal(t, errors.UnexpectedError("AfterUpdate error"), query.Update(&user, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteHook(t *testing.T) {
+	user := HookUser{ID: 1}
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("Delete", matchQuery(query)).Return(1, nil).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Delete(&user))
+	assert.Equal(t, []string{"BeforeDelete", "AfterDelete"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteHookInsideTransaction(t *testing.T) {
+	user := HookUser{ID: 1}
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+
+	mock.On("Begin").Return(nil).Once().
+		On("Delete", matchQuery(repo.From("users").Find(1))).Return(1, nil).
+		On("Commit").Return(nil).Once()
+
+	err := repo.Transaction(func(repo Repo) error {
+		return repo.From("users").Find(1).Delete(&user)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"BeforeDelete", "AfterDelete"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteHookBeforeError(t *testing.T) {
+	user := HookUser{ID: 1, Fail: "BeforeDelete"}
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("Rollback").Return(nil)
+
+	assert.Equal(t, errors.UnexpectedError("BeforeDelete error"), query.Delete(&user))
+	assert.Equal(t, []string{"BeforeDelete"}, user.Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteHookAfterError(t *testing.T) {
+	user := HookUser{ID: 1, Fail: "AfterDelete"}
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	mock.On("Begin").Return(nil).
+		On("Delete", matchQuery(query)).Return(1, nil).
+		On("Rollback").Return(nil)
+
+	count, err := query.DeleteAll(&user)
+	assert.Equal(t, errors.UnexpectedError("AfterDelete error"), err)
+	assert.Equal(t, int64(1), count)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryFindHook(t *testing.T) {
+	user := HookUser{}
+	users := []HookUser{{ID: 1}, {ID: 2}}
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("All", query.Limit(1), &user).Return(1, nil).
+		On("All", query, &users).Return(2, nil)
+
+	assert.Nil(t, query.One(&user))
+	assert.Equal(t, []string{"AfterFind"}, user.Hooks)
+
+	assert.Nil(t, query.All(&users))
+	assert.Equal(t, []string{"AfterFind"}, users[0].Hooks)
+	assert.Equal(t, []string{"AfterFind"}, users[1].Hooks)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryFindHookError(t *testing.T) {
+	user := HookUser{Fail: "AfterFind"}
+
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("All", query.Limit(1), &user).Return(1, nil)
+
+	assert.Equal(t, errors.UnexpectedError("AfterFind error"), query.One(&user))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryEachHook(t *testing.T) {
+	user := HookUser{}
+	it := new(TestIterator)
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(it, nil)
+	it.On("Next", &user).Return(nil).Twice().
+		On("Next", &user).Return(io.EOF).Once().
+		On("Close").Return(nil).Once()
+
+	assert.Nil(t, query.Each(&user, func() error {
+		return nil
+	}))
+
+	assert.Equal(t, []string{"AfterFind", "AfterFind"}, user.Hooks)
+	mock.AssertExpectations(t)
+	it.AssertExpectations(t)
+}
@@ -0,0 +1,56 @@
+package grimoire
+
+import (
+	"context"
+	"time"
+)
+
+// QueryEvent defines information about query executed by adapter.
+type QueryEvent struct {
+	Context       context.Context
+	Op            string
+	Collection    string
+	Statement     string
+	Args          []interface{}
+	Duration      time.Duration
+	Rows          int64
+	Error         error
+	InTransaction bool
+}
+
+// Instrumenter receives event of every query executed by adapter.
+// Instrument is called synchronously after the query is executed, so events are received in the same order as the queries.
+type Instrumenter interface {
+	Instrument(event QueryEvent)
+}
+
+// InstrumenterFunc is an adapter to allow the use of ordinary function as instrumenter.
+type InstrumenterFunc func(QueryEvent)
+
+// Instrument calls fn(event).
+func (fn InstrumenterFunc) Instrument(event QueryEvent) {
+	fn(event)
+}
+
+// Instrument sends event to multiple instrumenters.
+// This function intended to be used within adapter.
+func Instrument(instrumenters []Instrumenter, event QueryEvent) {
+	for _, i := range instrumenters {
+		i.Instrument(event)
+	}
+}
+
+// operation completes event with operation information that's unknown to adapter before passing it to instrumenters.
+// Op is one of count, all, iterate, insert, insert_all, update, delete, raw_query and raw_exec.
+type operation struct {
+	op            string
+	collection    string
+	instrumenters []Instrumenter
+}
+
+// Instrument event using operation information.
+func (o operation) Instrument(event QueryEvent) {
+	event.Op = o.op
+	event.Collection = o.collection
+	Instrument(o.instrumenters, event)
+}
@@ -0,0 +1,46 @@
+package grimoire
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	var events []QueryEvent
+	instrumenter := InstrumenterFunc(func(event QueryEvent) {
+		events = append(events, event)
+	})
+
+	event := QueryEvent{
+		Statement: "SELECT * FROM users WHERE id=?;",
+		Args:      []interface{}{1},
+		Duration:  time.Second,
+		Rows:      1,
+		Error:     errors.NotFoundError("error"),
+	}
+
+	Instrument([]Instrumenter{instrumenter, instrumenter}, event)
+	assert.Equal(t, []QueryEvent{event, event}, events)
+}
+
+func TestOperationInstrument(t *testing.T) {
+	var result QueryEvent
+	op := operation{
+		op:         "insert",
+		collection: "users",
+		instrumenters: []Instrumenter{InstrumenterFunc(func(event QueryEvent) {
+			result = event
+		})},
+	}
+
+	op.Instrument(QueryEvent{Statement: "INSERT INTO users (name) VALUES (?);", InTransaction: true})
+	assert.Equal(t, QueryEvent{
+		Op:            "insert",
+		Collection:    "users",
+		Statement:     "INSERT INTO users (name) VALUES (?);",
+		InTransaction: true,
+	}, result)
+}
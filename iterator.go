@@ -0,0 +1,8 @@
+package grimoire
+
+// Iterator defines interface of iterator returned by Query.Iterate.
+// Next scans the next result into record and returns io.EOF when there's no more result.
+type Iterator interface {
+	Next(record interface{}) error
+	Close() error
+}
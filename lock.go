@@ -0,0 +1,86 @@
+package grimoire
+
+import (
+	"reflect"
+
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/azer/snakecase"
+)
+
+// optimisticLock returns query that only matches records with the version that is being changed,
+// and sets the incremented version to changes.
+// Version is taken from changes if it's set, otherwise the version of changeset's entity is used if the entity is a loaded record,
+// which has non-zero primary key, so changeset casted from zero-value entity doesn't match version zero.
+// Query is returned as is if the entity doesn't support optimistic locking.
+func (query Query) optimisticLock(ch *changeset.Changeset, changes map[string]interface{}) (Query, bool) {
+	field, ok := lockVersionField(recordType(ch.Entity()))
+	if !ok {
+		return query, false
+	}
+
+	version, exist := changes[field]
+	if !exist {
+		if isZero(ch.Values()["id"]) {
+			return query, false
+		}
+
+		version, exist = ch.Values()[field]
+	}
+
+	if !exist || version == nil {
+		return query, false
+	}
+
+	changes[field] = nextVersion(version)
+
+	return query.Where(c.Eq(c.I(query.Collection+"."+field), version)), true
+}
+
+// lockVersionField returns column name of optimistic lock version field.
+// Record supports optimistic locking if its struct has LockVersion field or a field tagged with `lock_version:"true"`,
+// the field must be an integer.
+func lockVersionField(rt reflect.Type) (string, bool) {
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return "", false
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.Name != "LockVersion" && f.Tag.Get("lock_version") != "true" {
+			continue
+		}
+
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			continue
+		}
+
+		if tag := f.Tag.Get("db"); tag != "" {
+			return tag, tag != "-"
+		}
+
+		return snakecase.SnakeCase(f.Name), true
+	}
+
+	return "", false
+}
+
+// nextVersion returns version incremented by one, using the same type as version.
+func nextVersion(version interface{}) interface{} {
+	rv := reflect.ValueOf(version)
+	next := reflect.New(rv.Type()).Elem()
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		next.SetInt(rv.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		next.SetUint(rv.Uint() + 1)
+	default:
+		return version
+	}
+
+	return next.Interface()
+}
@@ -0,0 +1,149 @@
+package grimoire
+
+import (
+	"testing"
+
+	. "github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/changeset"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type LockedUser struct {
+	ID          int64
+	Name        string
+	LockVersion int
+}
+
+type TaggedLockedUser struct {
+	ID      int64
+	Name    string
+	Version uint `db:"version" lock_version:"true"`
+}
+
+func TestLockVersionField(t *testing.T) {
+	tests := []struct {
+		record interface{}
+		field  string
+		ok     bool
+	}{
+		{LockedUser{}, "lock_version", true},
+		{&TaggedLockedUser{}, "version", true},
+		{User{}, "", false},
+		{struct{ LockVersion string }{}, "", false},
+		{struct {
+			LockVersion int `db:"-"`
+		}{}, "-", false},
+		{nil, "", false},
+	}
+
+	for _, tt := range tests {
+		field, ok := lockVersionField(recordType(tt.record))
+		assert.Equal(t, tt.field, field)
+		assert.Equal(t, tt.ok, ok)
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	assert.Equal(t, 2, nextVersion(1))
+	assert.Equal(t, int64(2), nextVersion(int64(1)))
+	assert.Equal(t, uint(2), nextVersion(uint(1)))
+	assert.Equal(t, "1", nextVersion("1"))
+}
+
+func TestQuerySaveOptimisticLock(t *testing.T) {
+	var (
+		user    = LockedUser{ID: 1, Name: "name", LockVersion: 2}
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("locked_users").Find(1)
+		changes = map[string]interface{}{"name": "name", "lock_version": 3}
+	)
+
+	mock.On("Update", query.Where(Eq(I("locked_users.lock_version"), 2)), changes).Return(1, nil).Once()
+	mock.On("All", query.Primary(), &user).Return(1, nil).Once()
+
+	assert.Nil(t, query.Save(&user))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateOptimisticLock(t *testing.T) {
+	var (
+		user    = TaggedLockedUser{ID: 1, Name: "name", Version: 2}
+		ch      = changeset.Cast(user, map[string]interface{}{"name": "new name"}, []string{"name"})
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("users").Find(1)
+		changes = map[string]interface{}{"name": "new name", "version": uint(3)}
+	)
+
+	mock.On("Update", query.Where(Eq(I("users.version"), uint(2))), changes).Return(1, nil).Once()
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateOptimisticLockGivenVersion(t *testing.T) {
+	var (
+		user    = TaggedLockedUser{ID: 1, Name: "name", Version: 2}
+		params  = map[string]interface{}{"name": "new name", "version": 1}
+		ch      = changeset.Cast(user, params, []string{"name", "version"})
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("users").Find(1)
+		changes = map[string]interface{}{"name": "new name", "version": 2}
+	)
+
+	mock.On("Update", query.Where(Eq(I("users.version"), 1)), changes).Return(1, nil).Once()
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateOptimisticLockStale(t *testing.T) {
+	var (
+		user    = LockedUser{ID: 1, Name: "name", LockVersion: 2}
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("locked_users").Find(1)
+		changes = map[string]interface{}{"name": "name", "lock_version": 3}
+	)
+
+	mock.On("Update", query.Where(Eq(I("locked_users.lock_version"), 2)), changes).Return(0, nil).Once()
+
+	err := query.Save(&user)
+	assert.Equal(t, errors.StaleError("record has been modified or deleted"), err)
+	assert.Equal(t, 2, user.LockVersion)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateOptimisticLockZeroEntity(t *testing.T) {
+	var (
+		ch      = changeset.Cast(LockedUser{}, map[string]interface{}{"name": "new name"}, []string{"name"})
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("locked_users").Find(1)
+		changes = map[string]interface{}{"name": "new name"}
+	)
+
+	mock.On("Update", query, changes).Return(1, nil).Once()
+
+	assert.Nil(t, query.Update(nil, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateAllOptimisticLock(t *testing.T) {
+	var (
+		user    = LockedUser{ID: 1, Name: "name", LockVersion: 2}
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("locked_users")
+		changes = map[string]interface{}{"name": "new name"}
+	)
+
+	mock.On("Update", query, changes).Return(2, nil).Twice()
+
+	count, err := query.UpdateAll(changeset.Cast(LockedUser{}, map[string]interface{}{"name": "new name"}, []string{"name"}))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = query.UpdateAll(changeset.Cast(user, map[string]interface{}{"name": "new name"}, []string{"name"}))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	mock.AssertExpectations(t)
+}
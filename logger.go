@@ -1,6 +1,7 @@
 package grimoire
 
 import (
+	"context"
 	"log"
 	"time"
 )
@@ -8,6 +9,26 @@ import (
 // Logger defines function signature for custom logger.
 type Logger func(string, time.Duration, error)
 
+// ContextLogger defines function signature for custom logger that requires query's context.
+type ContextLogger func(context.Context, string, time.Duration, error)
+
+// Instrument logs event using logger, it allows logger to be used as instrumenter.
+func (logger Logger) Instrument(event QueryEvent) {
+	logger(event.Statement, event.Duration, event.Error)
+}
+
+// Instrument logs event alongside its context using logger, it allows context logger to be used as instrumenter.
+func (logger ContextLogger) Instrument(event QueryEvent) {
+	logger(event.Context, event.Statement, event.Duration, event.Error)
+}
+
+// Bind context to logger, so it can be used as regular logger.
+func (logger ContextLogger) Bind(ctx context.Context) Logger {
+	return func(statement string, duration time.Duration, err error) {
+		logger(ctx, statement, duration, err)
+	}
+}
+
 // DefaultLogger log query suing standard log library.
 func DefaultLogger(query string, duration time.Duration, err error) {
 	if err != nil {
@@ -1,6 +1,7 @@
 package grimoire
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -20,3 +21,37 @@ func TestLogger(t *testing.T) {
 		Log([]Logger{DefaultLogger}, "", time.Second, nil)
 	})
 }
+
+func TestContextLoggerBind(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	var result context.Context
+	logger := ContextLogger(func(ctx context.Context, query string, duration time.Duration, err error) {
+		result = ctx
+	})
+
+	Log([]Logger{logger.Bind(ctx)}, "", time.Second, nil)
+	assert.Equal(t, ctx, result)
+}
+
+func TestLoggerInstrument(t *testing.T) {
+	var result string
+	logger := Logger(func(query string, duration time.Duration, err error) {
+		result = query
+	})
+
+	Instrument([]Instrumenter{logger}, QueryEvent{Statement: "SELECT * FROM users;"})
+	assert.Equal(t, "SELECT * FROM users;", result)
+}
+
+func TestContextLoggerInstrument(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	var result context.Context
+	logger := ContextLogger(func(ctx context.Context, query string, duration time.Duration, err error) {
+		result = ctx
+	})
+
+	Instrument([]Instrumenter{logger}, QueryEvent{Context: ctx})
+	assert.Equal(t, ctx, result)
+}
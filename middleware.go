@@ -0,0 +1,180 @@
+package grimoire
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Operation defines an adapter call that is passed through middleware.
+// Op is one of count, all, iterate, insert, insert_all, update, delete, raw_query, raw_exec, begin, commit and rollback,
+// only the fields related to the operation are set.
+type Operation struct {
+	Context       context.Context
+	Op            string
+	Query         Query
+	Record        interface{}
+	Fields        []string
+	Changes       map[string]interface{}
+	AllChanges    []map[string]interface{}
+	Statement     string
+	Args          []interface{}
+	TxOptions     *sql.TxOptions
+	Instrumenters []Instrumenter
+}
+
+// Result defines result of an adapter call, only the fields related to the operation are set.
+// Rows contains count of records for count, all and raw_query, and count of affected rows for update, delete and raw_exec.
+type Result struct {
+	Rows           int64
+	ID             interface{}
+	IDs            []interface{}
+	LastInsertedID int64
+	Iterator       Iterator
+	Adapter        Adapter
+	Error          error
+}
+
+// Handler performs an adapter operation.
+type Handler func(Operation) Result
+
+// Middleware wraps a handler, it can modify the operation before passing it to the next handler and modify its result.
+type Middleware func(next Handler) Handler
+
+// Chain wraps adapter with middlewares, the first middleware is the outermost one.
+// Adapter returned by Begin is wrapped using the same middlewares.
+func Chain(adapter Adapter, middlewares ...Middleware) Adapter {
+	return newChain(context.Background(), adapter, middlewares)
+}
+
+type chain struct {
+	adapter     Adapter
+	middlewares []Middleware
+	handler     Handler
+	ctx         context.Context
+}
+
+func newChain(ctx context.Context, adapter Adapter, middlewares []Middleware) *chain {
+	c := &chain{
+		adapter:     adapter,
+		middlewares: middlewares,
+		ctx:         ctx,
+	}
+
+	c.handler = c.perform
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c.handler = middlewares[i](c.handler)
+	}
+
+	return c
+}
+
+func (c *chain) Count(query Query, instrumenters ...Instrumenter) (int, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "count", Query: query, Instrumenters: instrumenters})
+	return int(result.Rows), result.Error
+}
+
+func (c *chain) All(query Query, doc interface{}, instrumenters ...Instrumenter) (int, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "all", Query: query, Record: doc, Instrumenters: instrumenters})
+	return int(result.Rows), result.Error
+}
+
+func (c *chain) Iterate(query Query, instrumenters ...Instrumenter) (Iterator, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "iterate", Query: query, Instrumenters: instrumenters})
+	return result.Iterator, result.Error
+}
+
+func (c *chain) Insert(query Query, changes map[string]interface{}, instrumenters ...Instrumenter) (interface{}, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "insert", Query: query, Changes: changes, Instrumenters: instrumenters})
+	return result.ID, result.Error
+}
+
+func (c *chain) InsertAll(query Query, fields []string, allchanges []map[string]interface{}, instrumenters ...Instrumenter) ([]interface{}, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "insert_all", Query: query, Fields: fields, AllChanges: allchanges, Instrumenters: instrumenters})
+	return result.IDs, result.Error
+}
+
+func (c *chain) Update(query Query, changes map[string]interface{}, instrumenters ...Instrumenter) (int64, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "update", Query: query, Changes: changes, Instrumenters: instrumenters})
+	return result.Rows, result.Error
+}
+
+func (c *chain) Delete(query Query, instrumenters ...Instrumenter) (int64, error) {
+	result := c.handler(Operation{Context: query.Context(), Op: "delete", Query: query, Instrumenters: instrumenters})
+	return result.Rows, result.Error
+}
+
+func (c *chain) RawQuery(ctx context.Context, out interface{}, statement string, args []interface{}, instrumenters ...Instrumenter) (int64, error) {
+	result := c.handler(Operation{Context: ctx, Op: "raw_query", Record: out, Statement: statement, Args: args, Instrumenters: instrumenters})
+	return result.Rows, result.Error
+}
+
+func (c *chain) RawExec(ctx context.Context, statement string, args []interface{}, instrumenters ...Instrumenter) (int64, int64, error) {
+	result := c.handler(Operation{Context: ctx, Op: "raw_exec", Statement: statement, Args: args, Instrumenters: instrumenters})
+	return result.LastInsertedID, result.Rows, result.Error
+}
+
+func (c *chain) Begin(ctx context.Context, opts *sql.TxOptions) (Adapter, error) {
+	result := c.handler(Operation{Context: ctx, Op: "begin", TxOptions: opts})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return newChain(ctx, result.Adapter, c.middlewares), nil
+}
+
+func (c *chain) Commit() error {
+	return c.handler(Operation{Context: c.ctx, Op: "commit"}).Error
+}
+
+func (c *chain) Rollback() error {
+	return c.handler(Operation{Context: c.ctx, Op: "rollback"}).Error
+}
+
+// Render renders operation using the wrapped adapter, it's not passed through middlewares since nothing is performed.
+func (c *chain) Render(op Operation) (string, []interface{}, error) {
+	return Render(c.adapter, op)
+}
+
+// perform calls the wrapped adapter, it's the innermost handler of the chain.
+// Context of operation is applied to its query, so context replaced by middleware is used by the wrapped adapter.
+func (c *chain) perform(op Operation) Result {
+	var (
+		result Result
+		rows   int
+	)
+
+	if op.Context != nil && op.Context != op.Query.Context() {
+		op.Query = op.Query.WithContext(op.Context)
+	}
+
+	switch op.Op {
+	case "count":
+		rows, result.Error = c.adapter.Count(op.Query, op.Instrumenters...)
+		result.Rows = int64(rows)
+	case "all":
+		rows, result.Error = c.adapter.All(op.Query, op.Record, op.Instrumenters...)
+		result.Rows = int64(rows)
+	case "iterate":
+		result.Iterator, result.Error = c.adapter.Iterate(op.Query, op.Instrumenters...)
+	case "insert":
+		result.ID, result.Error = c.adapter.Insert(op.Query, op.Changes, op.Instrumenters...)
+	case "insert_all":
+		result.IDs, result.Error = c.adapter.InsertAll(op.Query, op.Fields, op.AllChanges, op.Instrumenters...)
+	case "update":
+		result.Rows, result.Error = c.adapter.Update(op.Query, op.Changes, op.Instrumenters...)
+	case "delete":
+		result.Rows, result.Error = c.adapter.Delete(op.Query, op.Instrumenters...)
+	case "raw_query":
+		result.Rows, result.Error = c.adapter.RawQuery(op.Context, op.Record, op.Statement, op.Args, op.Instrumenters...)
+	case "raw_exec":
+		result.LastInsertedID, result.Rows, result.Error = c.adapter.RawExec(op.Context, op.Statement, op.Args, op.Instrumenters...)
+	case "begin":
+		result.Adapter, result.Error = c.adapter.Begin(op.Context, op.TxOptions)
+	case "commit":
+		result.Error = c.adapter.Commit()
+	case "rollback":
+		result.Error = c.adapter.Rollback()
+	}
+
+	return result
+}
@@ -0,0 +1,266 @@
+package grimoire
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func recordMiddleware(ops *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(op Operation) Result {
+			*ops = append(*ops, op.Op)
+			return next(op)
+		}
+	}
+}
+
+func TestUse(t *testing.T) {
+	var (
+		ops     []string
+		adapter = new(TestAdapter)
+		repo    = New(adapter, Use(recordMiddleware(&ops)))
+		query   = repo.From("users")
+	)
+
+	adapter.On("Count", matchQuery(query)).Return(1, nil).Once()
+
+	count, err := query.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"count"}, ops)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainOrder(t *testing.T) {
+	var (
+		calls   []string
+		adapter = new(TestAdapter)
+		named   = func(name string) Middleware {
+			return func(next Handler) Handler {
+				return func(op Operation) Result {
+					calls = append(calls, name+" before")
+					result := next(op)
+					calls = append(calls, name+" after")
+					return result
+				}
+			}
+		}
+		query = New(adapter, Use(named("first"), named("second"))).From("users")
+	)
+
+	adapter.On("Delete", matchQuery(query)).Return(1, nil).Once()
+
+	assert.Nil(t, query.Delete())
+	assert.Equal(t, []string{"first before", "second before", "second after", "first after"}, calls)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainOperations(t *testing.T) {
+	var (
+		ops     []string
+		ctx     = context.Background()
+		adapter = new(TestAdapter)
+		chain   = Chain(adapter, recordMiddleware(&ops))
+		query   = Repo{}.From("users")
+		changes = map[string]interface{}{"name": "name"}
+		iter    = new(TestIterator)
+		record  []User
+	)
+
+	adapter.On("Count", query).Return(1, nil).Once()
+	adapter.On("All", query, &record).Return(2, nil).Once()
+	adapter.On("Iterate", query).Return(iter, nil).Once()
+	adapter.On("Insert", query, changes).Return(1, nil).Once()
+	adapter.On("InsertAll", query, []map[string]interface{}{changes}).Return([]interface{}{1}, nil).Once()
+	adapter.On("Update", query, changes).Return(1, nil).Once()
+	adapter.On("Delete", query).Return(1, nil).Once()
+	adapter.On("RawQuery", &record, "SELECT 1;", []interface{}(nil)).Return(int64(3), nil).Once()
+	adapter.On("RawExec", "DELETE FROM users;", []interface{}(nil)).Return(int64(4), int64(5), nil).Once()
+	adapter.On("Begin").Return(nil).Once()
+	adapter.On("Commit").Return(nil).Once()
+	adapter.On("Rollback").Return(nil).Once()
+
+	count, err := chain.Count(query)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	count, err = chain.All(query, &record)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	it, err := chain.Iterate(query)
+	assert.Nil(t, err)
+	assert.Equal(t, iter, it)
+
+	id, err := chain.Insert(query, changes)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, id)
+
+	ids, err := chain.InsertAll(query, []string{"name"}, []map[string]interface{}{changes})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{1}, ids)
+
+	rows, err := chain.Update(query, changes)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), rows)
+
+	rows, err = chain.Delete(query)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), rows)
+
+	rows, err = chain.RawQuery(ctx, &record, "SELECT 1;", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), rows)
+
+	lastInsertedID, rows, err := chain.RawExec(ctx, "DELETE FROM users;", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), lastInsertedID)
+	assert.Equal(t, int64(5), rows)
+
+	tx, err := chain.Begin(ctx, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, tx.Rollback())
+
+	assert.Equal(t, []string{
+		"count", "all", "iterate", "insert", "insert_all", "update", "delete",
+		"raw_query", "raw_exec", "begin", "commit", "rollback",
+	}, ops)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainModifyQuery(t *testing.T) {
+	var (
+		adapter = new(TestAdapter)
+		tenancy = func(next Handler) Handler {
+			return func(op Operation) Result {
+				op.Query = op.Query.Where(c.Eq(c.I("tenant_id"), 1))
+				return next(op)
+			}
+		}
+		repo = New(adapter, Use(tenancy))
+	)
+
+	adapter.On("Count", matchQuery(repo.From("users").Where(c.Eq(c.I("tenant_id"), 1)))).Return(1, nil).Once()
+
+	count, err := repo.From("users").Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainModifyContext(t *testing.T) {
+	type key struct{}
+
+	var (
+		adapter = new(TestAdapter)
+		ctx     = context.WithValue(context.Background(), key{}, "value")
+		timeout = func(next Handler) Handler {
+			return func(op Operation) Result {
+				op.Context = ctx
+				return next(op)
+			}
+		}
+		repo = New(adapter, Use(timeout))
+	)
+
+	adapter.On("Count", matchQuery(repo.From("users").WithContext(ctx))).Return(1, nil).Once().
+		On("Delete", matchQuery(repo.From("users").WithContext(ctx).Find(1))).Return(1, nil).Once()
+
+	count, err := repo.From("users").Count()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Nil(t, repo.From("users").Find(1).Delete())
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainModifyResult(t *testing.T) {
+	var (
+		adapter   = new(TestAdapter)
+		translate = func(next Handler) Handler {
+			return func(op Operation) Result {
+				result := next(op)
+				if result.Error != nil {
+					result.Error = errors.NotFoundError("translated")
+				}
+
+				return result
+			}
+		}
+		query = New(adapter, Use(translate)).From("users")
+	)
+
+	adapter.On("Count", matchQuery(query)).Return(0, errors.UnexpectedError("error")).Once()
+
+	_, err := query.Count()
+	assert.Equal(t, errors.NotFoundError("translated"), err)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainTransaction(t *testing.T) {
+	var (
+		ops     []string
+		adapter = new(TestAdapter)
+		repo    = New(adapter, Use(recordMiddleware(&ops)))
+		query   = repo.From("users")
+	)
+
+	adapter.On("Begin").Return(nil).Once()
+	adapter.On("Count", matchQuery(query)).Return(1, nil).Once()
+	adapter.On("Commit").Return(nil).Once()
+
+	err := repo.Transaction(func(repo Repo) error {
+		_, err := repo.From("users").Count()
+		return err
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"begin", "count", "commit"}, ops)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainBeginError(t *testing.T) {
+	var (
+		adapter = new(TestAdapter)
+		chain   = Chain(adapter)
+		err     = errors.UnexpectedError("error")
+	)
+
+	adapter.On("Begin").Return(err).Once()
+
+	tx, beginErr := chain.Begin(context.Background(), nil)
+	assert.Nil(t, tx)
+	assert.Equal(t, err, beginErr)
+
+	adapter.AssertExpectations(t)
+}
+
+func TestChainRender(t *testing.T) {
+	var (
+		ops     []string
+		adapter = new(TestAdapter)
+		repo    = New(adapter, Use(recordMiddleware(&ops)))
+		query   = repo.From("users")
+	)
+
+	adapter.On("Render", "all", matchQuery(query), map[string]interface{}(nil)).Return("SELECT * FROM users;", nil, nil).Once()
+
+	statement, args, err := query.ToSQL("all")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM users;", statement)
+	assert.Nil(t, args)
+	assert.Nil(t, ops)
+
+	adapter.AssertExpectations(t)
+}
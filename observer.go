@@ -0,0 +1,19 @@
+package grimoire
+
+import (
+	"context"
+)
+
+// Observer starts a span around each adapter call, the span is started using caller's context so it can be nested under caller's span.
+// Op is one of query, exec, begin, commit and rollback.
+// The returned context is used to perform the call, and finish is called once the call is completed.
+type Observer interface {
+	Start(ctx context.Context, op string) (context.Context, func(SpanResult))
+}
+
+// SpanResult defines result of adapter call observed by Observer.
+type SpanResult struct {
+	Statement string
+	Rows      int64
+	Error     error
+}
@@ -0,0 +1,91 @@
+// Package observer provides implementations of grimoire.Observer.
+package observer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Fs02/grimoire"
+)
+
+type spanKey struct{}
+
+// Span defines span recorded by Recorder.
+type Span struct {
+	ID        int
+	ParentID  int
+	Op        string
+	Statement string
+	Rows      int64
+	Error     error
+	StartedAt time.Time
+	EndedAt   time.Time
+	Finished  bool
+}
+
+// Recorder records spans in memory, it's intended to be used in tests.
+// Span ids keep increasing after reset, so they're unique for the lifetime of the recorder.
+type Recorder struct {
+	mutex  sync.Mutex
+	spans  []*Span
+	lastID int
+}
+
+var _ grimoire.Observer = (*Recorder)(nil)
+
+// NewRecorder creates an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Start records a new span, the span will be the parent of spans started using the returned context.
+func (recorder *Recorder) Start(ctx context.Context, op string) (context.Context, func(grimoire.SpanResult)) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	parentID, _ := ctx.Value(spanKey{}).(int)
+	recorder.lastID++
+
+	span := &Span{
+		ID:        recorder.lastID,
+		ParentID:  parentID,
+		Op:        op,
+		StartedAt: time.Now(),
+	}
+
+	recorder.spans = append(recorder.spans, span)
+
+	// span removed by reset is still finished, but it's no longer returned by Spans.
+	return context.WithValue(ctx, spanKey{}, span.ID), func(result grimoire.SpanResult) {
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+
+		span.Statement = result.Statement
+		span.Rows = result.Rows
+		span.Error = result.Error
+		span.EndedAt = time.Now()
+		span.Finished = true
+	}
+}
+
+// Spans returns copy of recorded spans in the order they're started.
+func (recorder *Recorder) Spans() []Span {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	spans := make([]Span, len(recorder.spans))
+	for i, span := range recorder.spans {
+		spans[i] = *span
+	}
+
+	return spans
+}
+
+// Reset removes all recorded spans, finishing span that's started before reset is ignored.
+func (recorder *Recorder) Reset() {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	recorder.spans = nil
+}
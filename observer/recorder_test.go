@@ -0,0 +1,64 @@
+package observer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+
+	ctx, finishBegin := recorder.Start(context.Background(), "begin")
+	_, finishQuery := recorder.Start(ctx, "query")
+
+	spans := recorder.Spans()
+	assert.Equal(t, 2, len(spans))
+	assert.False(t, spans[0].Finished)
+	assert.False(t, spans[1].Finished)
+
+	finishQuery(grimoire.SpanResult{Statement: "SELECT * FROM users;", Rows: 2})
+	finishBegin(grimoire.SpanResult{Error: errors.UnexpectedError("error")})
+
+	spans = recorder.Spans()
+	assert.Equal(t, 1, spans[0].ID)
+	assert.Equal(t, 0, spans[0].ParentID)
+	assert.Equal(t, "begin", spans[0].Op)
+	assert.Equal(t, errors.UnexpectedError("error"), spans[0].Error)
+	assert.True(t, spans[0].Finished)
+
+	assert.Equal(t, 2, spans[1].ID)
+	assert.Equal(t, 1, spans[1].ParentID)
+	assert.Equal(t, "query", spans[1].Op)
+	assert.Equal(t, "SELECT * FROM users;", spans[1].Statement)
+	assert.Equal(t, int64(2), spans[1].Rows)
+	assert.True(t, spans[1].Finished)
+	assert.False(t, spans[1].EndedAt.Before(spans[1].StartedAt))
+
+	recorder.Reset()
+	assert.Equal(t, 0, len(recorder.Spans()))
+}
+
+func TestRecorderResetOpenSpan(t *testing.T) {
+	recorder := NewRecorder()
+
+	ctx, finishBegin := recorder.Start(context.Background(), "begin")
+	recorder.Reset()
+
+	_, finishQuery := recorder.Start(ctx, "query")
+
+	assert.NotPanics(t, func() {
+		finishBegin(grimoire.SpanResult{})
+	})
+	finishQuery(grimoire.SpanResult{Statement: "SELECT * FROM users;"})
+
+	spans := recorder.Spans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, 2, spans[0].ID)
+	assert.Equal(t, 1, spans[0].ParentID)
+	assert.Equal(t, "query", spans[0].Op)
+	assert.True(t, spans[0].Finished)
+}
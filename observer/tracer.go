@@ -0,0 +1,58 @@
+package observer
+
+import (
+	"context"
+
+	"github.com/Fs02/grimoire"
+)
+
+// Tracer is shaped after OpenTelemetry's trace.Tracer, so it can be satisfied by a thin wrapper of OpenTelemetry's tracer without importing it here.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, TracerSpan)
+}
+
+// TracerSpan is shaped after OpenTelemetry's trace.Span.
+type TracerSpan interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute defines key and value of span's attribute, it's equivalent to OpenTelemetry's attribute.KeyValue.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+type tracing struct {
+	tracer Tracer
+}
+
+// Tracing creates observer that starts span using tracer.
+// Span is named after the operation, for example: grimoire.query, and statement is recorded using OpenTelemetry's database semantic conventions.
+func Tracing(tracer Tracer) grimoire.Observer {
+	return tracing{tracer: tracer}
+}
+
+// Start span using tracer.
+func (t tracing) Start(ctx context.Context, op string) (context.Context, func(grimoire.SpanResult)) {
+	ctx, span := t.tracer.Start(ctx, "grimoire."+op)
+
+	return ctx, func(result grimoire.SpanResult) {
+		attributes := []Attribute{{Key: "db.operation", Value: op}}
+		if result.Statement != "" {
+			attributes = append(attributes, Attribute{Key: "db.statement", Value: result.Statement})
+		}
+
+		if op == "query" || op == "exec" {
+			attributes = append(attributes, Attribute{Key: "db.rows", Value: result.Rows})
+		}
+
+		span.SetAttributes(attributes...)
+		if result.Error != nil {
+			span.RecordError(result.Error)
+		}
+
+		span.End()
+	}
+}
@@ -0,0 +1,74 @@
+package observer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Fs02/grimoire"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSpan struct {
+	name       string
+	attributes []Attribute
+	err        error
+	ended      bool
+}
+
+func (span *testSpan) SetAttributes(attributes ...Attribute) {
+	span.attributes = append(span.attributes, attributes...)
+}
+
+func (span *testSpan) RecordError(err error) {
+	span.err = err
+}
+
+func (span *testSpan) End() {
+	span.ended = true
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (tracer *testTracer) Start(ctx context.Context, spanName string) (context.Context, TracerSpan) {
+	span := &testSpan{name: spanName}
+	tracer.spans = append(tracer.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestTracing(t *testing.T) {
+	tracer := &testTracer{}
+	observer := Tracing(tracer)
+
+	ctx, finish := observer.Start(context.Background(), "query")
+	assert.NotNil(t, ctx.Value(spanKey{}))
+	assert.False(t, tracer.spans[0].ended)
+
+	finish(grimoire.SpanResult{Statement: "SELECT * FROM users;", Rows: 2})
+	assert.Equal(t, &testSpan{
+		name: "grimoire.query",
+		attributes: []Attribute{
+			{Key: "db.operation", Value: "query"},
+			{Key: "db.statement", Value: "SELECT * FROM users;"},
+			{Key: "db.rows", Value: int64(2)},
+		},
+		ended: true,
+	}, tracer.spans[0])
+}
+
+func TestTracingError(t *testing.T) {
+	tracer := &testTracer{}
+	observer := Tracing(tracer)
+
+	_, finish := observer.Start(context.Background(), "commit")
+	finish(grimoire.SpanResult{Error: errors.UnexpectedError("error")})
+
+	assert.Equal(t, &testSpan{
+		name:       "grimoire.commit",
+		attributes: []Attribute{{Key: "db.operation", Value: "commit"}},
+		err:        errors.UnexpectedError("error"),
+		ended:      true,
+	}, tracer.spans[0])
+}
@@ -0,0 +1,267 @@
+package grimoire
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/azer/snakecase"
+)
+
+// collectionNamer can be implemented by record to override its collection name.
+type collectionNamer interface {
+	Collection() string
+}
+
+// association defines information about association between parent and its children.
+type association struct {
+	index      int
+	collection string
+	elem       reflect.Type
+	many       bool
+	ptr        bool
+	belongsTo  bool
+	parentKey  string
+	childKey   string
+}
+
+// preload loads association defined by path into record.
+func (query Query) preload(record interface{}, path string) error {
+	rv := reflect.ValueOf(record)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic("grimoire: record must be a pointer")
+	}
+
+	return query.preloadValues(structValues(rv.Elem()), path)
+}
+
+func (query Query) preloadValues(parents []reflect.Value, path string) error {
+	if len(parents) == 0 {
+		return nil
+	}
+
+	field, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		field, rest = path[:i], path[i+1:]
+	}
+
+	assoc := getAssociation(parents[0].Type(), field)
+	keys, ids := collectKeys(parents, assoc.parentKey)
+
+	childIndex, ok := fieldIndexByName(assoc.elem, assoc.childKey)
+	if !ok {
+		panic("grimoire: field named (" + assoc.childKey + ") is not found in " + assoc.elem.String())
+	}
+
+	// reset association value, so previously loaded value won't leak.
+	for _, parent := range parents {
+		fv := parent.Field(assoc.index)
+		fv.Set(reflect.Zero(fv.Type()))
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	children := reflect.New(reflect.SliceOf(assoc.elem))
+	err := query.assocQuery(assoc).Where(c.In(c.I(assoc.childKey), ids...)).All(children.Interface())
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	children = children.Elem()
+
+	for i := 0; i < children.Len(); i++ {
+		child := children.Index(i)
+		key, ok := normalizeKey(child.Field(childIndex))
+		if !ok {
+			continue
+		}
+
+		for _, parent := range keys[key] {
+			setAssociation(parent.Field(assoc.index), child, assoc)
+		}
+	}
+
+	if rest == "" {
+		return nil
+	}
+
+	var nested []reflect.Value
+	for _, parent := range parents {
+		nested = append(nested, structValues(parent.Field(assoc.index))...)
+	}
+
+	return query.preloadValues(nested, rest)
+}
+
+func getAssociation(rt reflect.Type, field string) association {
+	index, ok := fieldIndexByName(rt, field)
+	if !ok {
+		panic("grimoire: field named (" + field + ") is not found in " + rt.String())
+	}
+
+	sf := rt.Field(index)
+	assoc := association{index: index}
+
+	ft := sf.Type
+	if ft.Kind() == reflect.Slice {
+		assoc.many = true
+		ft = ft.Elem()
+	}
+
+	if ft.Kind() == reflect.Ptr {
+		assoc.ptr = true
+		ft = ft.Elem()
+	}
+
+	if ft.Kind() != reflect.Struct {
+		panic("grimoire: field (" + field + ") is not a struct or slice of struct")
+	}
+
+	assoc.elem = ft
+	assoc.collection = collectionName(ft)
+
+	fk := sf.Tag.Get("fk")
+	ref := sf.Tag.Get("ref")
+
+	// belongs to if foreign key is defined in parent.
+	if _, belongsTo := fieldIndexByName(rt, defaultString(fk, field+"_id")); belongsTo && !assoc.many {
+		assoc.belongsTo = true
+		assoc.parentKey = defaultString(fk, field+"_id")
+		assoc.childKey = defaultString(ref, "id")
+	} else {
+		assoc.parentKey = defaultString(ref, "id")
+		assoc.childKey = defaultString(fk, snakecase.SnakeCase(rt.Name())+"_id")
+	}
+
+	return assoc
+}
+
+func setAssociation(fv reflect.Value, child reflect.Value, assoc association) {
+	if assoc.ptr {
+		ptr := reflect.New(assoc.elem)
+		ptr.Elem().Set(child)
+		child = ptr
+	}
+
+	if assoc.many {
+		fv.Set(reflect.Append(fv, child))
+	} else {
+		fv.Set(child)
+	}
+}
+
+// collectKeys groups parents using value of key field and returns its unique values.
+func collectKeys(parents []reflect.Value, field string) (map[interface{}][]reflect.Value, []interface{}) {
+	index, ok := fieldIndexByName(parents[0].Type(), field)
+	if !ok {
+		panic("grimoire: field named (" + field + ") is not found in " + parents[0].Type().String())
+	}
+
+	keys := make(map[interface{}][]reflect.Value)
+	ids := make([]interface{}, 0, len(parents))
+
+	for _, parent := range parents {
+		key, ok := normalizeKey(parent.Field(index))
+		if !ok {
+			continue
+		}
+
+		if _, exist := keys[key]; !exist {
+			ids = append(ids, key)
+		}
+
+		keys[key] = append(keys[key], parent)
+	}
+
+	return keys, ids
+}
+
+// normalizeKey dereferences pointer and converts numbers, so keys with different types can be compared.
+func normalizeKey(rv reflect.Value) (interface{}, bool) {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+
+	return rv.Interface(), true
+}
+
+// structValues returns addressable struct values contained in rv.
+func structValues(rv reflect.Value) []reflect.Value {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+
+		return structValues(rv.Elem())
+	case reflect.Slice:
+		values := make([]reflect.Value, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, structValues(rv.Index(i))...)
+		}
+
+		return values
+	case reflect.Struct:
+		return []reflect.Value{rv}
+	}
+
+	return nil
+}
+
+func fieldIndexByName(rt reflect.Type, name string) (int, bool) {
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+
+		if tag := f.Tag.Get("db"); tag != "" {
+			if tag == name {
+				return i, true
+			}
+		} else if snakecase.SnakeCase(f.Name) == name {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func collectionName(rt reflect.Type) string {
+	if namer, ok := reflect.New(rt).Interface().(collectionNamer); ok {
+		return namer.Collection()
+	}
+
+	return pluralize(snakecase.SnakeCase(rt.Name()))
+}
+
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "y") && len(name) > 1 && !strings.ContainsAny(name[len(name)-2:len(name)-1], "aeiou"):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "z"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	}
+
+	return name + "s"
+}
+
+func defaultString(s string, def string) string {
+	if s == "" {
+		return def
+	}
+
+	return s
+}
@@ -0,0 +1,177 @@
+package grimoire
+
+import (
+	"testing"
+
+	. "github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
+)
+
+type Person struct {
+	ID        int
+	Name      string
+	Addresses []Address
+	Profile   *Profile
+}
+
+type Profile struct {
+	ID       int
+	PersonID *int
+	Bio      string
+}
+
+func (Profile) Collection() string {
+	return "person_profiles"
+}
+
+type Address struct {
+	ID       int64
+	PersonID int64
+	CityID   *int64
+	City     City
+}
+
+type City struct {
+	ID   uint
+	Name string
+}
+
+func TestQueryPreloadHasMany(t *testing.T) {
+	people := []Person{}
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Preload("addresses")
+
+	mock.On("All", query, &people).Return(2, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}, {ID: 2}, {ID: 3}}
+	})
+
+	mock.On("All", repo.From("addresses").Where(In(I("person_id"), int64(1), int64(2), int64(3))), new([]Address)).Return(3, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Address) = []Address{{ID: 1, PersonID: 1}, {ID: 2, PersonID: 2}, {ID: 3, PersonID: 1}}
+	})
+
+	assert.Nil(t, query.All(&people))
+	assert.Equal(t, []Person{
+		{ID: 1, Addresses: []Address{{ID: 1, PersonID: 1}, {ID: 3, PersonID: 1}}},
+		{ID: 2, Addresses: []Address{{ID: 2, PersonID: 2}}},
+		{ID: 3},
+	}, people)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryPreloadHasOne(t *testing.T) {
+	person := Person{}
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Preload("profile")
+
+	mock.On("All", query.Limit(1), &person).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*Person) = Person{ID: 1, Profile: &Profile{ID: 10}}
+	})
+
+	personID := 1
+	mock.On("All", repo.From("person_profiles").Where(In(I("person_id"), int64(1))), new([]Profile)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Profile) = []Profile{{ID: 1, PersonID: &personID, Bio: "bio"}}
+	})
+
+	assert.Nil(t, query.One(&person))
+	assert.Equal(t, Person{ID: 1, Profile: &Profile{ID: 1, PersonID: &personID, Bio: "bio"}}, person)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryPreloadNestedBelongsTo(t *testing.T) {
+	person := Person{}
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Preload("addresses.city")
+
+	mock.On("All", query.Limit(1), &person).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*Person) = Person{ID: 1}
+	})
+
+	cityID := int64(5)
+	mock.On("All", repo.From("addresses").Where(In(I("person_id"), int64(1))), new([]Address)).Return(2, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Address) = []Address{{ID: 1, PersonID: 1, CityID: &cityID}, {ID: 2, PersonID: 1}}
+	})
+
+	mock.On("All", repo.From("cities").Where(In(I("id"), int64(5))), new([]City)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]City) = []City{{ID: 5, Name: "Bandung"}}
+	})
+
+	assert.Nil(t, query.One(&person))
+	assert.Equal(t, Person{ID: 1, Addresses: []Address{
+		{ID: 1, PersonID: 1, CityID: &cityID, City: City{ID: 5, Name: "Bandung"}},
+		{ID: 2, PersonID: 1},
+	}}, person)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryPreloadNoKeys(t *testing.T) {
+	address := Address{}
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("addresses").Preload("city")
+
+	mock.On("All", query.Limit(1), &address).Return(1, nil)
+
+	assert.Nil(t, query.One(&address))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryPreloadError(t *testing.T) {
+	people := []Person{}
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Preload("addresses")
+
+	mock.On("All", query, &people).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}}
+	})
+
+	mock.On("All", repo.From("addresses").Where(In(I("person_id"), int64(1))), new([]Address)).Return(0, errors.UnexpectedError("error"))
+
+	assert.Equal(t, errors.UnexpectedError("error"), query.All(&people))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryPreloadInvalidField(t *testing.T) {
+	person := Person{ID: 1}
+
+	assert.Panics(t, func() {
+		Repo{}.From("people").preload(&person, "unknown")
+	})
+
+	assert.Panics(t, func() {
+		Repo{}.From("people").preload(&person, "name")
+	})
+}
+
+type Owner struct {
+	ID     int
+	Cities []City
+}
+
+func TestQueryPreloadMissingForeignKey(t *testing.T) {
+	owner := Owner{ID: 1}
+
+	// child doesn't have the foreign key field, so it's reported before querying the children.
+	assert.PanicsWithValue(t, "grimoire: field named (owner_id) is not found in grimoire.City", func() {
+		Repo{}.From("owners").preload(&owner, "cities")
+	})
+}
+
+func TestPluralize(t *testing.T) {
+	tests := map[string]string{
+		"user":    "users",
+		"address": "addresses",
+		"city":    "cities",
+		"day":     "days",
+		"box":     "boxes",
+		"match":   "matches",
+	}
+
+	for name, expected := range tests {
+		assert.Equal(t, expected, pluralize(name))
+	}
+}
@@ -1,7 +1,11 @@
 package grimoire
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,23 +18,55 @@ import (
 
 // Query defines information about query generated by query builder.
 type Query struct {
-	repo            *Repo
-	Collection      string
-	Fields          []string
-	AsDistinct      bool
-	JoinClause      []c.Join
-	Condition       c.Condition
-	GroupFields     []string
-	HavingCondition c.Condition
-	OrderClause     []c.Order
-	OffsetResult    int
-	LimitResult     int
-	Changes         map[string]interface{}
-}
-
-// Select filter fields to be selected from database.
+	repo             *Repo
+	ctx              context.Context
+	Collection       string
+	Fields           []string
+	FieldValues      []interface{}
+	AsDistinct       bool
+	JoinClause       []c.Join
+	Condition        c.Condition
+	GroupFields      []string
+	HavingCondition  c.Condition
+	OrderClause      []c.Order
+	OffsetResult     int
+	LimitResult      int
+	LockClause       c.Lock
+	UsePrimary       bool
+	SoftDeleteMode   SoftDeleteMode
+	AffectedRows     int
+	PreloadFields    []string
+	OnConflictClause c.OnConflict
+	Changes          map[string]interface{}
+}
+
+// WithContext sets context that will be used when executing the query.
+func (query Query) WithContext(ctx context.Context) Query {
+	query.ctx = ctx
+	return query
+}
+
+// Context returns query's context, it'll return background context if not set.
+func (query Query) Context() context.Context {
+	if query.ctx == nil {
+		return context.Background()
+	}
+
+	return query.ctx
+}
+
+// Select filter fields to be selected from database, it replaces previously selected fields including select fragments.
 func (query Query) Select(fields ...string) Query {
 	query.Fields = fields
+	query.FieldValues = nil
+	return query
+}
+
+// SelectFragment adds an expression with placeholders to selected fields, for example: SelectFragment("age > ? AS adult", 17).
+// Values of every select fragment are bound in order.
+func (query Query) SelectFragment(expr string, values ...interface{}) Query {
+	query.Fields = append(append([]string(nil), query.Fields...), expr)
+	query.FieldValues = append(append([]interface{}(nil), query.FieldValues...), values...)
 	return query
 }
 
@@ -115,12 +151,49 @@ func (query Query) Limit(limit int) Query {
 	return query
 }
 
+// Lock selected rows using the given locking mode, for example: Lock(c.ForUpdate().SkipLocked()).
+// Lock can only be used inside transaction, and it's ignored by sqlite.
+func (query Query) Lock(lock c.Lock) Query {
+	query.LockClause = lock
+	return query
+}
+
+// Primary forces query to be executed using primary database when repo's adapter routes read to replicas.
+func (query Query) Primary() Query {
+	query.UsePrimary = true
+	return query
+}
+
+// MustAffect requires update and delete to affect at least n rows, otherwise not found error is returned.
+// Update and delete that are performed inside transaction will be reverted when the transaction returns the error.
+func (query Query) MustAffect(n int) Query {
+	query.AffectedRows = n
+	return query
+}
+
 // Find adds where id=? into query.
 // This is short cut for Where(Eq(I("id"), 1))
 func (query Query) Find(id interface{}) Query {
 	return query.Where(c.Eq(c.I(query.Collection+".id"), id))
 }
 
+// Preload loads association into record's field after the main query is executed.
+// Nested association can be preloaded using dot notation, for example: "addresses.city".
+func (query Query) Preload(field string) Query {
+	query.PreloadFields = append(query.PreloadFields, field)
+	return query
+}
+
+// OnConflict defines conflict resolution of insert operation on the given unique fields.
+// Resolution must be specified using DoNothing or DoUpdate, for example: OnConflict("email").DoUpdate("name").
+// Mysql resolves conflict on any unique index, so the fields are only used by postgres and sqlite.
+func (query Query) OnConflict(fields ...string) Conflict {
+	return Conflict{
+		query:  query,
+		fields: fields,
+	}
+}
+
 // Set value for insert or update operation that will replace changeset value.
 func (query Query) Set(field string, value interface{}) Query {
 	if query.Changes == nil {
@@ -132,18 +205,28 @@ func (query Query) Set(field string, value interface{}) Query {
 }
 
 // One retrieves one result that match the query.
-// If no result found, it'll return not found error.
+// If no result found, it'll return not found error. AfterFind hook of record will be called if implemented.
+// Soft deleted record is excluded unless WithDeleted is used.
 func (query Query) One(record interface{}) error {
+	if err := query.checkLock(); err != nil {
+		return err
+	}
+
+	query = query.softDelete(record)
 	query.LimitResult = 1
-	count, err := query.repo.adapter.All(query, record, query.repo.logger...)
+	count, err := query.repo.adapter.All(query, record, query.instrumenters("all")...)
 
 	if err != nil {
 		return errors.Wrap(err)
 	} else if count == 0 {
 		return errors.NotFoundError("no result found")
-	} else {
-		return nil
 	}
+
+	if err := query.preloadAll(record); err != nil {
+		return err
+	}
+
+	return afterFind(record)
 }
 
 // MustOne retrieves one result that match the query.
@@ -153,9 +236,24 @@ func (query Query) MustOne(record interface{}) {
 }
 
 // All retrieves all results that match the query.
+// AfterFind hook of each record will be called if implemented. Soft deleted records are excluded unless WithDeleted is used.
 func (query Query) All(record interface{}) error {
-	_, err := query.repo.adapter.All(query, record, query.repo.logger...)
-	return err
+	if err := query.checkLock(); err != nil {
+		return err
+	}
+
+	query = query.softDelete(record)
+
+	count, err := query.repo.adapter.All(query, record, query.instrumenters("all")...)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	if err := query.preloadAll(record); err != nil {
+		return err
+	}
+
+	return afterFind(record)
 }
 
 // MustAll retrieves all results that match the query.
@@ -164,9 +262,102 @@ func (query Query) MustAll(record interface{}) {
 	paranoid.Panic(query.All(record))
 }
 
+// Iterate returns iterator of results that match the query, it's useful to process large results without loading all of them to memory.
+// The iterator must be closed after use, and associations won't be preloaded.
+// Soft deleted records of collection declared using SoftDelete option are excluded unless WithDeleted is used.
+func (query Query) Iterate() (Iterator, error) {
+	return query.softDelete(nil).iterate()
+}
+
+func (query Query) iterate() (Iterator, error) {
+	if err := query.checkLock(); err != nil {
+		return nil, err
+	}
+
+	it, err := query.repo.adapter.Iterate(query, query.instrumenters("iterate")...)
+	return it, errors.Wrap(err)
+}
+
+// Each scans every result that match the query into record and calls fn after each scan.
+// Iteration will stop if fn returns an error.
+func (query Query) Each(record interface{}, fn func() error) error {
+	it, err := query.softDelete(record).iterate()
+	if err != nil {
+		return err
+	}
+
+	defer it.Close()
+
+	for {
+		if err := it.Next(record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err)
+		}
+
+		if err := afterFind(record); err != nil {
+			return err
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+}
+
+// FindInBatches retrieves results that match the query into records in batches and calls fn after each batch.
+// Batches are retrieved using keyset pagination on id, so order, offset and limit of the query will be replaced.
+func (query Query) FindInBatches(records interface{}, size int, fn func() error) error {
+	rv := reflect.ValueOf(records)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		panic("grimoire: records must be a pointer to slice")
+	}
+
+	if size <= 0 {
+		panic("grimoire: batch size must be greater than 0")
+	}
+
+	id := c.I(query.Collection + ".id")
+	query.OrderClause = []c.Order{c.Asc(id)}
+	query.OffsetResult = 0
+	query.LimitResult = size
+
+	batch := query
+	for {
+		if err := batch.All(records); err != nil {
+			return err
+		}
+
+		length := rv.Elem().Len()
+		if length == 0 {
+			return nil
+		}
+
+		last := structValues(rv.Elem().Index(length - 1))
+		index, ok := fieldIndexByName(last[0].Type(), "id")
+		if !ok {
+			panic("grimoire: field named (id) is not found in " + last[0].Type().String())
+		}
+
+		lastID := last[0].Field(index).Interface()
+
+		if err := fn(); err != nil {
+			return err
+		}
+
+		if length < size {
+			return nil
+		}
+
+		batch = query.Where(c.Gt(id, lastID))
+	}
+}
+
 // Count retrieves count of results that match the query.
+// Soft deleted records of collection declared using SoftDelete option are excluded unless WithDeleted is used.
 func (query Query) Count() (int, error) {
-	count, err := query.repo.adapter.Count(query, query.repo.logger...)
+	query = query.softDelete(nil)
+	count, err := query.repo.adapter.Count(query, query.instrumenters("count")...)
 	return count, err
 }
 
@@ -178,8 +369,77 @@ func (query Query) MustCount() int {
 	return count
 }
 
+// ToSQL returns statement and arguments of the query for the given operation without performing it.
+// op is one of count, all, iterate, insert, update and delete, insert and update use changes of the query.
+// Query is rendered the same way it's performed without record, so soft delete scope and conflict resolution are applied.
+// It returns an error if there's nothing to insert or update, or if repo's adapter doesn't support rendering statement.
+func (query Query) ToSQL(op string) (string, []interface{}, error) {
+	changes := query.Changes
+
+	switch op {
+	case "count", "all", "iterate":
+		query = query.softDelete(nil)
+	case "insert":
+		if len(changes) == 0 {
+			return "", nil, errors.UnexpectedError("nothing to insert")
+		}
+
+		query = query.resolveConflict(changesFields(changes))
+	case "update":
+		if len(changes) == 0 {
+			return "", nil, errors.UnexpectedError("nothing to update")
+		}
+	case "delete":
+		if field, ok := query.softDeleteColumn(nil); ok && query.SoftDeleteMode != SoftDeleteDisabled {
+			op = "update"
+			changes = map[string]interface{}{field: time.Now().Round(time.Second)}
+			query = query.softDelete(nil)
+		}
+	}
+
+	statement, args, err := Render(query.repo.adapter, Operation{
+		Context: query.Context(),
+		Op:      op,
+		Query:   query,
+		Changes: changes,
+	})
+
+	return statement, args, errors.Wrap(err)
+}
+
+// MustToSQL returns statement and arguments of the query for the given operation without performing it.
+// It'll panic if any error eccured.
+func (query Query) MustToSQL(op string) (string, []interface{}) {
+	statement, args, err := query.ToSQL(op)
+	paranoid.Panic(err)
+	return statement, args
+}
+
+// transaction performs fn inside transaction that begins using query's context, so deadline of the query also applies to begin and commit.
+// Current transaction will be used if repo is already in transaction.
+func (query Query) transaction(fn func(Repo) error) error {
+	return query.repo.WithContext(query.Context()).transaction(fn)
+}
+
 // Insert records to database.
+// BeforeInsert hook of changeset's entity and AfterInsert hook of record will be called if implemented.
+// If record implements AfterInsert hook, insert will be performed inside transaction, so it'll be reverted when the hook returns an error.
 func (query Query) Insert(record interface{}, chs ...*changeset.Changeset) error {
+	if !query.repo.inTransaction && hasHook(record, typeAfterInsertHook) {
+		return errors.Wrap(query.transaction(func(repo Repo) error {
+			query.repo = &repo
+			return query.Insert(record, chs...)
+		}))
+	}
+
+	if hasAssocChanges(chs...) {
+		return query.insertWithAssoc(record, chs)
+	}
+
+	if err := beforeInsert(chs); err != nil {
+		return err
+	}
+
 	var err error
 	var ids []interface{}
 
@@ -193,11 +453,13 @@ func (query Query) Insert(record interface{}, chs ...*changeset.Changeset) error
 		cloneQuery(changes, query.Changes)
 
 		var id interface{}
-		id, err = query.repo.adapter.Insert(query, changes, query.repo.logger...)
+		query = query.resolveConflict(changesFields(changes))
+		id, err = query.repo.adapter.Insert(query, changes, query.instrumenters("insert")...)
 		ids = append(ids, id)
 	} else if len(chs) > 1 {
 		// multiple insert
 		fields := getFields(query, chs)
+		query = query.resolveConflict(fields)
 
 		allchanges := make([]map[string]interface{}, len(chs))
 		for i, ch := range chs {
@@ -210,23 +472,34 @@ func (query Query) Insert(record interface{}, chs ...*changeset.Changeset) error
 			allchanges[i] = changes
 		}
 
-		ids, err = query.repo.adapter.InsertAll(query, fields, allchanges, query.repo.logger...)
+		ids, err = query.repo.adapter.InsertAll(query, fields, allchanges, query.instrumenters("insert_all")...)
 	} else if len(query.Changes) > 0 {
 		// set only
 		var id interface{}
-		id, err = query.repo.adapter.Insert(query, query.Changes, query.repo.logger...)
+		query = query.resolveConflict(changesFields(query.Changes))
+		id, err = query.repo.adapter.Insert(query, query.Changes, query.instrumenters("insert")...)
 		ids = append(ids, id)
 	}
 
+	if !query.OnConflictClause.None() {
+		ids = insertedIDs(ids)
+	}
+
 	if err != nil {
 		return errors.Wrap(err)
 	} else if record == nil || len(ids) == 0 {
 		return nil
 	} else if len(ids) == 1 {
-		return errors.Wrap(query.Find(ids[0]).One(record))
+		err = query.Primary().Find(ids[0]).One(record)
+	} else {
+		err = query.Primary().Where(c.In(c.I("id"), ids...)).All(record)
+	}
+
+	if err != nil {
+		return errors.Wrap(err)
 	}
 
-	return errors.Wrap(query.Where(c.In(c.I("id"), ids...)).All(record))
+	return afterInsert(record)
 }
 
 // MustInsert records to database.
@@ -236,8 +509,52 @@ func (query Query) MustInsert(record interface{}, chs ...*changeset.Changeset) {
 }
 
 // Update records in database.
-// It'll panic if any error occurred.
+// BeforeUpdate hook of changeset's entity and AfterUpdate hook of record will be called if implemented.
+// If record implements AfterUpdate hook, update will be performed inside transaction, so it'll be reverted when the hook returns an error.
 func (query Query) Update(record interface{}, chs ...*changeset.Changeset) error {
+	_, err := query.update(record, chs, true)
+	return err
+}
+
+// MustUpdate records in database.
+// It'll panic if any error occurred.
+func (query Query) MustUpdate(record interface{}, chs ...*changeset.Changeset) {
+	paranoid.Panic(query.Update(record, chs...))
+}
+
+// UpdateAll updates records in database without retrieving them, and returns the number of affected rows.
+func (query Query) UpdateAll(chs ...*changeset.Changeset) (int64, error) {
+	return query.update(nil, chs, false)
+}
+
+// MustUpdateAll updates records in database without retrieving them, and returns the number of affected rows.
+// It'll panic if any error occurred.
+func (query Query) MustUpdateAll(chs ...*changeset.Changeset) int64 {
+	count, err := query.UpdateAll(chs...)
+	paranoid.Panic(err)
+	return count
+}
+
+// update records using the first changeset, optimistic lock is only used if lock is true, so it's never used by UpdateAll.
+func (query Query) update(record interface{}, chs []*changeset.Changeset, lock bool) (int64, error) {
+	if !query.repo.inTransaction && hasHook(record, typeAfterUpdateHook) {
+		var count int64
+		err := query.transaction(func(repo Repo) error {
+			var err error
+			query.repo = &repo
+			count, err = query.update(record, chs, lock)
+			return err
+		})
+
+		return count, errors.Wrap(err)
+	}
+
+	if len(chs) != 0 {
+		if err := beforeUpdate(chs[0]); err != nil {
+			return 0, err
+		}
+	}
+
 	changes := make(map[string]interface{})
 
 	// only take the first changeset if any
@@ -248,29 +565,51 @@ func (query Query) Update(record interface{}, chs ...*changeset.Changeset) error
 
 	cloneQuery(changes, query.Changes)
 
+	if len(chs) != 0 && hasAssocChanges(chs[0]) {
+		return query.updateWithAssoc(record, chs[0], changes, lock)
+	}
+
 	// nothing to update
 	if len(changes) == 0 {
-		return nil
+		return 0, nil
+	}
+
+	// perform update, only matches the loaded version if record supports optimistic locking.
+	lockQuery, locked := query, false
+	if lock && len(chs) != 0 {
+		lockQuery, locked = query.optimisticLock(chs[0], changes)
 	}
 
-	// perform update
-	err := query.repo.adapter.Update(query, changes, query.repo.logger...)
+	count, err := lockQuery.performUpdate(changes, locked)
 	if err != nil {
-		return errors.Wrap(err)
+		return count, errors.Wrap(err)
 	}
 
 	// should not fetch updated record(s) if not necessery
-	if record != nil {
-		return errors.Wrap(query.All(record))
+	if record == nil {
+		return count, nil
 	}
 
-	return nil
+	if err := query.Primary().All(record); err != nil {
+		return count, errors.Wrap(err)
+	}
+
+	return count, afterUpdate(record)
 }
 
-// MustUpdate records in database.
-// It'll panic if any error occurred.
-func (query Query) MustUpdate(record interface{}, chs ...*changeset.Changeset) {
-	paranoid.Panic(query.Update(record, chs...))
+// performUpdate updates records using adapter.
+// Stale error is returned if the query is locked and no record is updated.
+func (query Query) performUpdate(changes map[string]interface{}, locked bool) (int64, error) {
+	count, err := query.repo.adapter.Update(query, changes, query.instrumenters("update")...)
+	if err != nil {
+		return count, err
+	}
+
+	if locked && count == 0 {
+		return count, errors.StaleError("record has been modified or deleted")
+	}
+
+	return count, query.checkAffected(count)
 }
 
 // Save a record to database.
@@ -329,14 +668,155 @@ func (query Query) MustSave(record interface{}) {
 }
 
 // Delete deletes all results that match the query.
-func (query Query) Delete() error {
-	return errors.Wrap(query.repo.adapter.Delete(query, query.repo.logger...))
+// If record is given and it supports soft delete, results will be soft deleted by setting its deleted at field to current time instead,
+// and the field of the record will be set too. Results of collection declared using SoftDelete option are soft deleted even without record.
+// Use HardDelete to delete them permanently.
+// BeforeDelete and AfterDelete hook of record will be called inside transaction if implemented, so delete will be reverted when the hook returns an error.
+func (query Query) Delete(record ...interface{}) error {
+	_, err := query.delete(record)
+	return err
 }
 
 // MustDelete deletes all results that match the query.
 // It'll panic if any error eccured.
-func (query Query) MustDelete() {
-	paranoid.Panic(query.Delete())
+func (query Query) MustDelete(record ...interface{}) {
+	paranoid.Panic(query.Delete(record...))
+}
+
+// DeleteAll deletes all results that match the query, and returns the number of affected rows.
+// Record is only used to soft delete the results, it works the same way as Delete.
+func (query Query) DeleteAll(record ...interface{}) (int64, error) {
+	return query.delete(record)
+}
+
+// MustDeleteAll deletes all results that match the query, and returns the number of affected rows.
+// It'll panic if any error eccured.
+func (query Query) MustDeleteAll(record ...interface{}) int64 {
+	count, err := query.DeleteAll(record...)
+	paranoid.Panic(err)
+	return count
+}
+
+func (query Query) delete(record []interface{}) (int64, error) {
+	if len(record) == 0 {
+		return query.deleteRecord(nil)
+	}
+
+	if !query.repo.inTransaction && (hasHook(record[0], typeBeforeDeleteHook) || hasHook(record[0], typeAfterDeleteHook)) {
+		var count int64
+		err := query.transaction(func(repo Repo) error {
+			var err error
+			query.repo = &repo
+			count, err = query.delete(record)
+			return err
+		})
+
+		return count, errors.Wrap(err)
+	}
+
+	if err := beforeDelete(record[0]); err != nil {
+		return 0, err
+	}
+
+	count, err := query.deleteRecord(record[0])
+	if err != nil {
+		return count, err
+	}
+
+	return count, afterDelete(record[0])
+}
+
+func (query Query) deleteRecord(record interface{}) (int64, error) {
+	if query.SoftDeleteMode == SoftDeleteDisabled {
+		return query.performDelete()
+	}
+
+	field, ok := query.softDeleteColumn(record)
+	if !ok {
+		return query.performDelete()
+	}
+
+	deletedAt := time.Now().Round(time.Second)
+	changes := map[string]interface{}{field: deletedAt}
+
+	count, err := query.softDelete(record).performUpdate(changes, false)
+	if err != nil {
+		return count, errors.Wrap(err)
+	}
+
+	if _, index, ok := softDeleteField(recordType(record)); ok {
+		setDeletedAt(record, index, deletedAt)
+	}
+
+	return count, nil
+}
+
+func (query Query) performDelete() (int64, error) {
+	count, err := query.repo.adapter.Delete(query, query.instrumenters("delete")...)
+	if err != nil {
+		return count, errors.Wrap(err)
+	}
+
+	return count, query.checkAffected(count)
+}
+
+// checkAffected returns not found error if the number of affected rows is less than required by MustAffect.
+func (query Query) checkAffected(count int64) error {
+	if count < int64(query.AffectedRows) {
+		return errors.NotFoundError(fmt.Sprintf("expected at least %d affected rows, but %d rows affected", query.AffectedRows, count))
+	}
+
+	return nil
+}
+
+// checkLock returns error if locking is used outside transaction, since the lock will be released immediately.
+func (query Query) checkLock() error {
+	if !query.LockClause.None() && !query.repo.inTransaction {
+		return errors.UnexpectedError("lock can only be used inside transaction")
+	}
+
+	return nil
+}
+
+func (query Query) preloadAll(record interface{}) error {
+	for _, field := range query.PreloadFields {
+		if err := query.preload(record, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (query Query) instrumenters(op string) []Instrumenter {
+	return query.repo.instrumenters(op, query.Collection)
+}
+
+// resolveConflict uses inserted fields as update fields of conflict resolution if it's not specified.
+// Conflict fields and created_at are excluded, so they won't be modified when updating existing record.
+func (query Query) resolveConflict(fields []string) Query {
+	if query.OnConflictClause.Action != c.ConflictUpdate || len(query.OnConflictClause.UpdateFields) != 0 {
+		return query
+	}
+
+	excluded := map[string]bool{"created_at": true}
+	for _, f := range query.OnConflictClause.Fields {
+		excluded[f] = true
+	}
+
+	updateFields := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if !excluded[f] {
+			updateFields = append(updateFields, f)
+		}
+	}
+
+	if len(updateFields) == 0 {
+		query.OnConflictClause.Action = c.ConflictIgnore
+	}
+
+	query.OnConflictClause.UpdateFields = updateFields
+	return query
 }
 
 func cloneChangeset(out map[string]interface{}, changes map[string]interface{}) {
@@ -388,5 +868,30 @@ func getFields(query Query, chs []*changeset.Changeset) []string {
 		}
 	}
 
+	sort.Strings(fields)
+	return fields
+}
+
+func changesFields(changes map[string]interface{}) []string {
+	fields := make([]string, 0, len(changes))
+	for f := range changes {
+		fields = append(fields, f)
+	}
+
+	sort.Strings(fields)
 	return fields
 }
+
+// insertedIDs removes zero ids, which are returned for records skipped by conflict resolution.
+func insertedIDs(ids []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if id == nil || reflect.DeepEqual(id, reflect.Zero(reflect.TypeOf(id)).Interface()) {
+			continue
+		}
+
+		result = append(result, id)
+	}
+
+	return result
+}
@@ -1,6 +1,8 @@
 package grimoire
 
 import (
+	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -8,6 +10,7 @@ import (
 	"github.com/Fs02/grimoire/changeset"
 	"github.com/Fs02/grimoire/errors"
 	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
 )
 
 type User struct {
@@ -17,6 +20,45 @@ type User struct {
 	UpdatedAt time.Time
 }
 
+func TestQueryWithContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	assert.Equal(t, repo.From("users").WithContext(ctx), Query{
+		repo:       &repo,
+		ctx:        ctx,
+		Collection: "users",
+		Fields:     []string{"*"},
+	})
+
+	assert.Equal(t, ctx, repo.From("users").WithContext(ctx).Context())
+	assert.Equal(t, context.Background(), repo.From("users").Context())
+}
+
+func TestQueryInstrumenters(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+	repo := New(nil)
+
+	var result context.Context
+	repo.SetContextLogger(func(ctx context.Context, query string, duration time.Duration, err error) {
+		result = ctx
+	})
+
+	var event QueryEvent
+	repo.SetInstrumenter(InstrumenterFunc(func(e QueryEvent) {
+		event = e
+	}))
+
+	instrumenters := repo.From("users").instrumenters("all")
+	assert.Equal(t, 1, len(instrumenters))
+
+	Instrument(instrumenters, QueryEvent{Context: ctx, Statement: "SELECT * FROM users;"})
+	assert.Equal(t, ctx, result)
+	assert.Equal(t, QueryEvent{Context: ctx, Op: "all", Collection: "users", Statement: "SELECT * FROM users;"}, event)
+
+	// without logger and instrumenter
+	assert.Nil(t, Repo{}.From("users").instrumenters("all"))
+}
+
 func TestQuerySelect(t *testing.T) {
 	assert.Equal(t, repo.From("users").Select("*"), Query{
 		repo:       &repo,
@@ -31,6 +73,24 @@ func TestQuerySelect(t *testing.T) {
 	})
 }
 
+func TestQuerySelectFragment(t *testing.T) {
+	query := repo.From("users").Select("id").SelectFragment("age > ? AS adult", 17)
+	assert.Equal(t, query, Query{
+		repo:        &repo,
+		Collection:  "users",
+		Fields:      []string{"id", "age > ? AS adult"},
+		FieldValues: []interface{}{17},
+	})
+
+	assert.Equal(t, []string{"id"}, repo.From("users").Select("id").Fields)
+
+	assert.Equal(t, repo.From("users").SelectFragment("age > ? AS adult", 17).Select("id"), Query{
+		repo:       &repo,
+		Collection: "users",
+		Fields:     []string{"id"},
+	})
+}
+
 func TestQueryDistinct(t *testing.T) {
 	assert.Equal(t, repo.From("users").Distinct(), Query{
 		repo:       &repo,
@@ -338,6 +398,64 @@ func TestQueryLimit(t *testing.T) {
 	})
 }
 
+func TestQueryLock(t *testing.T) {
+	assert.Equal(t, repo.From("users").Lock(ForUpdate()), Query{
+		repo:       &repo,
+		Collection: "users",
+		Fields:     []string{"*"},
+		LockClause: Lock{Mode: LockForUpdate},
+	})
+}
+
+func TestQueryMustAffect(t *testing.T) {
+	assert.Equal(t, repo.From("users").MustAffect(1), Query{
+		repo:         &repo,
+		Collection:   "users",
+		Fields:       []string{"*"},
+		AffectedRows: 1,
+	})
+}
+
+func TestQueryPrimary(t *testing.T) {
+	assert.Equal(t, repo.From("users").Primary(), Query{
+		repo:       &repo,
+		Collection: "users",
+		Fields:     []string{"*"},
+		UsePrimary: true,
+	})
+}
+
+func TestQueryLockInTransaction(t *testing.T) {
+	user := User{}
+	users := []User{}
+	mock := new(TestAdapter)
+
+	mock.On("Begin").Return(nil).
+		On("All", testmock.Anything, &user).Return(1, nil).
+		On("All", testmock.Anything, &users).Return(1, nil).
+		On("Commit").Return(nil)
+
+	err := Repo{adapter: mock}.Transaction(func(repo Repo) error {
+		query := repo.From("users").Lock(ForUpdate())
+		assert.Nil(t, query.One(&user))
+		assert.Nil(t, query.All(&users))
+		return nil
+	})
+
+	assert.Nil(t, err)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryLockOutsideTransaction(t *testing.T) {
+	user := User{}
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Lock(ForUpdate())
+
+	assert.Equal(t, errors.UnexpectedError("lock can only be used inside transaction"), query.One(&user))
+	assert.Equal(t, errors.UnexpectedError("lock can only be used inside transaction"), query.All(&user))
+	mock.AssertExpectations(t)
+}
+
 func TestQueryFind(t *testing.T) {
 	assert.Equal(t, repo.From("users").Find(1), Query{
 		repo:       &repo,
@@ -423,6 +541,187 @@ func TestQueryAll(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestQueryIterate(t *testing.T) {
+	it := new(TestIterator)
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(it, nil)
+
+	result, err := query.Iterate()
+	assert.Nil(t, err)
+	assert.Equal(t, it, result)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryIterateError(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(nil, errors.UnexpectedError("error"))
+
+	_, err := query.Iterate()
+	assert.Equal(t, errors.UnexpectedError("error"), err)
+	mock.AssertExpectations(t)
+
+	// lock outside transaction
+	_, err = query.Lock(ForUpdate()).Iterate()
+	assert.Equal(t, errors.UnexpectedError("lock can only be used inside transaction"), err)
+}
+
+func TestQueryEach(t *testing.T) {
+	user := User{}
+	it := new(TestIterator)
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(it, nil)
+	it.On("Next", &user).Return(nil).Twice().
+		On("Next", &user).Return(io.EOF).Once().
+		On("Close").Return(nil).Once()
+
+	count := 0
+	assert.Nil(t, query.Each(&user, func() error {
+		count++
+		return nil
+	}))
+
+	assert.Equal(t, 2, count)
+	mock.AssertExpectations(t)
+	it.AssertExpectations(t)
+}
+
+func TestQueryEachError(t *testing.T) {
+	user := User{}
+	it := new(TestIterator)
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(it, nil)
+	it.On("Next", &user).Return(nil).Once().
+		On("Close").Return(nil).Once()
+
+	assert.Equal(t, errors.NotFoundError("stop"), query.Each(&user, func() error {
+		return errors.NotFoundError("stop")
+	}))
+
+	mock.AssertExpectations(t)
+	it.AssertExpectations(t)
+}
+
+func TestQueryEachNextError(t *testing.T) {
+	user := User{}
+	it := new(TestIterator)
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(it, nil)
+	it.On("Next", &user).Return(errors.UnexpectedError("error")).Once().
+		On("Close").Return(nil).Once()
+
+	assert.Equal(t, errors.UnexpectedError("error"), query.Each(&user, func() error {
+		return nil
+	}))
+
+	mock.AssertExpectations(t)
+	it.AssertExpectations(t)
+}
+
+func TestQueryEachIterateError(t *testing.T) {
+	user := User{}
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Iterate", query).Return(nil, errors.UnexpectedError("error"))
+
+	assert.Equal(t, errors.UnexpectedError("error"), query.Each(&user, func() error {
+		return nil
+	}))
+
+	mock.AssertExpectations(t)
+}
+
+func TestQueryFindInBatches(t *testing.T) {
+	people := []Person{}
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("people").Where(Eq(I("name"), "name")).Order(Desc("name")).Offset(10)
+	batch := query.Limit(2)
+	batch.OrderClause = []Order{Asc("people.id")}
+	batch.OffsetResult = 0
+
+	mock.On("All", batch, &people).Return(2, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}, {ID: 2}}
+	}).Once()
+
+	mock.On("All", batch.Where(Gt(I("people.id"), 2)), &people).Return(2, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 3}, {ID: 4}}
+	}).Once()
+
+	mock.On("All", batch.Where(Gt(I("people.id"), 4)), &people).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 5}}
+	}).Once()
+
+	var ids []int
+	assert.Nil(t, query.FindInBatches(&people, 2, func() error {
+		for _, person := range people {
+			ids = append(ids, person.ID)
+		}
+
+		return nil
+	}))
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, ids)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryFindInBatchesEmpty(t *testing.T) {
+	people := []Person{}
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("people")
+
+	mock.On("All", query.Order(Asc("people.id")).Limit(10), &people).Return(0, nil)
+
+	assert.Nil(t, query.FindInBatches(&people, 10, func() error {
+		t.Fail()
+		return nil
+	}))
+
+	mock.AssertExpectations(t)
+}
+
+func TestQueryFindInBatchesError(t *testing.T) {
+	people := []Person{}
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("people")
+	batch := query.Order(Asc("people.id")).Limit(2)
+
+	mock.On("All", batch, &people).Return(0, errors.UnexpectedError("error")).Once()
+
+	assert.Equal(t, errors.UnexpectedError("error"), query.FindInBatches(&people, 2, func() error {
+		return nil
+	}))
+
+	mock.On("All", batch, &people).Return(2, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}, {ID: 2}}
+	}).Once()
+
+	assert.Equal(t, errors.NotFoundError("stop"), query.FindInBatches(&people, 2, func() error {
+		return errors.NotFoundError("stop")
+	}))
+
+	mock.AssertExpectations(t)
+}
+
+func TestQueryFindInBatchesInvalidArgument(t *testing.T) {
+	assert.Panics(t, func() {
+		repo.From("people").FindInBatches(&Person{}, 10, func() error { return nil })
+	})
+
+	assert.Panics(t, func() {
+		repo.From("people").FindInBatches(&[]Person{}, 0, func() error { return nil })
+	})
+}
+
 func TestQueryCount(t *testing.T) {
 	mock := new(TestAdapter)
 	query := Repo{adapter: mock}.From("users")
@@ -440,6 +739,80 @@ func TestQueryCount(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestQueryToSQL(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1).Set("name", "name")
+
+	mock.On("Render", "update", query, query.Changes).Return("UPDATE users SET name=? WHERE id=?;", []interface{}{"name", 1}, nil).Twice()
+
+	statement, args, err := query.ToSQL("update")
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE users SET name=? WHERE id=?;", statement)
+	assert.Equal(t, []interface{}{"name", 1}, args)
+
+	assert.NotPanics(t, func() {
+		statement, args := query.MustToSQL("update")
+		assert.Equal(t, "UPDATE users SET name=? WHERE id=?;", statement)
+		assert.Equal(t, []interface{}{"name", 1}, args)
+	})
+
+	mock.AssertExpectations(t)
+}
+
+func TestQueryToSQLError(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Render", "upsert", query, map[string]interface{}(nil)).Return("", nil, errors.UnexpectedError("unsupported operation: upsert")).Once()
+
+	_, _, err := query.ToSQL("upsert")
+	assert.Equal(t, errors.UnexpectedError("unsupported operation: upsert"), err)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryToSQLUnsupported(t *testing.T) {
+	// adapter is embedded, so only methods of Adapter interface are available.
+	query := Repo{adapter: struct{ Adapter }{new(TestAdapter)}}.From("users")
+
+	_, _, err := query.ToSQL("all")
+	assert.Equal(t, errors.UnexpectedError("adapter doesn't support rendering statement"), err)
+
+	assert.Panics(t, func() {
+		query.MustToSQL("all")
+	})
+}
+
+func TestQueryToSQLOnConflict(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Set("id", 1).Set("name", "name").OnConflict("id").DoUpdate()
+	resolved := query.OnConflict("id").DoUpdate("name")
+
+	mock.On("Render", "insert", resolved, query.Changes).Return("INSERT INTO users (id,name) VALUES (?,?) ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name;", []interface{}{1, "name"}, nil).Once()
+
+	statement, args, err := query.ToSQL("insert")
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT INTO users (id,name) VALUES (?,?) ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name;", statement)
+	assert.Equal(t, []interface{}{1, "name"}, args)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryToSQLNothingToChange(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1)
+
+	_, _, err := query.ToSQL("insert")
+	assert.Equal(t, errors.UnexpectedError("nothing to insert"), err)
+
+	_, _, err = query.ToSQL("update")
+	assert.Equal(t, errors.UnexpectedError("nothing to update"), err)
+
+	assert.Panics(t, func() {
+		query.MustToSQL("update")
+	})
+
+	mock.AssertExpectations(t)
+}
+
 func createChangeset() (*changeset.Changeset, User) {
 	user := User{}
 	ch := changeset.Cast(user, map[string]interface{}{
@@ -465,7 +838,7 @@ func TestQueryInsert(t *testing.T) {
 	}
 
 	mock.On("Insert", query, changes).Return(1, nil).
-		On("All", query.Find(1).Limit(1), &user).Return(1, nil)
+		On("All", query.Primary().Find(1).Limit(1), &user).Return(1, nil)
 
 	assert.Nil(t, query.Insert(&user, ch))
 	assert.NotPanics(t, func() { query.MustInsert(&user, ch) })
@@ -489,7 +862,7 @@ func TestQueryInsertMultiple(t *testing.T) {
 	allchanges := []map[string]interface{}{changes, changes}
 
 	mock.On("InsertAll", query, allchanges).Return([]interface{}{1, 2}, nil).
-		On("All", query.Where(In(I("id"), 1, 2)), &users).Return(2, nil)
+		On("All", query.Primary().Where(In(I("id"), 1, 2)), &users).Return(2, nil)
 
 	assert.Nil(t, query.Insert(&users, ch1, ch2))
 	assert.NotPanics(t, func() { query.MustInsert(&users, ch1, ch2) })
@@ -514,7 +887,7 @@ func TestQueryInsertMultipleWithSet(t *testing.T) {
 	allchanges := []map[string]interface{}{changes, changes}
 
 	mock.On("InsertAll", query, allchanges).Return([]interface{}{1, 2}, nil).
-		On("All", query.Where(In(I("id"), 1, 2)), &users).Return(2, nil)
+		On("All", query.Primary().Where(In(I("id"), 1, 2)), &users).Return(2, nil)
 
 	assert.Nil(t, query.Insert(&users, ch1, ch2))
 	assert.NotPanics(t, func() { query.MustInsert(&users, ch1, ch2) })
@@ -575,7 +948,7 @@ func TestQueryInsertWithSet(t *testing.T) {
 	}
 
 	mock.On("Insert", query, changes).Return(0, nil).
-		On("All", query.Find(0).Limit(1), &user).Return(1, nil)
+		On("All", query.Primary().Find(0).Limit(1), &user).Return(1, nil)
 
 	assert.Nil(t, query.Insert(&user, ch))
 	assert.NotPanics(t, func() { query.MustInsert(&user, ch) })
@@ -612,39 +985,146 @@ func TestQueryInsertOnlySetError(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
-func TestQueryInsertAssocOne(t *testing.T) {
-	var card struct {
-		ID   int
-		User User
-	}
+func TestQueryOnConflict(t *testing.T) {
+	assert.Equal(t, repo.From("users").OnConflict("email").DoNothing(), Query{
+		repo:       &repo,
+		Collection: "users",
+		Fields:     []string{"*"},
+		OnConflictClause: OnConflict{
+			Fields: []string{"email"},
+			Action: ConflictIgnore,
+		},
+	})
 
-	params := map[string]interface{}{
-		"id": 1,
-		"user": map[string]interface{}{
-			"name": "name",
+	assert.Equal(t, repo.From("users").OnConflict("email").DoUpdate("name"), Query{
+		repo:       &repo,
+		Collection: "users",
+		Fields:     []string{"*"},
+		OnConflictClause: OnConflict{
+			Fields:       []string{"email"},
+			Action:       ConflictUpdate,
+			UpdateFields: []string{"name"},
 		},
+	})
+}
+
+func TestQueryInsertOnConflictUpdate(t *testing.T) {
+	ch, user := createChangeset()
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").OnConflict("name").DoUpdate()
+	resolved := query.OnConflict("name").DoUpdate("updated_at")
+
+	changes := map[string]interface{}{
+		"name":       "name",
+		"created_at": time.Now().Round(time.Second),
+		"updated_at": time.Now().Round(time.Second),
 	}
 
-	userChangeset := func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
-		ch := changeset.Cast(entity, params, []string{"name"})
-		return ch
+	mock.On("Insert", resolved, changes).Return(1, nil).
+		On("All", resolved.Primary().Find(1).Limit(1), &user).Return(1, nil)
+
+	assert.Nil(t, query.Insert(&user, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertOnConflictDoNothing(t *testing.T) {
+	ch, user := createChangeset()
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").OnConflict("name").DoNothing()
+
+	changes := map[string]interface{}{
+		"name":       "name",
+		"created_at": time.Now().Round(time.Second),
+		"updated_at": time.Now().Round(time.Second),
 	}
 
-	ch := changeset.Cast(card, params, []string{"id"})
-	changeset.CastAssoc(ch, "user", userChangeset)
+	// skipped record returns zero id and won't be retrieved.
+	mock.On("Insert", query, changes).Return(int64(0), nil)
+
+	assert.Nil(t, query.Insert(&user, ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryInsertMultipleOnConflict(t *testing.T) {
+	ch1, user1 := createChangeset()
+	ch2, user2 := createChangeset()
+	users := []User{user1, user2}
 
 	mock := new(TestAdapter)
-	query := Repo{adapter: mock}.From("cards")
+	query := Repo{adapter: mock}.From("users").OnConflict("name").DoUpdate()
 
 	changes := map[string]interface{}{
-		"id": 1,
+		"name":       "name",
+		"created_at": time.Now().Round(time.Second),
+		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Insert", query, changes).Return(0, nil).
-		On("All", query.Find(0).Limit(1), &card).Return(1, nil)
+	allchanges := []map[string]interface{}{changes, changes}
+
+	inserted := testmock.MatchedBy(func(q Query) bool {
+		return q.OnConflictClause.Action == ConflictUpdate && assert.ObjectsAreEqual([]string{"updated_at"}, q.OnConflictClause.UpdateFields)
+	})
+
+	// skipped record won't be retrieved.
+	retrieved := testmock.MatchedBy(func(q Query) bool {
+		return q.UsePrimary && assert.ObjectsAreEqual(And(Eq(I("users.id"), int64(1))), q.Condition)
+	})
 
-	assert.Nil(t, query.Insert(&card, ch))
-	assert.NotPanics(t, func() { query.MustInsert(&card, ch) })
+	mock.On("InsertAll", inserted, allchanges).Return([]interface{}{int64(1), int64(0)}, nil).
+		On("All", retrieved, &users).Return(1, nil)
+
+	assert.Nil(t, query.Insert(&users, ch1, ch2))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryResolveConflict(t *testing.T) {
+	query := repo.From("users").OnConflict("email").DoUpdate()
+
+	assert.Equal(t, []string{"name", "updated_at"}, query.resolveConflict([]string{"created_at", "email", "name", "updated_at"}).OnConflictClause.UpdateFields)
+	assert.Equal(t, ConflictIgnore, query.resolveConflict([]string{"email", "created_at"}).OnConflictClause.Action)
+
+	// specified update fields are kept.
+	query = repo.From("users").OnConflict("email").DoUpdate("name")
+	assert.Equal(t, []string{"name"}, query.resolveConflict([]string{"email", "name", "age"}).OnConflictClause.UpdateFields)
+}
+
+func TestQueryInsertAssocOne(t *testing.T) {
+	person := Person{}
+
+	params := map[string]interface{}{
+		"name": "name",
+		"profile": map[string]interface{}{
+			"bio": "bio",
+		},
+	}
+
+	profileChangeset := func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
+		ch := changeset.Cast(entity, params, []string{"bio"})
+		return ch
+	}
+
+	ch := changeset.Cast(person, params, []string{"name"})
+	changeset.CastAssoc(ch, "profile", profileChangeset)
+
+	mock := new(TestAdapter)
+	repo := Repo{adapter: mock}
+	query := repo.From("people")
+	personID := 1
+
+	mock.On("Begin").Return(nil).
+		On("Insert", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("Insert", matchQuery(repo.From("person_profiles").Set("person_id", 1)), map[string]interface{}{"bio": "bio", "person_id": 1}).Return(1, nil).
+		On("All", matchQuery(query.Preload("profile").Find(1).Limit(1)), &person).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*Person) = Person{ID: 1, Name: "name"}
+	}).
+		On("All", matchQuery(repo.From("person_profiles").Where(In(I("person_id"), int64(1)))), new([]Profile)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Profile) = []Profile{{ID: 1, PersonID: &personID, Bio: "bio"}}
+	}).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Insert(&person, ch))
+	assert.Equal(t, Person{ID: 1, Name: "name", Profile: &Profile{ID: 1, PersonID: &personID, Bio: "bio"}}, person)
+	assert.NotPanics(t, func() { query.MustInsert(&person, ch) })
 	mock.AssertExpectations(t)
 }
 
@@ -693,7 +1173,7 @@ func TestQueryInsertAssocMany(t *testing.T) {
 	userChs := ch.Changes()["users"].([]*changeset.Changeset)
 
 	mock.On("InsertAll", query, allchanges).Return([]interface{}{1, 2}, nil).
-		On("All", query.Where(In("id", 1, 2)), &group.Users).Return(1, nil)
+		On("All", query.Primary().Where(In("id", 1, 2)), &group.Users).Return(1, nil)
 
 	assert.Nil(t, query.Insert(&group.Users, userChs...))
 	assert.NotPanics(t, func() { query.MustInsert(&group.Users, userChs...) })
@@ -727,8 +1207,8 @@ func TestQueryUpdate(t *testing.T) {
 		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Update", query, changes).Return(nil).
-		On("All", query, &user).Return(1, nil)
+	mock.On("Update", query, changes).Return(1, nil).
+		On("All", query.Primary(), &user).Return(1, nil)
 
 	assert.Nil(t, query.Update(&user, ch))
 	assert.NotPanics(t, func() { query.MustUpdate(&user, ch) })
@@ -746,8 +1226,8 @@ func TestUpdateWithSet(t *testing.T) {
 		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Update", query, changes).Return(nil).
-		On("All", query, &user).Return(1, nil)
+	mock.On("Update", query, changes).Return(1, nil).
+		On("All", query.Primary(), &user).Return(1, nil)
 
 	assert.Nil(t, query.Update(&user, ch))
 	assert.NotPanics(t, func() { query.MustUpdate(&user, ch) })
@@ -762,7 +1242,7 @@ func TestUpdateOnlySet(t *testing.T) {
 		"age": 10,
 	}
 
-	mock.On("Update", query, changes).Return(nil)
+	mock.On("Update", query, changes).Return(1, nil)
 
 	assert.Nil(t, query.Update(nil))
 	assert.NotPanics(t, func() { query.MustUpdate(nil) })
@@ -779,7 +1259,7 @@ func TestUpdateNotReturning(t *testing.T) {
 		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Update", query, changes).Return(nil)
+	mock.On("Update", query, changes).Return(1, nil)
 
 	assert.Nil(t, query.Update(nil, ch))
 	assert.NotPanics(t, func() { query.MustUpdate(nil, ch) })
@@ -796,38 +1276,47 @@ func TestUpdateNothing(t *testing.T) {
 }
 
 func TestQueryUpdateAssocOne(t *testing.T) {
-	var card struct {
-		ID   int
-		User User
-	}
+	person := Person{}
 
 	params := map[string]interface{}{
-		"id": 1,
-		"user": map[string]interface{}{
-			"name": "name",
+		"name": "name",
+		"profile": map[string]interface{}{
+			"bio": "bio",
 		},
 	}
 
-	userChangeset := func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
-		ch := changeset.Cast(entity, params, []string{"name"})
+	profileChangeset := func(entity interface{}, params map[string]interface{}) *changeset.Changeset {
+		ch := changeset.Cast(entity, params, []string{"bio"})
 		return ch
 	}
 
-	ch := changeset.Cast(card, params, []string{"id"})
-	changeset.CastAssoc(ch, "user", userChangeset)
-
-	changes := map[string]interface{}{
-		"id": 1,
-	}
+	ch := changeset.Cast(person, params, []string{"name"})
+	changeset.CastAssoc(ch, "profile", profileChangeset)
 
 	mock := new(TestAdapter)
-	query := Repo{adapter: mock}.From("cards")
-
-	mock.On("Update", query, changes).Return(nil).
-		On("All", query, &card).Return(1, nil)
-
-	assert.Nil(t, query.Update(&card, ch))
-	assert.NotPanics(t, func() { query.MustUpdate(&card, ch) })
+	repo := Repo{adapter: mock}
+	query := repo.From("people").Find(1)
+
+	personID := 1
+
+	mock.On("Begin").Return(nil).
+		On("All", matchQuery(query), new([]Person)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Person) = []Person{{ID: 1}}
+	}).
+		On("Update", matchQuery(query), map[string]interface{}{"name": "name"}).Return(1, nil).
+		On("Delete", matchQuery(repo.From("person_profiles").Where(In(I("person_id"), int64(1))))).Return(1, nil).
+		On("Insert", matchQuery(repo.From("person_profiles").Set("person_id", int64(1))), map[string]interface{}{"bio": "bio", "person_id": int64(1)}).Return(1, nil).
+		On("All", matchQuery(query.Preload("profile")), &person).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*Person) = Person{ID: 1, Name: "name"}
+	}).
+		On("All", matchQuery(repo.From("person_profiles").Where(In(I("person_id"), int64(1)))), new([]Profile)).Return(1, nil).Run(func(args testmock.Arguments) {
+		*args.Get(1).(*[]Profile) = []Profile{{ID: 1, PersonID: &personID, Bio: "bio"}}
+	}).
+		On("Commit").Return(nil)
+
+	assert.Nil(t, query.Update(&person, ch))
+	assert.Equal(t, Person{ID: 1, Name: "name", Profile: &Profile{ID: 1, PersonID: &personID, Bio: "bio"}}, person)
+	assert.NotPanics(t, func() { query.MustUpdate(&person, ch) })
 	mock.AssertExpectations(t)
 }
 
@@ -861,8 +1350,8 @@ func TestUpdateAssocMany(t *testing.T) {
 	mock := new(TestAdapter)
 	query := Repo{adapter: mock}.From("groups")
 
-	mock.On("Update", query, changes).Return(nil).
-		On("All", query, &group).Return(1, nil)
+	mock.On("Update", query, changes).Return(1, nil).
+		On("All", query.Primary(), &group).Return(1, nil)
 
 	assert.Nil(t, query.Update(&group, ch))
 	assert.NotPanics(t, func() { query.MustUpdate(&group, ch) })
@@ -879,13 +1368,49 @@ func TestQueryUpdateError(t *testing.T) {
 		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Update", query, changes).Return(errors.UnexpectedError("error"))
+	mock.On("Update", query, changes).Return(0, errors.UnexpectedError("error"))
 
 	assert.NotNil(t, query.Update(&user, ch))
 	assert.Panics(t, func() { query.MustUpdate(&user, ch) })
 	mock.AssertExpectations(t)
 }
 
+func TestQueryUpdateAll(t *testing.T) {
+	ch, _ := createChangeset()
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	changes := map[string]interface{}{
+		"name":       "name",
+		"updated_at": time.Now().Round(time.Second),
+	}
+
+	mock.On("Update", query, changes).Return(3, nil)
+
+	count, err := query.UpdateAll(ch)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, int64(3), query.MustUpdateAll(ch))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryUpdateMustAffect(t *testing.T) {
+	ch, user := createChangeset()
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1).MustAffect(1)
+
+	changes := map[string]interface{}{
+		"name":       "name",
+		"updated_at": time.Now().Round(time.Second),
+	}
+
+	mock.On("Update", query, changes).Return(0, nil).Once()
+
+	err := query.Update(&user, ch)
+	assert.Equal(t, errors.NotFoundError("expected at least 1 affected rows, but 0 rows affected"), err)
+	mock.AssertExpectations(t)
+}
+
 func TestPutInsert(t *testing.T) {
 	mock := new(TestAdapter)
 	query := Repo{adapter: mock}.From("users")
@@ -899,7 +1424,7 @@ func TestPutInsert(t *testing.T) {
 	}
 
 	mock.On("Insert", query, changes).Return(1, nil).
-		On("All", query.Find(1).Limit(1), &user).Return(1, nil)
+		On("All", query.Primary().Find(1).Limit(1), &user).Return(1, nil)
 
 	assert.Nil(t, query.Save(&user))
 	assert.NotPanics(t, func() { query.MustSave(&user) })
@@ -919,7 +1444,7 @@ func TestPutInsertMultiple(t *testing.T) {
 	}
 
 	mock.On("InsertAll", query, []map[string]interface{}{changes, changes}).Return([]interface{}{1, 2}, nil).
-		On("All", query.Where(In(I("id"), 1, 2)), &users).Return(1, nil)
+		On("All", query.Primary().Where(In(I("id"), 1, 2)), &users).Return(1, nil)
 
 	assert.Nil(t, query.Save(&users))
 	assert.NotPanics(t, func() { query.MustSave(&users) })
@@ -937,8 +1462,8 @@ func TestPutUpdate(t *testing.T) {
 		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Update", query, changes).Return(nil).
-		On("All", query, &user).Return(1, nil)
+	mock.On("Update", query, changes).Return(1, nil).
+		On("All", query.Primary(), &user).Return(1, nil)
 
 	assert.Nil(t, query.Save(&user))
 	assert.NotPanics(t, func() { query.MustSave(&user) })
@@ -956,8 +1481,8 @@ func TestPutUpdateMultiple(t *testing.T) {
 		"updated_at": time.Now().Round(time.Second),
 	}
 
-	mock.On("Update", query, changes).Return(nil).
-		On("All", query, &users).Return(1, nil)
+	mock.On("Update", query, changes).Return(1, nil).
+		On("All", query.Primary(), &users).Return(1, nil)
 
 	assert.Nil(t, query.Save(&users))
 	assert.NotPanics(t, func() { query.MustSave(&users) })
@@ -977,13 +1502,49 @@ func TestQueryDelete(t *testing.T) {
 	mock := new(TestAdapter)
 	query := Repo{adapter: mock}.From("users")
 
-	mock.On("Delete", query).Return(nil)
+	mock.On("Delete", query).Return(1, nil)
 
 	assert.Nil(t, query.Delete())
 	assert.NotPanics(t, func() { query.MustDelete() })
 	mock.AssertExpectations(t)
 }
 
+func TestQueryDeleteAll(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users")
+
+	mock.On("Delete", query).Return(2, nil)
+
+	count, err := query.DeleteAll()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, int64(2), query.MustDeleteAll())
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteMustAffect(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").Find(1).MustAffect(1)
+
+	mock.On("Delete", query).Return(0, nil).Once()
+
+	err := query.Delete()
+	assert.Equal(t, errors.NotFoundError("expected at least 1 affected rows, but 0 rows affected"), err)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteError(t *testing.T) {
+	mock := new(TestAdapter)
+	query := Repo{adapter: mock}.From("users").MustAffect(1)
+
+	mock.On("Delete", query).Return(0, errors.UnexpectedError("error")).Once()
+
+	count, err := query.DeleteAll()
+	assert.Equal(t, errors.UnexpectedError("error"), err)
+	assert.Equal(t, int64(0), count)
+	mock.AssertExpectations(t)
+}
+
 func TestGetFields(t *testing.T) {
 	var group struct {
 		Name  string
@@ -1010,3 +1571,15 @@ func TestGetFields(t *testing.T) {
 
 	assert.Equal(t, []string{"name"}, getFields(query, []*changeset.Changeset{ch}))
 }
+
+func TestGetFieldsSorted(t *testing.T) {
+	query := repo.From("users")
+	chs := []*changeset.Changeset{
+		changeset.Cast(User{}, map[string]interface{}{"name": "name1", "age": 10}, []string{"name", "age"}),
+		changeset.Cast(User{}, map[string]interface{}{"age": 20}, []string{"name", "age"}),
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, []string{"age", "created_at", "name", "updated_at"}, getFields(query, chs))
+	}
+}
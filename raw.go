@@ -0,0 +1,65 @@
+package grimoire
+
+import (
+	"github.com/Fs02/go-paranoid"
+	"github.com/Fs02/grimoire/errors"
+)
+
+// Raw defines information about raw sql query created by Repo.Raw.
+type Raw struct {
+	repo      *Repo
+	Statement string
+	Args      []interface{}
+}
+
+// One retrieves one result of raw query into record.
+// If no result found, it'll return not found error.
+func (raw Raw) One(record interface{}) error {
+	count, err := raw.query(record)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return errors.NotFoundError("no result found")
+	}
+
+	return nil
+}
+
+// MustOne retrieves one result of raw query into record.
+// It'll panic if any error occurred.
+func (raw Raw) MustOne(record interface{}) {
+	paranoid.Panic(raw.One(record))
+}
+
+// All retrieves all results of raw query into record.
+func (raw Raw) All(record interface{}) error {
+	_, err := raw.query(record)
+	return err
+}
+
+// MustAll retrieves all results of raw query into record.
+// It'll panic if any error occurred.
+func (raw Raw) MustAll(record interface{}) {
+	paranoid.Panic(raw.All(record))
+}
+
+// Exec executes raw statement and returns the number of affected rows.
+func (raw Raw) Exec() (int64, error) {
+	ctx := raw.repo.Context()
+	_, affected, err := raw.repo.adapter.RawExec(ctx, raw.Statement, raw.Args, raw.repo.instrumenters("raw_exec", "")...)
+	return affected, errors.Wrap(err)
+}
+
+// MustExec executes raw statement and returns the number of affected rows.
+// It'll panic if any error occurred.
+func (raw Raw) MustExec() int64 {
+	affected, err := raw.Exec()
+	paranoid.Panic(err)
+	return affected
+}
+
+func (raw Raw) query(record interface{}) (int64, error) {
+	ctx := raw.repo.Context()
+	count, err := raw.repo.adapter.RawQuery(ctx, record, raw.Statement, raw.Args, raw.repo.instrumenters("raw_query", "")...)
+	return count, errors.Wrap(err)
+}
@@ -0,0 +1,108 @@
+package grimoire
+
+import (
+	"testing"
+
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
+)
+
+func TestRepoRaw(t *testing.T) {
+	assert.Equal(t, Raw{
+		repo:      &repo,
+		Statement: "SELECT * FROM users WHERE id=?;",
+		Args:      []interface{}{1},
+	}, repo.Raw("SELECT * FROM users WHERE id=?;", 1))
+}
+
+func TestRawOne(t *testing.T) {
+	user := User{}
+	mock := new(TestAdapter)
+	raw := Repo{adapter: mock}.Raw("SELECT * FROM users WHERE id=?;", 1)
+
+	mock.On("RawQuery", &user, raw.Statement, raw.Args).Return(int64(1), nil).Run(func(args testmock.Arguments) {
+		args.Get(0).(*User).Name = "name"
+	})
+
+	assert.Nil(t, raw.One(&user))
+	assert.NotPanics(t, func() { raw.MustOne(&user) })
+	assert.Equal(t, "name", user.Name)
+	mock.AssertExpectations(t)
+}
+
+func TestRawOneNotFound(t *testing.T) {
+	user := User{}
+	mock := new(TestAdapter)
+	raw := Repo{adapter: mock}.Raw("SELECT * FROM users WHERE id=?;", 1)
+
+	mock.On("RawQuery", &user, raw.Statement, raw.Args).Return(int64(0), nil)
+
+	assert.Equal(t, errors.NotFoundError("no result found"), raw.One(&user))
+	assert.Panics(t, func() { raw.MustOne(&user) })
+	mock.AssertExpectations(t)
+}
+
+func TestRawAll(t *testing.T) {
+	users := []User{}
+	mock := new(TestAdapter)
+	raw := Repo{adapter: mock}.Raw("SELECT * FROM users;")
+
+	mock.On("RawQuery", &users, raw.Statement, raw.Args).Return(int64(0), nil)
+
+	assert.Nil(t, raw.All(&users))
+	assert.NotPanics(t, func() { raw.MustAll(&users) })
+	mock.AssertExpectations(t)
+}
+
+func TestRawAllError(t *testing.T) {
+	users := []User{}
+	mock := new(TestAdapter)
+	raw := Repo{adapter: mock}.Raw("SELECT * FROM users;")
+
+	mock.On("RawQuery", &users, raw.Statement, raw.Args).Return(int64(0), errors.UnexpectedError("error"))
+
+	assert.Equal(t, errors.UnexpectedError("error"), raw.All(&users))
+	assert.Panics(t, func() { raw.MustAll(&users) })
+	mock.AssertExpectations(t)
+}
+
+func TestRawExec(t *testing.T) {
+	mock := new(TestAdapter)
+	raw := Repo{adapter: mock}.Raw("UPDATE users SET name=? WHERE age>?;", "name", 10)
+
+	mock.On("RawExec", raw.Statement, raw.Args).Return(int64(0), int64(2), nil)
+
+	affected, err := raw.Exec()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, int64(2), raw.MustExec())
+	mock.AssertExpectations(t)
+}
+
+func TestRawExecError(t *testing.T) {
+	mock := new(TestAdapter)
+	raw := Repo{adapter: mock}.Raw("DELETE FROM users;")
+
+	mock.On("RawExec", raw.Statement, raw.Args).Return(int64(0), int64(0), errors.UnexpectedError("error"))
+
+	_, err := raw.Exec()
+	assert.Equal(t, errors.UnexpectedError("error"), err)
+	assert.Panics(t, func() { raw.MustExec() })
+	mock.AssertExpectations(t)
+}
+
+func TestRawInTransaction(t *testing.T) {
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).
+		On("RawExec", "DELETE FROM users;", []interface{}(nil)).Return(int64(0), int64(1), nil).
+		On("Commit").Return(nil)
+
+	err := Repo{adapter: mock}.Transaction(func(repo Repo) error {
+		_, err := repo.Raw("DELETE FROM users;").Exec()
+		return err
+	})
+
+	assert.Nil(t, err)
+	mock.AssertExpectations(t)
+}
@@ -1,21 +1,45 @@
 package grimoire
 
 import (
+	"context"
+	"time"
+
 	"github.com/Fs02/grimoire/errors"
 )
 
 // Repo defines grimoire repository.
 type Repo struct {
-	adapter Adapter
-	logger  []Logger
+	adapter       Adapter
+	logger        []Logger
+	contextLogger []ContextLogger
+	instrumenter  []Instrumenter
+	ctx           context.Context
+	softDeletes   map[string]string
+	inTransaction bool
+}
+
+// Option configures repo when it's created.
+type Option func(*Repo)
+
+// Use wraps repo's adapter with middlewares, the first middleware is the outermost one.
+func Use(middlewares ...Middleware) Option {
+	return func(repo *Repo) {
+		repo.adapter = Chain(repo.adapter, middlewares...)
+	}
 }
 
 // New create new repo using adapter.
-func New(adapter Adapter) Repo {
-	return Repo{
+func New(adapter Adapter, options ...Option) Repo {
+	repo := Repo{
 		adapter: adapter,
 		logger:  []Logger{DefaultLogger},
 	}
+
+	for _, option := range options {
+		option(&repo)
+	}
+
+	return repo
 }
 
 // SetLogger replace default logger with custom logger.
@@ -23,23 +47,86 @@ func (repo *Repo) SetLogger(logger ...Logger) {
 	repo.logger = logger
 }
 
+// SetContextLogger sets logger that will receive context of each query.
+func (repo *Repo) SetContextLogger(logger ...ContextLogger) {
+	repo.contextLogger = logger
+}
+
+// SetInstrumenter sets instrumenter that will receive event of each query.
+// Loggers will keep receiving the query, instrumenter can be used alongside them.
+func (repo *Repo) SetInstrumenter(instrumenter ...Instrumenter) {
+	repo.instrumenter = instrumenter
+}
+
+// WithContext returns a copy of repo that uses ctx for every query and transaction.
+func (repo Repo) WithContext(ctx context.Context) Repo {
+	repo.ctx = ctx
+	return repo
+}
+
+// Context returns repo's context, it'll return background context if not set.
+func (repo Repo) Context() context.Context {
+	if repo.ctx == nil {
+		return context.Background()
+	}
+
+	return repo.ctx
+}
+
 // From initiates a query for a collection.
 func (repo Repo) From(collection string) Query {
 	return Query{
 		repo:       &repo,
+		ctx:        repo.ctx,
 		Collection: collection,
 		Fields:     []string{"*"},
 	}
 }
 
+// Raw creates a raw sql query using ? as placeholder of args.
+// The placeholders will be rewritten by adapter that uses ordinal placeholder such as postgres.
+// Use ?? to write literal ?, such as postgres jsonb ?| operator.
+func (repo Repo) Raw(statement string, args ...interface{}) Raw {
+	return Raw{
+		repo:      &repo,
+		Statement: statement,
+		Args:      args,
+	}
+}
+
 // Transaction performs transaction with given function argument.
+// Calling Transaction inside another transaction will create a savepoint, so only changes made by the inner function will be reverted when it fails.
 func (repo Repo) Transaction(fn func(Repo) error) error {
-	adp, err := repo.adapter.Begin()
+	return repo.TransactionWith(TxOptions{}, fn)
+}
+
+// TransactionWith performs transaction using the given options.
+// If retry is enabled, the function will be retried when the transaction fails because of serialization failure or deadlock.
+// Retry is not performed for nested transaction, since the outer transaction needs to be retried instead.
+func (repo Repo) TransactionWith(opts TxOptions, fn func(Repo) error) error {
+	for attempt := 0; ; attempt++ {
+		err := repo.transactionWith(opts, fn)
+		if err == nil || repo.inTransaction || !opts.Retry.retryable(attempt, err) {
+			return err
+		}
+
+		select {
+		case <-repo.Context().Done():
+			return err
+		case <-time.After(opts.Retry.backoff(attempt)):
+		}
+	}
+}
+
+func (repo Repo) transactionWith(opts TxOptions, fn func(Repo) error) error {
+	adp, err := repo.adapter.Begin(repo.Context(), opts.txOptions())
 	if err != nil {
-		return err
+		return errors.Wrap(err)
 	}
 
-	txRepo := New(adp)
+	txRepo := repo
+	txRepo.adapter = adp
+	txRepo.inTransaction = true
 
 	func() {
 		defer func() {
@@ -63,3 +150,37 @@ func (repo Repo) Transaction(fn func(Repo) error) error {
 
 	return err
 }
+
+// transaction performs fn inside transaction, current transaction will be used if repo is already in transaction.
+func (repo Repo) transaction(fn func(Repo) error) error {
+	if repo.inTransaction {
+		return fn(repo)
+	}
+
+	return repo.Transaction(fn)
+}
+
+// instrumenters returns repo's loggers and instrumenters as a single instrumenter for the given operation.
+func (repo Repo) instrumenters(op string, collection string) []Instrumenter {
+	count := len(repo.logger) + len(repo.contextLogger) + len(repo.instrumenter)
+	if count == 0 {
+		return nil
+	}
+
+	instrumenters := make([]Instrumenter, 0, count)
+	for _, l := range repo.logger {
+		instrumenters = append(instrumenters, l)
+	}
+
+	for _, l := range repo.contextLogger {
+		instrumenters = append(instrumenters, l)
+	}
+
+	instrumenters = append(instrumenters, repo.instrumenter...)
+
+	return []Instrumenter{operation{
+		op:            op,
+		collection:    collection,
+		instrumenters: instrumenters,
+	}}
+}
@@ -1,7 +1,9 @@
 package grimoire
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/Fs02/grimoire/errors"
 	"github.com/stretchr/testify/assert"
@@ -20,6 +22,29 @@ func TestRepoSetLogger(t *testing.T) {
 	assert.NotNil(t, repo.logger)
 }
 
+func TestRepoSetContextLogger(t *testing.T) {
+	repo := Repo{}
+	assert.Nil(t, repo.contextLogger)
+	repo.SetContextLogger(func(context.Context, string, time.Duration, error) {})
+	assert.NotNil(t, repo.contextLogger)
+}
+
+func TestRepoSetInstrumenter(t *testing.T) {
+	repo := Repo{}
+	assert.Nil(t, repo.instrumenter)
+	repo.SetInstrumenter(InstrumenterFunc(func(QueryEvent) {}))
+	assert.NotNil(t, repo.instrumenter)
+}
+
+func TestRepoWithContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+	repo := Repo{}.WithContext(ctx)
+
+	assert.Equal(t, ctx, repo.Context())
+	assert.Equal(t, ctx, repo.From("users").Context())
+	assert.Equal(t, context.Background(), Repo{}.Context())
+}
+
 func TestRepoFrom(t *testing.T) {
 	assert.Equal(t, repo.From("users"), Query{
 		repo:       &repo,
@@ -42,6 +67,137 @@ func TestRepoTransaction(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestRepoTransactionWithContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).
+		On("Commit").Return(nil)
+
+	err := Repo{adapter: mock}.WithContext(ctx).Transaction(func(r Repo) error {
+		assert.Equal(t, ctx, r.Context())
+		return nil
+	})
+
+	assert.Nil(t, err)
+	mock.AssertExpectations(t)
+}
+
+func TestRepoTransactionNested(t *testing.T) {
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).Times(3).
+		On("Rollback").Return(nil).Once().
+		On("Commit").Return(nil).Twice()
+
+	err := Repo{adapter: mock}.Transaction(func(r Repo) error {
+		assert.Equal(t, errors.UnexpectedError("error"), r.Transaction(func(r Repo) error {
+			return errors.UnexpectedError("error")
+		}))
+
+		return r.Transaction(func(r Repo) error {
+			return nil
+		})
+	})
+
+	assert.Nil(t, err)
+	mock.AssertExpectations(t)
+}
+
+func TestRepoTransactionWithRetry(t *testing.T) {
+	attempt := 0
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).Twice().
+		On("Rollback").Return(nil).Once().
+		On("Commit").Return(nil).Once()
+
+	opts := TxOptions{
+		Retry: Retry{MaxRetries: 3, Backoff: time.Millisecond},
+	}
+
+	err := Repo{adapter: mock}.TransactionWith(opts, func(r Repo) error {
+		attempt++
+		if attempt == 1 {
+			return errors.SerializationError("serialization failure")
+		}
+
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempt)
+	mock.AssertExpectations(t)
+}
+
+func TestRepoTransactionWithRetryExceeded(t *testing.T) {
+	attempt := 0
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).Times(3).
+		On("Rollback").Return(nil).Times(3)
+
+	opts := TxOptions{
+		Retry: Retry{MaxRetries: 2},
+	}
+
+	err := Repo{adapter: mock}.TransactionWith(opts, func(r Repo) error {
+		attempt++
+		panic(errors.SerializationError("deadlock"))
+	})
+
+	assert.Equal(t, errors.SerializationError("deadlock"), err)
+	assert.Equal(t, 3, attempt)
+	mock.AssertExpectations(t)
+}
+
+func TestRepoTransactionWithoutRetry(t *testing.T) {
+	attempt := 0
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).Twice().
+		On("Rollback").Return(nil).Twice()
+
+	opts := TxOptions{
+		Retry: Retry{MaxRetries: 3},
+	}
+
+	// not a serialization error.
+	err := Repo{adapter: mock}.TransactionWith(opts, func(r Repo) error {
+		attempt++
+		return errors.NotFoundError("error")
+	})
+
+	assert.Equal(t, errors.NotFoundError("error"), err)
+
+	// nested transaction is not retried.
+	err = Repo{adapter: mock, inTransaction: true}.TransactionWith(opts, func(r Repo) error {
+		attempt++
+		return errors.SerializationError("deadlock")
+	})
+
+	assert.Equal(t, errors.SerializationError("deadlock"), err)
+	assert.Equal(t, 2, attempt)
+	mock.AssertExpectations(t)
+}
+
+func TestRepoTransactionWithRetryCanceled(t *testing.T) {
+	attempt := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	mock := new(TestAdapter)
+	mock.On("Begin").Return(nil).Once().
+		On("Rollback").Return(nil).Once()
+
+	opts := TxOptions{
+		Retry: Retry{MaxRetries: 3, Backoff: time.Minute},
+	}
+
+	err := Repo{adapter: mock}.WithContext(ctx).TransactionWith(opts, func(r Repo) error {
+		attempt++
+		cancel()
+		return errors.SerializationError("deadlock")
+	})
+
+	assert.Equal(t, errors.SerializationError("deadlock"), err)
+	assert.Equal(t, 1, attempt)
+	mock.AssertExpectations(t)
+}
+
 func TestTransactionBeginError(t *testing.T) {
 	mock := new(TestAdapter)
 	mock.On("Begin").Return(errors.UnexpectedError("error"))
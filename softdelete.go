@@ -0,0 +1,170 @@
+package grimoire
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+
+	"github.com/Fs02/grimoire/c"
+	"github.com/azer/snakecase"
+)
+
+// SoftDeleteMode defines how query treats soft deleted records.
+type SoftDeleteMode int
+
+const (
+	// SoftDeleteExclude excludes soft deleted records from query result, it's the default mode.
+	SoftDeleteExclude SoftDeleteMode = iota
+	// SoftDeleteInclude includes soft deleted records in query result.
+	SoftDeleteInclude
+	// SoftDeleteOnly only includes soft deleted records in query result.
+	SoftDeleteOnly
+	// SoftDeleteDisabled includes soft deleted records in query result, and deletes records permanently.
+	SoftDeleteDisabled
+)
+
+var (
+	typeTime    = reflect.TypeOf(time.Time{})
+	typeScanner = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// SoftDelete declares that records of collection are soft deleted using field, so soft delete is applied even when the record is unknown.
+// Count, Iterate and Delete without record will exclude or soft delete records of the collection.
+func SoftDelete(collection string, field string) Option {
+	return func(repo *Repo) {
+		if repo.softDeletes == nil {
+			repo.softDeletes = make(map[string]string)
+		}
+
+		repo.softDeletes[collection] = field
+	}
+}
+
+// WithDeleted includes soft deleted records in query result.
+func (query Query) WithDeleted() Query {
+	query.SoftDeleteMode = SoftDeleteInclude
+	return query
+}
+
+// OnlyDeleted only retrieves soft deleted records.
+func (query Query) OnlyDeleted() Query {
+	query.SoftDeleteMode = SoftDeleteOnly
+	return query
+}
+
+// HardDelete deletes records permanently even if the record supports soft delete.
+func (query Query) HardDelete() Query {
+	query.SoftDeleteMode = SoftDeleteDisabled
+	return query
+}
+
+// softDelete returns query that excludes or only includes soft deleted records, depending on query's soft delete mode.
+// Query is returned as is if neither record nor query's collection supports soft delete.
+func (query Query) softDelete(record interface{}) Query {
+	field, ok := query.softDeleteColumn(record)
+	if !ok {
+		return query
+	}
+
+	column := c.I(query.Collection + "." + field)
+
+	switch query.SoftDeleteMode {
+	case SoftDeleteExclude:
+		return query.Where(c.Nil(column))
+	case SoftDeleteOnly:
+		return query.Where(c.NotNil(column))
+	}
+
+	return query
+}
+
+// softDeleteColumn returns soft delete field of record, or field declared for query's collection using SoftDelete option.
+func (query Query) softDeleteColumn(record interface{}) (string, bool) {
+	if field, _, ok := softDeleteField(recordType(record)); ok {
+		return field, true
+	}
+
+	if query.repo == nil {
+		return "", false
+	}
+
+	field, ok := query.repo.softDeletes[query.Collection]
+	return field, ok
+}
+
+// softDeleteField returns column name and index of soft delete field.
+// Record supports soft delete if its struct has DeletedAt field or a field tagged with `soft_delete:"true"`,
+// the field must be nullable since it's null for records that aren't deleted, either *time.Time or a type like sql.NullTime.
+func softDeleteField(rt reflect.Type) (string, int, bool) {
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return "", 0, false
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.Name != "DeletedAt" && f.Tag.Get("soft_delete") != "true" {
+			continue
+		}
+
+		if !nullableTime(f.Type) {
+			continue
+		}
+
+		if tag := f.Tag.Get("db"); tag != "" {
+			return tag, i, tag != "-"
+		}
+
+		return snakecase.SnakeCase(f.Name), i, true
+	}
+
+	return "", 0, false
+}
+
+// nullableTime returns true if rt is *time.Time, or a scanner with Time and Valid field such as sql.NullTime.
+func nullableTime(rt reflect.Type) bool {
+	if rt == reflect.PtrTo(typeTime) {
+		return true
+	}
+
+	if rt.Kind() != reflect.Struct || !reflect.PtrTo(rt).Implements(typeScanner) {
+		return false
+	}
+
+	timeField, hasTime := rt.FieldByName("Time")
+	validField, hasValid := rt.FieldByName("Valid")
+
+	return hasTime && hasValid && timeField.Type == typeTime && validField.Type.Kind() == reflect.Bool
+}
+
+// setDeletedAt sets soft delete field of every struct in record.
+func setDeletedAt(record interface{}, index int, deletedAt time.Time) {
+	rv := reflect.ValueOf(record)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return
+	}
+
+	for _, sv := range structValues(rv.Elem()) {
+		fv := sv.Field(index)
+		if fv.Kind() == reflect.Ptr {
+			t := deletedAt
+			fv.Set(reflect.ValueOf(&t))
+		} else {
+			fv.FieldByName("Time").Set(reflect.ValueOf(deletedAt))
+			fv.FieldByName("Valid").SetBool(true)
+		}
+	}
+}
+
+// recordType returns struct type of record, record can be a struct, slice of struct or pointer to them.
+func recordType(record interface{}) reflect.Type {
+	if record == nil {
+		return nil
+	}
+
+	rt := reflect.TypeOf(record)
+	for rt.Kind() == reflect.Ptr || rt.Kind() == reflect.Slice {
+		rt = rt.Elem()
+	}
+
+	return rt
+}
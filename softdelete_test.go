@@ -0,0 +1,292 @@
+package grimoire
+
+import (
+	"database/sql"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/Fs02/grimoire/c"
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
+)
+
+type SoftUser struct {
+	ID        int64
+	Name      string
+	DeletedAt *time.Time
+}
+
+type TaggedSoftUser struct {
+	ID        int64
+	RemovedAt sql.NullTime `db:"removed_at" soft_delete:"true"`
+}
+
+func TestSoftDeleteMode(t *testing.T) {
+	assert.Equal(t, SoftDeleteInclude, repo.From("users").WithDeleted().SoftDeleteMode)
+	assert.Equal(t, SoftDeleteOnly, repo.From("users").OnlyDeleted().SoftDeleteMode)
+	assert.Equal(t, SoftDeleteDisabled, repo.From("users").HardDelete().SoftDeleteMode)
+}
+
+func TestSoftDeleteField(t *testing.T) {
+	tests := []struct {
+		record interface{}
+		field  string
+		index  int
+		ok     bool
+	}{
+		{SoftUser{}, "deleted_at", 2, true},
+		{&[]SoftUser{}, "deleted_at", 2, true},
+		{&[]*TaggedSoftUser{}, "removed_at", 1, true},
+		{User{}, "", 0, false},
+		{struct{ DeletedAt bool }{}, "", 0, false},
+		{struct{ DeletedAt time.Time }{}, "", 0, false},
+		{struct {
+			DeletedAt *time.Time `db:"-"`
+		}{}, "-", 0, false},
+		{nil, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		field, index, ok := softDeleteField(recordType(tt.record))
+		assert.Equal(t, tt.field, field)
+		assert.Equal(t, tt.index, index)
+		assert.Equal(t, tt.ok, ok)
+	}
+}
+
+func TestQueryAllSoftDelete(t *testing.T) {
+	var (
+		users []SoftUser
+		mock  = new(TestAdapter)
+		query = Repo{adapter: mock}.From("users")
+	)
+
+	mock.On("All", query.Where(Nil(I("users.deleted_at"))), &users).Return(1, nil).Once().
+		On("All", query.OnlyDeleted().Where(NotNil(I("users.deleted_at"))), &users).Return(1, nil).Once().
+		On("All", query.WithDeleted(), &users).Return(1, nil).Once().
+		On("All", query.HardDelete(), &users).Return(1, nil).Once()
+
+	assert.Nil(t, query.All(&users))
+	assert.Nil(t, query.OnlyDeleted().All(&users))
+	assert.Nil(t, query.WithDeleted().All(&users))
+	assert.Nil(t, query.HardDelete().All(&users))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryOneSoftDelete(t *testing.T) {
+	var (
+		user  TaggedSoftUser
+		mock  = new(TestAdapter)
+		query = Repo{adapter: mock}.From("users")
+	)
+
+	mock.On("All", query.Where(Nil(I("users.removed_at"))).Limit(1), &user).Return(1, nil).Once()
+
+	assert.Nil(t, query.One(&user))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryEachSoftDelete(t *testing.T) {
+	var (
+		user  SoftUser
+		mock  = new(TestAdapter)
+		iter  = new(TestIterator)
+		query = Repo{adapter: mock}.From("users")
+	)
+
+	mock.On("Iterate", query.Where(Nil(I("users.deleted_at")))).Return(iter, nil).Once()
+	iter.On("Next", &user).Return(io.EOF).Once().
+		On("Close").Return(nil).Once()
+
+	assert.Nil(t, query.Each(&user, func() error { return nil }))
+	mock.AssertExpectations(t)
+	iter.AssertExpectations(t)
+}
+
+func TestQueryDeleteSoftDelete(t *testing.T) {
+	var (
+		user    = SoftUser{ID: 1}
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("users").Find(1)
+		changes = testmock.MatchedBy(func(changes map[string]interface{}) bool {
+			_, ok := changes["deleted_at"].(time.Time)
+			return ok && len(changes) == 1
+		})
+	)
+
+	mock.On("Update", query.Where(Nil(I("users.deleted_at"))), changes).Return(1, nil).Once()
+
+	assert.Nil(t, query.Delete(&user))
+	assert.NotNil(t, user.DeletedAt)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteSoftDeleteMultiple(t *testing.T) {
+	var (
+		users   = []TaggedSoftUser{{ID: 1}, {ID: 2}}
+		mock    = new(TestAdapter)
+		query   = Repo{adapter: mock}.From("users")
+		changes = testmock.MatchedBy(func(changes map[string]interface{}) bool {
+			_, ok := changes["removed_at"].(time.Time)
+			return ok
+		})
+	)
+
+	mock.On("Update", query.Where(Nil(I("users.removed_at"))), changes).Return(2, nil).Once()
+
+	count, err := query.DeleteAll(&users)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.True(t, users[0].RemovedAt.Valid)
+	assert.False(t, users[0].RemovedAt.Time.IsZero())
+	assert.Equal(t, users[0].RemovedAt, users[1].RemovedAt)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteSoftDeleteError(t *testing.T) {
+	var (
+		user  = SoftUser{ID: 1}
+		mock  = new(TestAdapter)
+		query = Repo{adapter: mock}.From("users").Find(1)
+	)
+
+	mock.On("Update", query.Where(Nil(I("users.deleted_at"))), testmock.Anything).Return(0, errors.UnexpectedError("error")).Once()
+
+	assert.Equal(t, errors.UnexpectedError("error"), query.Delete(&user))
+	assert.Nil(t, user.DeletedAt)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryHardDelete(t *testing.T) {
+	var (
+		user  = SoftUser{ID: 1}
+		mock  = new(TestAdapter)
+		query = Repo{adapter: mock}.From("users").Find(1)
+	)
+
+	mock.On("Delete", query.HardDelete()).Return(1, nil).Once().
+		On("Delete", query).Return(1, nil).Twice()
+
+	assert.Nil(t, query.HardDelete().Delete(&user))
+	assert.Nil(t, query.Delete())
+	assert.Nil(t, query.Delete(&User{}))
+	assert.Nil(t, user.DeletedAt)
+	mock.AssertExpectations(t)
+}
+
+func TestQueryCountSoftDelete(t *testing.T) {
+	var (
+		mock  = new(TestAdapter)
+		repo  = Repo{adapter: mock}
+		query = repo.From("users")
+	)
+
+	SoftDelete("users", "deleted_at")(&repo)
+	softQuery := repo.From("users")
+
+	mock.On("Count", matchQuery(query.Where(Nil(I("users.deleted_at"))))).Return(1, nil).Once().
+		On("Count", matchQuery(query.WithDeleted())).Return(2, nil).Once().
+		On("Count", matchQuery(repo.From("addresses"))).Return(3, nil).Once()
+
+	assert.Equal(t, 1, softQuery.MustCount())
+	assert.Equal(t, 2, softQuery.WithDeleted().MustCount())
+	assert.Equal(t, 3, repo.From("addresses").MustCount())
+	mock.AssertExpectations(t)
+}
+
+func TestQueryIterateSoftDelete(t *testing.T) {
+	var (
+		mock = new(TestAdapter)
+		iter = new(TestIterator)
+		repo = Repo{adapter: mock}
+		user SoftUser
+	)
+
+	SoftDelete("users", "deleted_at")(&repo)
+	query := repo.From("users")
+
+	mock.On("Iterate", matchQuery(query.OnlyDeleted().Where(NotNil(I("users.deleted_at"))))).Return(iter, nil).Once().
+		On("Iterate", matchQuery(query.Where(Nil(I("users.deleted_at"))))).Return(iter, nil).Once()
+	iter.On("Next", &user).Return(io.EOF).Once().
+		On("Close").Return(nil).Once()
+
+	_, err := query.OnlyDeleted().Iterate()
+	assert.Nil(t, err)
+
+	// scope is applied once when both record and collection support soft delete.
+	assert.Nil(t, query.Each(&user, func() error { return nil }))
+	mock.AssertExpectations(t)
+	iter.AssertExpectations(t)
+}
+
+func TestQueryPreloadSoftDelete(t *testing.T) {
+	var (
+		mock   = new(TestAdapter)
+		repo   = Repo{adapter: mock}
+		people = []Person{{ID: 1}}
+	)
+
+	SoftDelete("addresses", "deleted_at")(&repo)
+
+	mock.On("All", matchQuery(repo.From("addresses").Where(In(I("person_id"), int64(1)), Nil(I("addresses.deleted_at")))), new([]Address)).Return(0, nil).Once()
+
+	assert.Nil(t, repo.From("people").preload(&people, "addresses"))
+	mock.AssertExpectations(t)
+}
+
+func TestQueryDeleteSoftDeleteWithoutRecord(t *testing.T) {
+	var (
+		mock    = new(TestAdapter)
+		repo    = Repo{adapter: mock}
+		changes = testmock.MatchedBy(func(changes map[string]interface{}) bool {
+			_, ok := changes["deleted_at"].(time.Time)
+			return ok && len(changes) == 1
+		})
+	)
+
+	SoftDelete("users", "deleted_at")(&repo)
+	query := repo.From("users").Find(1)
+
+	mock.On("Update", matchQuery(query.Where(Nil(I("users.deleted_at")))), changes).Return(1, nil).Twice().
+		On("Delete", matchQuery(query.HardDelete())).Return(1, nil).Once()
+
+	assert.Nil(t, query.Delete())
+	assert.Nil(t, query.Delete(&User{}))
+	assert.Nil(t, query.HardDelete().Delete())
+	mock.AssertExpectations(t)
+}
+
+func TestQueryToSQLSoftDelete(t *testing.T) {
+	var (
+		mock    = new(TestAdapter)
+		repo    = Repo{adapter: mock}
+		changes = testmock.MatchedBy(func(changes map[string]interface{}) bool {
+			_, ok := changes["deleted_at"].(time.Time)
+			return ok && len(changes) == 1
+		})
+	)
+
+	SoftDelete("users", "deleted_at")(&repo)
+	query := repo.From("users").Find(1)
+	scoped := query.Where(Nil(I("users.deleted_at")))
+
+	mock.On("Render", "all", matchQuery(scoped), map[string]interface{}(nil)).Return("SELECT * FROM users WHERE (id=? AND users.deleted_at IS NULL);", []interface{}{1}, nil).Once().
+		On("Render", "update", matchQuery(scoped), changes).Return("UPDATE users SET deleted_at=? WHERE (id=? AND users.deleted_at IS NULL);", []interface{}{time.Now(), 1}, nil).Once().
+		On("Render", "delete", matchQuery(query.HardDelete()), map[string]interface{}(nil)).Return("DELETE FROM users WHERE id=?;", []interface{}{1}, nil).Once()
+
+	statement, _, err := query.ToSQL("all")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE (id=? AND users.deleted_at IS NULL);", statement)
+
+	statement, _, err = query.ToSQL("delete")
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE users SET deleted_at=? WHERE (id=? AND users.deleted_at IS NULL);", statement)
+
+	statement, _, err = query.HardDelete().ToSQL("delete")
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE FROM users WHERE id=?;", statement)
+
+	mock.AssertExpectations(t)
+}
@@ -0,0 +1,56 @@
+package grimoire
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Fs02/grimoire/errors"
+)
+
+// TxOptions defines options used by TransactionWith.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	Retry     Retry
+}
+
+func (opts TxOptions) txOptions() *sql.TxOptions {
+	if opts.Isolation == sql.LevelDefault && !opts.ReadOnly {
+		return nil
+	}
+
+	return &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  opts.ReadOnly,
+	}
+}
+
+// Retry defines policy to retry transaction that fails because of serialization failure or deadlock.
+// Backoff is doubled after each attempt and limited to MaxBackoff if specified.
+type Retry struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (retry Retry) retryable(attempt int, err error) bool {
+	if attempt >= retry.MaxRetries {
+		return false
+	}
+
+	e, ok := err.(errors.Error)
+	return ok && e.SerializationError()
+}
+
+func (retry Retry) backoff(attempt int) time.Duration {
+	backoff := retry.Backoff
+	for i := 0; i < attempt && (retry.MaxBackoff == 0 || backoff < retry.MaxBackoff); i++ {
+		backoff *= 2
+	}
+
+	if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+		return retry.MaxBackoff
+	}
+
+	return backoff
+}
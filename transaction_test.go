@@ -0,0 +1,43 @@
+package grimoire
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Fs02/grimoire/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxOptions(t *testing.T) {
+	assert.Nil(t, TxOptions{}.txOptions())
+	assert.Nil(t, TxOptions{Retry: Retry{MaxRetries: 3}}.txOptions())
+
+	assert.Equal(t, &sql.TxOptions{Isolation: sql.LevelSerializable}, TxOptions{Isolation: sql.LevelSerializable}.txOptions())
+	assert.Equal(t, &sql.TxOptions{ReadOnly: true}, TxOptions{ReadOnly: true}.txOptions())
+}
+
+func TestRetryRetryable(t *testing.T) {
+	retry := Retry{MaxRetries: 2}
+
+	assert.True(t, retry.retryable(0, errors.SerializationError("deadlock")))
+	assert.True(t, retry.retryable(1, errors.SerializationError("deadlock")))
+	assert.False(t, retry.retryable(2, errors.SerializationError("deadlock")))
+	assert.False(t, retry.retryable(0, errors.UnexpectedError("error")))
+	assert.False(t, Retry{}.retryable(0, errors.SerializationError("deadlock")))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	retry := Retry{Backoff: 10 * time.Millisecond}
+
+	assert.Equal(t, 10*time.Millisecond, retry.backoff(0))
+	assert.Equal(t, 20*time.Millisecond, retry.backoff(1))
+	assert.Equal(t, 40*time.Millisecond, retry.backoff(2))
+
+	retry.MaxBackoff = 30 * time.Millisecond
+	assert.Equal(t, 20*time.Millisecond, retry.backoff(1))
+	assert.Equal(t, 30*time.Millisecond, retry.backoff(2))
+	assert.Equal(t, 30*time.Millisecond, retry.backoff(10))
+
+	assert.Equal(t, time.Duration(0), Retry{}.backoff(3))
+}